@@ -26,6 +26,6 @@ import (
 func main() {
 	ctx := filteredinformerfactory.WithSelectors(context.Background(), v1alpha1.ManagedByLabelKey)
 	sharedmain.MainWithContext(ctx, "controller",
-		framework.NewController(ctx, &bundle.Resolver{}),
+		framework.NewController(ctx, &bundle.Resolver{}, framework.WithContentPolicyFromConfigMap(framework.ContentPolicyConfigMapName)),
 	)
 }
@@ -18,11 +18,11 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"io/ioutil"
 
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/tektoncd/resolution/pkg/resolver/framework"
 )
 
 // RequestOptions are the options used to request a resource from
@@ -107,6 +107,9 @@ func GetEntry(ctx context.Context, keychain authn.Keychain, opts RequestOptions)
 					if err != nil {
 						return nil, fmt.Errorf("error reading tarball header: %w", err)
 					}
+					if limit := framework.MaxResolvedDataSize(ctx); limit > 0 && header.Size > limit {
+						return nil, framework.NewErrorResolvedDataTooLarge(limit)
+					}
 					data := make([]byte, header.Size)
 					if n, err := tarReader.Read(data); err != nil && err != io.EOF {
 						return nil, fmt.Errorf("invalid tarball: %w", err)
@@ -133,7 +136,7 @@ func GetEntry(ctx context.Context, keychain authn.Keychain, opts RequestOptions)
 					return nil, fmt.Errorf("error decompressing layer: %w", err)
 				}
 				defer layerReader.Close()
-				data, err := ioutil.ReadAll(layerReader)
+				data, err := framework.ReadAllWithLimit(layerReader, framework.MaxResolvedDataSize(ctx))
 				if err != nil {
 					return nil, fmt.Errorf("error reading layer content: %w", err)
 				}
@@ -0,0 +1,62 @@
+package bundle
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	ttesting "github.com/tektoncd/resolution/pkg/reconciler/testing"
+	frtesting "github.com/tektoncd/resolution/pkg/resolver/framework/testing"
+	"github.com/tektoncd/resolution/test/fixtures"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakekubeclient "knative.dev/pkg/client/injection/kube/client/fake"
+)
+
+func TestConformance(t *testing.T) {
+	ctx, _ := ttesting.SetupFakeContext(t)
+	if _, err := fakekubeclient.Get(ctx).CoreV1().ServiceAccounts("default").Create(ctx, &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "default"},
+	}, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("error creating service account: %v", err)
+	}
+
+	// A registry that never answers, so that pulls only finish once the
+	// resolver gives up.
+	done := make(chan struct{})
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+	defer svr.Close()
+	defer close(done)
+
+	registry := fixtures.NewRegistry(t, fixtures.Bundle{
+		Name: "catalog/tasks:v1",
+		Resources: []string{`apiVersion: tekton.dev/v1beta1
+kind: Task
+metadata:
+  name: foo
+`},
+	})
+
+	frtesting.RunConformanceTests(ctx, t, frtesting.ConformanceSpec{
+		Resolver: &Resolver{},
+		Config: map[string]string{
+			ConfigServiceAccount: "default",
+			ConfigKind:           "task",
+		},
+		ValidParams: map[string]string{
+			ParamBundle: registry.Ref("catalog/tasks:v1"),
+			ParamName:   "foo",
+		},
+		RequiredParams: []string{ParamBundle, ParamName},
+		BlockingParams: map[string]string{
+			ParamBundle: strings.TrimPrefix(svr.URL, "http://") + "/bundle:latest",
+			ParamName:   "foo",
+		},
+	})
+}
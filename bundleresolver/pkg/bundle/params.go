@@ -22,7 +22,8 @@ import (
 )
 
 // ParamServiceAccount is the parameter defining what service
-// account name to use for bundle requests.
+// account name to use for bundle requests. Bundles are pulled
+// anonymously if it's empty.
 const ParamServiceAccount = "serviceAccount"
 
 // ParamBundle is the parameter defining what the bundle image url is.
@@ -18,9 +18,12 @@ package bundle
 
 import (
 	"context"
+	"fmt"
 	"time"
 
+	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/authn/k8schain"
+	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/tektoncd/resolution/pkg/common"
 	"github.com/tektoncd/resolution/pkg/resolver/framework"
 	"k8s.io/client-go/kubernetes"
@@ -63,6 +66,23 @@ func (r *Resolver) GetSelector(context.Context) map[string]string {
 	}
 }
 
+var _ framework.ClusterShareable = &Resolver{}
+
+// IsClusterShareable returns true if the request is for a bundle
+// pinned by digest, e.g. gcr.io/tekton-releases/catalog@sha256:...,
+// whose contents can never change, and it's pulled anonymously. Tags
+// can be moved to another image, and pulls with a service account use
+// the credentials of the namespace the request was made from, so the
+// results of those requests aren't shared.
+func (r *Resolver) IsClusterShareable(ctx context.Context, params map[string]string) bool {
+	opts, err := OptionsFromParams(ctx, params)
+	if err != nil || opts.ServiceAccount != "" {
+		return false
+	}
+	_, err = name.NewDigest(opts.Bundle)
+	return err == nil
+}
+
 // ValidateParams ensures parameters from a request are as expected.
 func (r *Resolver) ValidateParams(ctx context.Context, params map[string]string) error {
 	if _, err := OptionsFromParams(ctx, params); err != nil {
@@ -77,11 +97,17 @@ func (r *Resolver) Resolve(ctx context.Context, params map[string]string) (frame
 	if err != nil {
 		return nil, err
 	}
-	namespace := common.RequestNamespace(ctx)
-	kc, err := k8schain.New(ctx, r.kubeClientSet, k8schain.Options{
-		Namespace:          namespace,
-		ServiceAccountName: opts.ServiceAccount,
-	})
+	// Without a service account the bundle is pulled anonymously.
+	kc := authn.NewMultiKeychain()
+	if opts.ServiceAccount != "" {
+		kc, err = k8schain.New(ctx, r.kubeClientSet, k8schain.Options{
+			Namespace:          common.RequestNamespace(ctx),
+			ServiceAccountName: opts.ServiceAccount,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error getting credentials for service account %q: %w", opts.ServiceAccount, err)
+		}
+	}
 	ctx, cancelFn := context.WithTimeout(ctx, timeoutDuration)
 	defer cancelFn()
 	return GetEntry(ctx, kc, opts)
@@ -4,7 +4,15 @@ import (
 	"context"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
 	resolutioncommon "github.com/tektoncd/resolution/pkg/common"
+	ttesting "github.com/tektoncd/resolution/pkg/reconciler/testing"
+	"github.com/tektoncd/resolution/pkg/resolver/framework"
+	"github.com/tektoncd/resolution/test/diff"
+	"github.com/tektoncd/resolution/test/fixtures"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakekubeclient "knative.dev/pkg/client/injection/kube/client/fake"
 )
 
 func TestGetSelector(t *testing.T) {
@@ -67,3 +75,136 @@ func TestValidateParamsMissing(t *testing.T) {
 	}
 
 }
+
+func TestResolve(t *testing.T) {
+	ctx, _ := ttesting.SetupFakeContext(t)
+	if _, err := fakekubeclient.Get(ctx).CoreV1().ServiceAccounts("foo").Create(ctx, &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "foo"},
+	}, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("error creating service account: %v", err)
+	}
+	taskYAML := "apiVersion: tekton.dev/v1beta1\nkind: Task\nmetadata:\n  name: foo\n"
+	pipelineYAML := "apiVersion: tekton.dev/v1beta1\nkind: Pipeline\nmetadata:\n  name: foo\n"
+	registry := fixtures.NewRegistry(t, fixtures.Bundle{
+		Name:      "catalog/bundle:v1",
+		Resources: []string{taskYAML, pipelineYAML},
+	})
+
+	resolver := &Resolver{}
+	if err := resolver.Initialize(ctx); err != nil {
+		t.Fatalf("error initializing resolver: %v", err)
+	}
+	ctx = resolutioncommon.InjectRequestNamespace(ctx, "foo")
+
+	for _, tc := range []struct {
+		name            string
+		params          map[string]string
+		expectedContent string
+		expectedKind    string
+		limit           int64
+		expectedErr     string
+	}{{
+		name: "task by tag",
+		params: map[string]string{
+			ParamBundle: registry.Ref("catalog/bundle:v1"),
+			ParamName:   "foo",
+			ParamKind:   "task",
+		},
+		expectedContent: taskYAML,
+		expectedKind:    "task",
+	}, {
+		name: "pipeline by digest",
+		params: map[string]string{
+			ParamBundle: registry.DigestRef("catalog/bundle:v1"),
+			ParamName:   "foo",
+			ParamKind:   "pipeline",
+		},
+		expectedContent: pipelineYAML,
+		expectedKind:    "pipeline",
+	}, {
+		name: "no matching entry",
+		params: map[string]string{
+			ParamBundle: registry.Ref("catalog/bundle:v1"),
+			ParamName:   "bar",
+			ParamKind:   "task",
+		},
+		expectedErr: "no matching image layer",
+	}, {
+		name: "anonymous",
+		params: map[string]string{
+			ParamBundle:         registry.Ref("catalog/bundle:v1"),
+			ParamName:           "foo",
+			ParamKind:           "task",
+			ParamServiceAccount: "",
+		},
+		expectedContent: taskYAML,
+		expectedKind:    "task",
+	}, {
+		name: "larger than limit",
+		params: map[string]string{
+			ParamBundle: registry.Ref("catalog/bundle:v1"),
+			ParamName:   "foo",
+			ParamKind:   "task",
+		},
+		limit:       10,
+		expectedErr: "resolved data is larger than the limit of 10 bytes",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, ok := tc.params[ParamServiceAccount]; !ok {
+				tc.params[ParamServiceAccount] = "default"
+			}
+			resolved, err := resolver.Resolve(framework.InjectMaxResolvedDataSize(ctx, tc.limit), tc.params)
+			if tc.expectedErr != "" {
+				if err == nil || err.Error() != tc.expectedErr {
+					t.Fatalf("expected error %q, got %v", tc.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error resolving: %v", err)
+			}
+			if string(resolved.Data()) != tc.expectedContent {
+				t.Errorf("expected content %q, got %q", tc.expectedContent, resolved.Data())
+			}
+			expectedAnnotations := map[string]string{
+				BundleAnnotationKind:       tc.expectedKind,
+				BundleAnnotationName:       "foo",
+				BundleAnnotationAPIVersion: "tekton.dev/v1beta1",
+			}
+			if d := cmp.Diff(expectedAnnotations, resolved.Annotations()); d != "" {
+				t.Errorf("unexpected annotations %s", diff.PrintWantGot(d))
+			}
+		})
+	}
+}
+
+func TestIsClusterShareable(t *testing.T) {
+	for bundle, expected := range map[string]bool{
+		"gcr.io/tekton-releases/catalog@sha256:9b0a0d7d7eb2b0c1df4ea35a6d8e6bdcf6c0b1f7b1b4ff9fe0b9a1bdac3dba5f":    true,
+		"gcr.io/tekton-releases/catalog:v1@sha256:9b0a0d7d7eb2b0c1df4ea35a6d8e6bdcf6c0b1f7b1b4ff9fe0b9a1bdac3dba5f": true,
+		"gcr.io/tekton-releases/catalog:v1":         false,
+		"gcr.io/tekton-releases/catalog":            false,
+		"gcr.io/tekton-releases/catalog@sha256:abc": false,
+	} {
+		params := map[string]string{ParamBundle: bundle, ParamName: "build", ParamKind: "task", ParamServiceAccount: ""}
+		if actual := (&Resolver{}).IsClusterShareable(context.Background(), params); actual != expected {
+			t.Errorf("expected bundle %q to be shareable: %t, got %t", bundle, expected, actual)
+		}
+	}
+}
+
+func TestIsClusterShareableWithServiceAccount(t *testing.T) {
+	digestRef := "gcr.io/tekton-releases/catalog@sha256:9b0a0d7d7eb2b0c1df4ea35a6d8e6bdcf6c0b1f7b1b4ff9fe0b9a1bdac3dba5f"
+	params := map[string]string{ParamBundle: digestRef, ParamName: "build", ParamKind: "task", ParamServiceAccount: "builder"}
+	if (&Resolver{}).IsClusterShareable(context.Background(), params) {
+		t.Error("expected request with a service account not to be shareable")
+	}
+
+	// The default service account is used when the param isn't
+	// given, and its credentials are just as namespace-scoped.
+	delete(params, ParamServiceAccount)
+	ctx := framework.InjectResolverConfigToContext(context.Background(), map[string]string{ConfigServiceAccount: "default"})
+	if (&Resolver{}).IsClusterShareable(ctx, params) {
+		t.Error("expected request with the default service account not to be shareable")
+	}
+}
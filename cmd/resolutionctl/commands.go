@@ -0,0 +1,322 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/tektoncd/resolution/internal/cmdutil"
+	"github.com/tektoncd/resolution/pkg/apis/resolution/v1alpha1"
+	"github.com/tektoncd/resolution/pkg/client/informers/externalversions"
+	resolutioncommon "github.com/tektoncd/resolution/pkg/common"
+	"github.com/tektoncd/resolution/pkg/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"knative.dev/pkg/apis"
+)
+
+// The states a ResolutionRequest can be listed by.
+const (
+	stateInProgress = "InProgress"
+	stateSucceeded  = "Succeeded"
+	stateFailed     = "Failed"
+)
+
+// submit creates a ResolutionRequest with a name generated from its
+// resolver and params, so that submitting the same request twice
+// reuses the first one.
+func (c *cli) submit(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("submit", flag.ContinueOnError)
+	resolverType := fs.String("type", "", "the type of resolver to send the request to")
+	params := cmdutil.ParamFlags{}
+	fs.Var(params, "param", "a key=value param to pass to the resolver, may be repeated")
+	name := fs.String("name", "", "the name of the ResolutionRequest, generated from its type and params if not given")
+	wait := fs.Bool("wait", false, "wait for the request to complete and print its result")
+	timeout := fs.Duration("timeout", time.Minute, "how long to wait when -wait is given")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *resolverType == "" {
+		return errors.New("-type is required")
+	}
+	if *name == "" {
+		var err error
+		if *name, err = resource.GenerateDeterministicName(*resolverType, *resolverType, params); err != nil {
+			return fmt.Errorf("error generating name: %w", err)
+		}
+	}
+
+	requester, err := c.requesterFor(ctx, *name)
+	if err != nil {
+		return err
+	}
+	resolver := resource.ResolverName(*resolverType)
+	req := resource.NewRequest(*name, c.namespace, params)
+	if *wait {
+		ctx, cancel := context.WithTimeout(ctx, *timeout)
+		defer cancel()
+		resolved, err := requester.SubmitAndWait(ctx, resolver, req)
+		if err != nil {
+			return fmt.Errorf("resolutionrequest %q: %w", *name, err)
+		}
+		return cmdutil.WriteResolved(c.out, resolved)
+	}
+
+	resolved, err := requester.Submit(ctx, resolver, req)
+	switch {
+	case errors.Is(err, resolutioncommon.ErrorRequestInProgress):
+		_, err = fmt.Fprintf(c.out, "resolutionrequest/%s submitted\n", *name)
+		return err
+	case err != nil:
+		return fmt.Errorf("resolutionrequest %q: %w", *name, err)
+	}
+	return cmdutil.WriteResolved(c.out, resolved)
+}
+
+// requesterFor returns a CRDRequester whose informer only holds the
+// named ResolutionRequest, rather than every request in the namespace.
+func (c *cli) requesterFor(ctx context.Context, name string) (*resource.CRDRequester, error) {
+	factory := externalversions.NewSharedInformerFactoryWithOptions(c.clientset, 0,
+		externalversions.WithNamespace(c.namespace),
+		externalversions.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.FieldSelector = fields.OneTermEqualSelector("metadata.name", name).String()
+		}),
+	)
+	requester := resource.NewCRDRequesterWithInformer(c.clientset, factory.Resolution().V1alpha1().ResolutionRequests())
+	factory.Start(ctx.Done())
+	for typ, synced := range factory.WaitForCacheSync(ctx.Done()) {
+		if !synced {
+			return nil, fmt.Errorf("error syncing informer for %v", typ)
+		}
+	}
+	return requester, nil
+}
+
+// wait waits for a ResolutionRequest that has already been submitted
+// to complete and prints its result.
+func (c *cli) wait(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("wait", flag.ContinueOnError)
+	timeout := fs.Duration("timeout", time.Minute, "how long to wait for the request to complete")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	name, err := nameArg(fs)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+	resolved, err := resource.WaitForResolution(ctx, c.clientset, c.namespace, name)
+	if err != nil {
+		return fmt.Errorf("resolutionrequest %q: %w", name, err)
+	}
+	return cmdutil.WriteResolved(c.out, resolved)
+}
+
+// show prints a ResolutionRequest's status along with its annotations
+// and decoded data.
+func (c *cli) show(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("show", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	name, err := nameArg(fs)
+	if err != nil {
+		return err
+	}
+
+	rr, err := c.clientset.ResolutionV1alpha1().ResolutionRequests(c.namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	data, err := base64.StdEncoding.DecodeString(rr.Status.Data)
+	if err != nil {
+		return fmt.Errorf("error decoding data of resolutionrequest %q: %w", name, err)
+	}
+
+	w := tabwriter.NewWriter(c.out, 0, 4, 1, ' ', 0)
+	fmt.Fprintf(w, "Name:\t%s\n", rr.Name)
+	fmt.Fprintf(w, "Namespace:\t%s\n", rr.Namespace)
+	fmt.Fprintf(w, "Resolver:\t%s\n", rr.Labels[resolutioncommon.LabelKeyResolverType])
+	fmt.Fprintf(w, "Age:\t%s\n", age(c.now().Sub(rr.CreationTimestamp.Time)))
+	fmt.Fprintf(w, "State:\t%s\n", stateOf(rr))
+	if condition := rr.Status.GetCondition(apis.ConditionSucceeded); condition != nil {
+		if condition.Reason != "" {
+			fmt.Fprintf(w, "Reason:\t%s\n", condition.Reason)
+		}
+		if condition.Message != "" {
+			fmt.Fprintf(w, "Message:\t%s\n", condition.Message)
+		}
+	}
+	fmt.Fprintf(w, "Params:\n")
+	for _, key := range cmdutil.SortedKeys(rr.Spec.Parameters) {
+		fmt.Fprintf(w, "  %s:\t%s\n", key, rr.Spec.Parameters[key])
+	}
+	fmt.Fprintf(w, "Annotations:\n")
+	for _, key := range cmdutil.SortedKeys(rr.Status.Annotations) {
+		fmt.Fprintf(w, "  %s:\t%s\n", key, rr.Status.Annotations[key])
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	if _, err := io.WriteString(c.out, "Data:\n"); err != nil {
+		return err
+	}
+	return cmdutil.WriteData(c.out, data)
+}
+
+// list prints the ResolutionRequests in the namespace, optionally only
+// those for one resolver or in one state.
+func (c *cli) list(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	resolverType := fs.String("type", "", "only list requests for this type of resolver")
+	state := fs.String("state", "", "only list requests in this state: InProgress, Succeeded or Failed")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := validateState(*state); err != nil {
+		return err
+	}
+
+	rrs, err := c.listRequests(ctx, *resolverType, *state)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(c.out, 0, 4, 3, ' ', 0)
+	fmt.Fprintf(w, "NAME\tRESOLVER\tSTATE\tAGE\n")
+	for _, rr := range rrs {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", rr.Name, rr.Labels[resolutioncommon.LabelKeyResolverType], stateOf(rr), age(c.now().Sub(rr.CreationTimestamp.Time)))
+	}
+	return w.Flush()
+}
+
+// clean deletes completed ResolutionRequests that are older than a given
+// age. Requests that are still in progress are never deleted.
+func (c *cli) clean(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("clean", flag.ContinueOnError)
+	resolverType := fs.String("type", "", "only delete requests for this type of resolver")
+	state := fs.String("state", "", "only delete requests in this state: Succeeded or Failed")
+	olderThan := fs.Duration("older-than", 24*time.Hour, "only delete requests created longer ago than this")
+	dryRun := fs.Bool("dry-run", false, "print the requests that would be deleted without deleting them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := validateState(*state); err != nil {
+		return err
+	}
+	if *state == stateInProgress {
+		return errors.New("requests that are in progress can't be cleaned up")
+	}
+
+	rrs, err := c.listRequests(ctx, *resolverType, *state)
+	if err != nil {
+		return err
+	}
+	for _, rr := range rrs {
+		if !rr.IsDone() || c.now().Sub(rr.CreationTimestamp.Time) < *olderThan {
+			continue
+		}
+		if *dryRun {
+			fmt.Fprintf(c.out, "resolutionrequest/%s would be deleted\n", rr.Name)
+			continue
+		}
+		if err := c.clientset.ResolutionV1alpha1().ResolutionRequests(rr.Namespace).Delete(ctx, rr.Name, metav1.DeleteOptions{}); err != nil {
+			return fmt.Errorf("error deleting resolutionrequest %q: %w", rr.Name, err)
+		}
+		fmt.Fprintf(c.out, "resolutionrequest/%s deleted\n", rr.Name)
+	}
+	return nil
+}
+
+// listRequests lists the ResolutionRequests in the namespace, sorted by
+// name, that are for the given resolver and in the given state. Either
+// can be empty to match everything.
+func (c *cli) listRequests(ctx context.Context, resolverType, state string) ([]*v1alpha1.ResolutionRequest, error) {
+	selector := labels.Everything()
+	if resolverType != "" {
+		selector = labels.SelectorFromSet(labels.Set{resolutioncommon.LabelKeyResolverType: resolverType})
+	}
+	list, err := c.clientset.ResolutionV1alpha1().ResolutionRequests(c.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: selector.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	rrs := []*v1alpha1.ResolutionRequest{}
+	for i := range list.Items {
+		rr := &list.Items[i]
+		if state != "" && stateOf(rr) != state {
+			continue
+		}
+		rrs = append(rrs, rr)
+	}
+	sort.Slice(rrs, func(i, j int) bool {
+		return rrs[i].Name < rrs[j].Name
+	})
+	return rrs, nil
+}
+
+// stateOf returns the state of a ResolutionRequest going by its
+// Succeeded condition.
+func stateOf(rr *v1alpha1.ResolutionRequest) string {
+	condition := rr.Status.GetCondition(apis.ConditionSucceeded)
+	switch {
+	case condition.IsTrue():
+		return stateSucceeded
+	case condition.IsFalse():
+		return stateFailed
+	default:
+		return stateInProgress
+	}
+}
+
+func validateState(state string) error {
+	switch state {
+	case "", stateInProgress, stateSucceeded, stateFailed:
+		return nil
+	default:
+		return fmt.Errorf("invalid state %q, must be one of %s, %s or %s", state, stateInProgress, stateSucceeded, stateFailed)
+	}
+}
+
+// age formats how long ago something happened in the short form
+// kubectl uses, e.g. 45s, 10m, 3h or 2d.
+func age(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	}
+}
@@ -0,0 +1,251 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tektoncd/resolution/pkg/apis/resolution/v1alpha1"
+	"github.com/tektoncd/resolution/pkg/client/clientset/versioned/fake"
+	resolutioncommon "github.com/tektoncd/resolution/pkg/common"
+	"github.com/tektoncd/resolution/pkg/resource"
+	"github.com/tektoncd/resolution/test/diff"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var now = time.Date(2022, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+func TestSubmit(t *testing.T) {
+	c, clientset, out := newTestCLI()
+	args := []string{"submit", "-type", "git", "-param", "url=https://example.com/repo.git", "-param", "pathInRepo=task.yaml"}
+
+	if err := c.run(context.Background(), args); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	name, err := resource.GenerateDeterministicName("git", "git", map[string]string{
+		"url":        "https://example.com/repo.git",
+		"pathInRepo": "task.yaml",
+	})
+	if err != nil {
+		t.Fatalf("error generating name: %v", err)
+	}
+	if d := cmp.Diff("resolutionrequest/"+name+" submitted\n", out.String()); d != "" {
+		t.Errorf("unexpected output %s", diff.PrintWantGot(d))
+	}
+	rr, err := clientset.ResolutionV1alpha1().ResolutionRequests("default").Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected request to be created: %v", err)
+	}
+	if rr.Labels[resolutioncommon.LabelKeyResolverType] != "git" {
+		t.Errorf("expected request to be labelled for the git resolver, got labels %v", rr.Labels)
+	}
+}
+
+func TestSubmitAlreadyResolved(t *testing.T) {
+	c, _, out := newTestCLI(newRequest("done", "git", stateSucceeded, 0))
+
+	if err := c.run(context.Background(), []string{"submit", "-type", "git", "-name", "done"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d := cmp.Diff("# revision: main\nkind: Task\n", out.String()); d != "" {
+		t.Errorf("unexpected output %s", diff.PrintWantGot(d))
+	}
+}
+
+func TestWait(t *testing.T) {
+	c, _, out := newTestCLI(newRequest("done", "git", stateSucceeded, 0))
+
+	if err := c.run(context.Background(), []string{"wait", "-timeout", "5s", "done"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d := cmp.Diff("# revision: main\nkind: Task\n", out.String()); d != "" {
+		t.Errorf("unexpected output %s", diff.PrintWantGot(d))
+	}
+}
+
+func TestShow(t *testing.T) {
+	c, _, out := newTestCLI(newRequest("done", "git", stateSucceeded, time.Hour))
+
+	if err := c.run(context.Background(), []string{"show", "done"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := `Name:      done
+Namespace: default
+Resolver:  git
+Age:       1h
+State:     Succeeded
+Params:
+  url: https://example.com/repo.git
+Annotations:
+  revision: main
+Data:
+kind: Task
+`
+	if d := cmp.Diff(expected, out.String()); d != "" {
+		t.Errorf("unexpected output %s", diff.PrintWantGot(d))
+	}
+}
+
+func TestList(t *testing.T) {
+	objs := []*v1alpha1.ResolutionRequest{
+		newRequest("a", "git", stateSucceeded, 90*time.Second),
+		newRequest("b", "bundles", stateFailed, 3*time.Hour),
+		newRequest("c", "git", stateInProgress, 72*time.Hour),
+	}
+	for _, tc := range []struct {
+		name     string
+		args     []string
+		expected string
+	}{{
+		name: "all",
+		args: []string{"list"},
+		expected: `NAME   RESOLVER   STATE        AGE
+a      git        Succeeded    1m
+b      bundles    Failed       3h
+c      git        InProgress   3d
+`,
+	}, {
+		name: "by type",
+		args: []string{"list", "-type", "git"},
+		expected: `NAME   RESOLVER   STATE        AGE
+a      git        Succeeded    1m
+c      git        InProgress   3d
+`,
+	}, {
+		name: "by state",
+		args: []string{"list", "-state", stateFailed},
+		expected: `NAME   RESOLVER   STATE    AGE
+b      bundles    Failed   3h
+`,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, _, out := newTestCLI(objs...)
+			if err := c.run(context.Background(), tc.args); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if d := cmp.Diff(tc.expected, out.String()); d != "" {
+				t.Errorf("unexpected output %s", diff.PrintWantGot(d))
+			}
+		})
+	}
+}
+
+func TestListInvalidState(t *testing.T) {
+	c, _, _ := newTestCLI()
+	if err := c.run(context.Background(), []string{"list", "-state", "Done"}); err == nil {
+		t.Fatalf("expected error for invalid state")
+	}
+}
+
+func TestClean(t *testing.T) {
+	c, clientset, out := newTestCLI(
+		newRequest("old-succeeded", "git", stateSucceeded, 48*time.Hour),
+		newRequest("old-failed", "git", stateFailed, 48*time.Hour),
+		newRequest("old-in-progress", "git", stateInProgress, 48*time.Hour),
+		newRequest("new-succeeded", "git", stateSucceeded, time.Hour),
+	)
+
+	if err := c.run(context.Background(), []string{"clean", "-older-than", "24h"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "resolutionrequest/old-failed deleted\nresolutionrequest/old-succeeded deleted\n"
+	if d := cmp.Diff(expected, out.String()); d != "" {
+		t.Errorf("unexpected output %s", diff.PrintWantGot(d))
+	}
+	list, err := clientset.ResolutionV1alpha1().ResolutionRequests("default").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error listing requests: %v", err)
+	}
+	remaining := []string{}
+	for _, rr := range list.Items {
+		remaining = append(remaining, rr.Name)
+	}
+	if d := cmp.Diff([]string{"new-succeeded", "old-in-progress"}, remaining); d != "" {
+		t.Errorf("unexpected remaining requests %s", diff.PrintWantGot(d))
+	}
+}
+
+func TestCleanDryRun(t *testing.T) {
+	c, clientset, out := newTestCLI(newRequest("old", "git", stateSucceeded, 48*time.Hour))
+
+	if err := c.run(context.Background(), []string{"clean", "-dry-run"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d := cmp.Diff("resolutionrequest/old would be deleted\n", out.String()); d != "" {
+		t.Errorf("unexpected output %s", diff.PrintWantGot(d))
+	}
+	if _, err := clientset.ResolutionV1alpha1().ResolutionRequests("default").Get(context.Background(), "old", metav1.GetOptions{}); err != nil {
+		t.Errorf("expected request not to be deleted: %v", err)
+	}
+}
+
+func TestUnknownCommand(t *testing.T) {
+	c, _, _ := newTestCLI()
+	if err := c.run(context.Background(), []string{"frobnicate"}); err == nil {
+		t.Fatalf("expected error for unknown command")
+	}
+}
+
+// newTestCLI returns a cli for the default namespace backed by a fake
+// clientset holding objs.
+func newTestCLI(objs ...*v1alpha1.ResolutionRequest) (*cli, *fake.Clientset, *bytes.Buffer) {
+	clientset := fake.NewSimpleClientset()
+	for _, obj := range objs {
+		_ = clientset.Tracker().Add(obj)
+	}
+	out := &bytes.Buffer{}
+	return &cli{
+		clientset: clientset,
+		namespace: "default",
+		out:       out,
+		now:       func() time.Time { return now },
+	}, clientset, out
+}
+
+// newRequest returns a ResolutionRequest for the given resolver that is
+// in the given state and was created age ago.
+func newRequest(name, resolverType, state string, age time.Duration) *v1alpha1.ResolutionRequest {
+	rr := &v1alpha1.ResolutionRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "default",
+			CreationTimestamp: metav1.NewTime(now.Add(-age)),
+			Labels: map[string]string{
+				resolutioncommon.LabelKeyResolverType: resolverType,
+			},
+		},
+		Spec: v1alpha1.ResolutionRequestSpec{
+			Parameters: map[string]string{"url": "https://example.com/repo.git"},
+		},
+	}
+	switch state {
+	case stateSucceeded:
+		rr.Status.Annotations = map[string]string{"revision": "main"}
+		rr.Status.Data = base64.StdEncoding.EncodeToString([]byte("kind: Task"))
+		rr.Status.MarkSucceeded()
+	case stateFailed:
+		rr.Status.MarkFailed(resolutioncommon.ReasonResolutionFailed, "not found")
+	default:
+		rr.Status.InitializeConditions()
+	}
+	return rr
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// resolutionctl creates, waits on and inspects the ResolutionRequests
+// in a cluster, saving users from decoding their status by hand.
+//
+// Usage:
+//
+//	resolutionctl [-kubeconfig path] [-namespace ns] <command> [flags]
+//
+// The commands are:
+//
+//	submit  create a ResolutionRequest, optionally waiting for its result
+//	wait    wait for an existing ResolutionRequest to complete
+//	show    print a ResolutionRequest's status, annotations and data
+//	list    list ResolutionRequests, optionally by resolver and state
+//	clean   delete completed ResolutionRequests older than a given age
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	rrclient "github.com/tektoncd/resolution/pkg/client/clientset/versioned"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// cli holds what every command needs to talk to the cluster.
+type cli struct {
+	clientset rrclient.Interface
+	namespace string
+	out       io.Writer
+	now       func() time.Time
+}
+
+// command is a single resolutionctl subcommand.
+type command struct {
+	usage string
+	run   func(c *cli, ctx context.Context, args []string) error
+}
+
+var commands = map[string]command{
+	"submit": {usage: "create a ResolutionRequest, optionally waiting for its result", run: (*cli).submit},
+	"wait":   {usage: "wait for an existing ResolutionRequest to complete", run: (*cli).wait},
+	"show":   {usage: "print a ResolutionRequest's status, annotations and data", run: (*cli).show},
+	"list":   {usage: "list ResolutionRequests, optionally by resolver and state", run: (*cli).list},
+	"clean":  {usage: "delete completed ResolutionRequests older than a given age", run: (*cli).clean},
+}
+
+func main() {
+	kubeconfig := flag.String("kubeconfig", "", "path to a kubeconfig, defaults to the usual kubectl rules")
+	namespace := flag.String("namespace", "", "the namespace to use, defaults to the kubeconfig's current namespace")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	c, err := newCLI(*kubeconfig, *namespace)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := c.run(context.Background(), flag.Args()); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <command> [command flags]\n\nCommands:\n", os.Args[0])
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(flag.CommandLine.Output(), "  %-8s%s\n", name, commands[name].usage)
+	}
+	fmt.Fprintf(flag.CommandLine.Output(), "\nFlags:\n")
+	flag.PrintDefaults()
+}
+
+// newCLI loads a kubeconfig the same way kubectl does and returns a cli
+// that uses it.
+func newCLI(kubeconfig, namespace string) (*cli, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = kubeconfig
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{})
+
+	if namespace == "" {
+		var err error
+		if namespace, _, err = clientConfig.Namespace(); err != nil {
+			return nil, fmt.Errorf("error getting namespace from kubeconfig: %w", err)
+		}
+	}
+	restConfig, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("error loading kubeconfig: %w", err)
+	}
+	clientset, err := rrclient.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error creating client: %w", err)
+	}
+	return &cli{
+		clientset: clientset,
+		namespace: namespace,
+		out:       os.Stdout,
+		now:       time.Now,
+	}, nil
+}
+
+// run runs the command named by the first of args with the rest of
+// args as its flags.
+func (c *cli) run(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New("no command given")
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+	return cmd.run(c, ctx, args[1:])
+}
+
+// nameArg returns the single ResolutionRequest name a command was
+// given after its flags.
+func nameArg(fs *flag.FlagSet) (string, error) {
+	if fs.NArg() != 1 {
+		return "", fmt.Errorf("%s takes exactly one ResolutionRequest name", fs.Name())
+	}
+	return fs.Arg(0), nil
+}
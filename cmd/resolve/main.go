@@ -0,0 +1,154 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// resolve runs one of the built-in resolvers locally, without a
+// cluster, and prints the resource it resolves. It is useful for
+// checking what a resolver will return for a set of params, e.g. when
+// debugging a pipeline or linting pipeline references in CI.
+//
+// Usage:
+//
+//	resolve -type git -param url=https://github.com/tektoncd/catalog.git \
+//	    -param pathInRepo=task/git-clone/0.6/git-clone.yaml -param revision=main
+//
+// The resolved resource is printed to stdout, preceded by its
+// annotations as YAML comments.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/tektoncd/resolution/bundleresolver/pkg/bundle"
+	"github.com/tektoncd/resolution/gitresolver/pkg/git"
+	"github.com/tektoncd/resolution/hubresolver/pkg/hub"
+	"github.com/tektoncd/resolution/internal/cmdutil"
+	"github.com/tektoncd/resolution/pkg/resolver/framework"
+	"github.com/tektoncd/resolution/pkg/resource"
+	"github.com/tektoncd/resolution/pkg/resource/direct"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// options are the settings for a single run of the CLI.
+type options struct {
+	resolverType string
+	params       map[string]string
+	configPath   string
+	namespace    string
+	hubAPI       string
+	timeout      time.Duration
+}
+
+func main() {
+	opts := options{params: cmdutil.ParamFlags{}}
+	flag.StringVar(&opts.resolverType, "type", "", "the type of resolver to use: git, bundles or hub")
+	flag.Var(cmdutil.ParamFlags(opts.params), "param", "a key=value param to pass to the resolver, may be repeated")
+	flag.StringVar(&opts.configPath, "config", "", "path to a file standing in for the resolver's ConfigMap, either a ConfigMap manifest or a map of keys to values")
+	flag.StringVar(&opts.namespace, "namespace", "default", "the namespace the request appears to come from")
+	flag.StringVar(&opts.hubAPI, "hub-api", os.Getenv("HUB_API"), "the base URL of a custom Tekton Hub API for the hub resolver")
+	flag.DurationVar(&opts.timeout, "timeout", time.Minute, "how long to wait for the resolver")
+	flag.Parse()
+
+	if err := run(context.Background(), os.Stdout, opts); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run resolves the resource described by opts and writes it to out.
+func run(ctx context.Context, out io.Writer, opts options) error {
+	if opts.resolverType == "" {
+		return fmt.Errorf("-type is required")
+	}
+	conf := map[string]string{}
+	if opts.configPath != "" {
+		var err error
+		if conf, err = loadConfig(opts.configPath); err != nil {
+			return err
+		}
+	}
+	if _, ok := conf[bundle.ConfigServiceAccount]; !ok {
+		// Local credentials are used in place of a service
+		// account, but the bundle resolver insists on one.
+		conf[bundle.ConfigServiceAccount] = "default"
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, opts.timeout)
+	defer cancel()
+
+	requester, err := direct.NewRequester(ctx,
+		&git.Resolver{},
+		&localBundleResolver{},
+		&hub.Resolver{HubURL: cmdutil.HubURL(opts.hubAPI)},
+	)
+	if err != nil {
+		return err
+	}
+	resolverName := resource.ResolverName(opts.resolverType)
+	requester.SetConfig(resolverName, conf)
+
+	resolved, err := requester.Submit(ctx, resolverName, resource.NewRequest("local", opts.namespace, opts.params))
+	if err != nil {
+		return err
+	}
+	return cmdutil.WriteResolved(out, resolved)
+}
+
+// loadConfig reads a resolver's config from a local file. The file can
+// either be a ConfigMap manifest, like the ones in each resolver's
+// config directory, or a plain map of keys to values.
+func loadConfig(path string) (map[string]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file: %w", err)
+	}
+	cm := &corev1.ConfigMap{}
+	if err := yaml.Unmarshal(content, cm); err == nil && cm.Kind == "ConfigMap" {
+		return framework.DataFromConfigMap(cm)
+	}
+	conf := map[string]string{}
+	if err := yaml.Unmarshal(content, &conf); err != nil {
+		return nil, fmt.Errorf("error parsing config file %q: %w", path, err)
+	}
+	return conf, nil
+}
+
+// localBundleResolver is a bundle resolver that pulls images with the
+// local user's registry credentials, e.g. from their docker config,
+// rather than those of a service account in a cluster.
+type localBundleResolver struct {
+	bundle.Resolver
+}
+
+// Initialize does nothing since no cluster clients are needed.
+func (r *localBundleResolver) Initialize(context.Context) error {
+	return nil
+}
+
+// Resolve fetches the requested bundle entry using local credentials.
+func (r *localBundleResolver) Resolve(ctx context.Context, params map[string]string) (framework.ResolvedResource, error) {
+	opts, err := bundle.OptionsFromParams(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	return bundle.GetEntry(ctx, authn.DefaultKeychain, opts)
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tektoncd/resolution/test/diff"
+)
+
+func TestLoadConfig(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		content  string
+		expected map[string]string
+	}{{
+		name: "configmap manifest",
+		content: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: hubresolver-config
+data:
+  default-catalog: Tekton
+  default-kind: task
+`,
+		expected: map[string]string{"default-catalog": "Tekton", "default-kind": "task"},
+	}, {
+		name:     "plain map",
+		content:  "default-catalog: Tekton\n",
+		expected: map[string]string{"default-catalog": "Tekton"},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(tc.content), 0600); err != nil {
+				t.Fatalf("writing config: %v", err)
+			}
+			conf, err := loadConfig(path)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if d := cmp.Diff(tc.expected, conf); d != "" {
+				t.Errorf("unexpected config %s", diff.PrintWantGot(d))
+			}
+		})
+	}
+}
+
+func TestRunHub(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/resource/Tekton/task/foo/0.1/yaml" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"data":{"yaml":"kind: Task\nmetadata:\n  name: foo"}}`)
+	}))
+	defer svr.Close()
+
+	out := &bytes.Buffer{}
+	err := run(context.Background(), out, options{
+		resolverType: "hub",
+		params: map[string]string{
+			"catalog": "Tekton",
+			"kind":    "task",
+			"name":    "foo",
+			"version": "0.1",
+		},
+		namespace: "default",
+		hubAPI:    svr.URL,
+		timeout:   10 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "kind: Task\nmetadata:\n  name: foo\n"
+	if d := cmp.Diff(expected, out.String()); d != "" {
+		t.Errorf("unexpected output %s", diff.PrintWantGot(d))
+	}
+}
+
+func TestRunUnknownType(t *testing.T) {
+	err := run(context.Background(), &bytes.Buffer{}, options{resolverType: "other", timeout: time.Second})
+	if err == nil {
+		t.Fatalf("expected error for unknown resolver type")
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"context"
 
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	kubeclient "knative.dev/pkg/client/injection/kube/client"
 	"knative.dev/pkg/configmap"
 	"knative.dev/pkg/controller"
 	"knative.dev/pkg/injection/sharedmain"
@@ -34,18 +35,24 @@ import (
 	"knative.dev/pkg/webhook/resourcesemantics/validation"
 
 	"github.com/tektoncd/resolution/pkg/apis/resolution/v1alpha1"
+	"github.com/tektoncd/resolution/pkg/policy"
+	"github.com/tektoncd/resolution/pkg/resolver/framework"
 )
 
+var resolutionRequestKind = v1alpha1.SchemeGroupVersion.WithKind("ResolutionRequest")
+
 var types = map[schema.GroupVersionKind]resourcesemantics.GenericCRD{
 	// List the types to validate.
-	v1alpha1.SchemeGroupVersion.WithKind("ResolutionRequest"): &v1alpha1.ResolutionRequest{},
+	resolutionRequestKind: &v1alpha1.ResolutionRequest{},
 }
 
-var callbacks = map[schema.GroupVersionKind]validation.Callback{}
-
 // NewDefaultingAdmissionController returns the defaulting webhook's
 // controller.
 func NewDefaultingAdmissionController(ctx context.Context, cmw configmap.Watcher) *controller.Impl {
+	store := policy.NewStore(logging.FromContext(ctx).Named("policy-store"))
+	store.WatchConfigs(cmw)
+	admission := &policy.Admission{KubeClient: kubeclient.Get(ctx)}
+
 	return defaulting.NewAdmissionController(ctx,
 
 		// Name of the resource webhook.
@@ -58,20 +65,25 @@ func NewDefaultingAdmissionController(ctx context.Context, cmw configmap.Watcher
 		types,
 
 		// A function that infuses the context passed to Validate/SetDefaults with custom metadata.
-		func(ctx context.Context) context.Context {
-			// Here is where you would infuse the context with state
-			// (e.g. attach a store with configmap data)
-			return ctx
-		},
+		store.ToContext,
 
 		// Whether to disallow unknown fields.
 		true,
+
+		// Extra defaulting callbacks to be applied to resources.
+		map[schema.GroupVersionKind]defaulting.Callback{
+			resolutionRequestKind: defaulting.NewCallback(admission.Annotate, webhook.Create),
+		},
 	)
 }
 
 // NewValidationAdmissionController returns the validating webhook's
 // controller.
 func NewValidationAdmissionController(ctx context.Context, cmw configmap.Watcher) *controller.Impl {
+	store := policy.NewStore(logging.FromContext(ctx).Named("policy-store"))
+	store.WatchConfigs(cmw)
+	admission := &policy.Admission{KubeClient: kubeclient.Get(ctx)}
+
 	return validation.NewAdmissionController(ctx,
 
 		// Name of the resource webhook.
@@ -84,17 +96,15 @@ func NewValidationAdmissionController(ctx context.Context, cmw configmap.Watcher
 		types,
 
 		// A function that infuses the context passed to Validate/SetDefaults with custom metadata.
-		func(ctx context.Context) context.Context {
-			// Here is where you would infuse the context with state
-			// (e.g. attach a store with configmap data)
-			return ctx
-		},
+		store.ToContext,
 
 		// Whether to disallow unknown fields.
 		true,
 
 		// Extra validating callbacks to be applied to resources.
-		callbacks,
+		map[schema.GroupVersionKind]validation.Callback{
+			resolutionRequestKind: validation.NewCallback(admission.Validate, webhook.Create),
+		},
 	)
 }
 
@@ -111,8 +121,10 @@ func NewConfigValidationController(ctx context.Context, cmw configmap.Watcher) *
 
 		// The configmaps to validate.
 		configmap.Constructors{
-			logging.ConfigMapName(): logging.NewConfigFromConfigMap,
-			metrics.ConfigMapName(): metrics.NewObservabilityConfigFromConfigMap,
+			logging.ConfigMapName():              logging.NewConfigFromConfigMap,
+			metrics.ConfigMapName():              metrics.NewObservabilityConfigFromConfigMap,
+			policy.ConfigMapName:                 policy.NewPolicyFromConfigMap,
+			framework.ContentPolicyConfigMapName: framework.NewContentRulesFromConfigMap,
 		},
 	)
 }
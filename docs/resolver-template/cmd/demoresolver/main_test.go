@@ -26,7 +26,10 @@ import (
 	ttesting "github.com/tektoncd/resolution/pkg/reconciler/testing"
 	frtesting "github.com/tektoncd/resolution/pkg/resolver/framework/testing"
 	"github.com/tektoncd/resolution/test"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
 	_ "knative.dev/pkg/system/testing"
 )
 
@@ -55,6 +58,14 @@ func TestResolver(t *testing.T) {
 	}
 
 	expectedStatus := &v1alpha1.ResolutionRequestStatus{
+		Status: duckv1.Status{
+			Conditions: duckv1.Conditions{{
+				Type:    apis.ConditionSucceeded,
+				Status:  corev1.ConditionUnknown,
+				Reason:  resolutioncommon.ReasonResolutionInProgress,
+				Message: `resolution in progress by resolver "Demo"`,
+			}},
+		},
 		ResolutionRequestStatusFields: v1alpha1.ResolutionRequestStatusFields{
 			Data: base64.StdEncoding.Strict().EncodeToString([]byte(pipeline)),
 		},
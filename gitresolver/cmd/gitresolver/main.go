@@ -28,6 +28,6 @@ import (
 func main() {
 	ctx := filteredinformerfactory.WithSelectors(signals.NewContext(), v1alpha1.ManagedByLabelKey)
 	sharedmain.MainWithContext(ctx, "controller",
-		framework.NewController(ctx, &git.Resolver{}),
+		framework.NewController(ctx, &git.Resolver{}, framework.WithContentPolicyFromConfigMap(framework.ContentPolicyConfigMapName)),
 	)
 }
@@ -20,4 +20,25 @@ const (
 	// AnnotationKeyRevision is the revision that was fetched
 	// from git
 	AnnotationKeyRevision = "revision"
+
+	// AnnotationKeyFiles lists the files that were resolved from
+	// a directory along with their digests, as a JSON object
+	AnnotationKeyFiles = "files"
+
+	// AnnotationKeySigner is the identity of whoever signed the
+	// commit that was fetched, if its signature was verified
+	AnnotationKeySigner = "signer"
+
+	// AnnotationKeySignerKey is the fingerprint of the key that
+	// signed the commit that was fetched, if its signature was
+	// verified
+	AnnotationKeySignerKey = "signer-key"
+
+	// AnnotationKeyKustomizeOverlay is the directory in the repo of
+	// the kustomization that was rendered, if kustomize was used
+	AnnotationKeyKustomizeOverlay = "kustomize-overlay"
+
+	// AnnotationKeyKustomizeVersion is the version of kustomize that
+	// the kustomization was rendered with, if kustomize was used
+	AnnotationKeyKustomizeVersion = "kustomize-version"
 )
@@ -0,0 +1,463 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/osfs"
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+	"knative.dev/pkg/logging"
+)
+
+// defaultCacheMaxSize is the size in bytes the repository cache is
+// kept under unless the resolver's config says otherwise.
+const defaultCacheMaxSize int64 = 512 * 1024 * 1024
+
+// cacheOptionsFromConfig returns the directory repositories are cached
+// in and the maximum size of the cache. The directory is empty if
+// repositories aren't cached.
+func cacheOptionsFromConfig(conf map[string]string) (string, int64, error) {
+	dir := conf[ConfigCacheDir]
+	maxSize := defaultCacheMaxSize
+	if val, ok := conf[ConfigCacheMaxSize]; ok {
+		size, err := strconv.ParseInt(val, 10, 64)
+		if err != nil || size <= 0 {
+			return "", 0, fmt.Errorf("invalid value %q for %s", val, ConfigCacheMaxSize)
+		}
+		maxSize = size
+	}
+	return dir, maxSize, nil
+}
+
+// repoCache keeps bare clones of remote repositories on disk so that
+// each request only fetches what changed since the last one. The
+// least recently used clones are removed to keep the cache under its
+// maximum size.
+//
+// Requests are only coordinated within a single resolver, so the
+// cache's directory must not be shared by resolvers in different pods.
+type repoCache struct {
+	dir string
+
+	// loaded measures the clones left in dir by an earlier run of the
+	// resolver the first time the cache is used.
+	loaded sync.Once
+
+	// mu guards repos, clones and size. It's never held while clones
+	// are read, written or removed.
+	mu     sync.Mutex
+	repos  map[string]*cachedRepo
+	clones map[string]cloneUsage
+	size   int64
+}
+
+// cachedRepo serializes the requests using a cached clone. A clone is
+// never evicted while it has users.
+type cachedRepo struct {
+	// held has room for one value and holds one while a request or
+	// eviction has the clone, so that waiting for it can give up
+	// when a request's context is done.
+	held  chan struct{}
+	users int
+}
+
+func newCachedRepo() *cachedRepo {
+	return &cachedRepo{held: make(chan struct{}, 1)}
+}
+
+// lock waits until the clone is free and takes it, or returns an error
+// if ctx is done first.
+func (r *cachedRepo) lock(ctx context.Context) error {
+	select {
+	case r.held <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *cachedRepo) unlock() {
+	<-r.held
+}
+
+// cloneUsage records the size of a clone, measured when it was last
+// fetched into, and when it was last used.
+type cloneUsage struct {
+	size     int64
+	lastUsed time.Time
+}
+
+// eviction is a clone claimed for removal from the cache.
+type eviction struct {
+	key   string
+	entry *cachedRepo
+	usage cloneUsage
+}
+
+func newRepoCache(dir string) *repoCache {
+	return &repoCache{
+		dir:    dir,
+		repos:  map[string]*cachedRepo{},
+		clones: map[string]cloneUsage{},
+	}
+}
+
+// open returns the cached clone of url, with anything new fetched from
+// the remote and a worktree in worktree, and a func that must be called
+// once the caller is done with it. Until then other requests for the
+// same url wait.
+func (c *repoCache) open(ctx context.Context, url string, worktree billy.Filesystem, maxSize int64) (*git.Repository, func(), error) {
+	c.load(ctx)
+	key := cacheKey(url)
+	dir := filepath.Join(c.dir, key)
+	entry, err := c.acquire(ctx, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error waiting for cached repository %q: %w", url, err)
+	}
+
+	repository, err := openCachedRepo(ctx, dir, url, worktree)
+	// A clone only grows when it's fetched into, so it's measured
+	// now rather than every time the cache is evicted from.
+	size, sizeErr := dirSize(dir)
+	if sizeErr != nil {
+		logging.FromContext(ctx).Warnf("error measuring cached repository %q: %v", url, sizeErr)
+	}
+	release := func() {
+		c.release(ctx, key, entry, size, maxSize)
+	}
+	if err != nil {
+		release()
+		return nil, nil, err
+	}
+	return repository, release, nil
+}
+
+// load measures the clones already in the cache's directory, once.
+func (c *repoCache) load(ctx context.Context) {
+	c.loaded.Do(func() {
+		dirs, err := os.ReadDir(c.dir)
+		if err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				logging.FromContext(ctx).Warnf("error reading git cache %q: %v", c.dir, err)
+			}
+			return
+		}
+		for _, d := range dirs {
+			if !d.IsDir() {
+				continue
+			}
+			info, err := d.Info()
+			if err != nil {
+				continue
+			}
+			size, err := dirSize(filepath.Join(c.dir, d.Name()))
+			if err != nil {
+				logging.FromContext(ctx).Warnf("error measuring %q in git cache %q: %v", d.Name(), c.dir, err)
+				continue
+			}
+			// The modification time of a clone's directory
+			// records when it was last used.
+			c.mu.Lock()
+			c.record(d.Name(), cloneUsage{size: size, lastUsed: info.ModTime()})
+			c.mu.Unlock()
+		}
+	})
+}
+
+// acquire waits for the clone in key to be free and takes it, unless
+// ctx is done first.
+func (c *repoCache) acquire(ctx context.Context, key string) (*cachedRepo, error) {
+	c.mu.Lock()
+	entry, ok := c.repos[key]
+	if !ok {
+		entry = newCachedRepo()
+		c.repos[key] = entry
+	}
+	entry.users++
+	c.mu.Unlock()
+
+	if err := entry.lock(ctx); err != nil {
+		c.mu.Lock()
+		entry.users--
+		if entry.users == 0 {
+			delete(c.repos, key)
+		}
+		c.mu.Unlock()
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (c *repoCache) release(ctx context.Context, key string, entry *cachedRepo, size, maxSize int64) {
+	// The modification time of a clone's directory records when it
+	// was last used, so that eviction picks up where it left off if
+	// the cache outlives the resolver.
+	now := time.Now()
+	_ = os.Chtimes(filepath.Join(c.dir, key), now, now)
+	entry.unlock()
+
+	c.mu.Lock()
+	c.record(key, cloneUsage{size: size, lastUsed: now})
+	entry.users--
+	if entry.users == 0 {
+		delete(c.repos, key)
+	}
+	evictions := c.claimEvictions(maxSize)
+	c.mu.Unlock()
+
+	c.evict(ctx, evictions)
+}
+
+// record sets the usage of the clone in key, or forgets it if its
+// size is 0. c.mu must be held.
+func (c *repoCache) record(key string, usage cloneUsage) {
+	c.size -= c.clones[key].size
+	if usage.size == 0 {
+		delete(c.clones, key)
+		return
+	}
+	c.clones[key] = usage
+	c.size += usage.size
+}
+
+// claimEvictions picks the least recently used clones that aren't in
+// use to remove until the cache is no larger than maxSize. Each is
+// claimed like a request would, so that nothing uses it while it's
+// removed, and no longer counts towards the size of the cache. c.mu
+// must be held.
+func (c *repoCache) claimEvictions(maxSize int64) []eviction {
+	if c.size <= maxSize {
+		return nil
+	}
+	keys := make([]string, 0, len(c.clones))
+	for key := range c.clones {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return c.clones[keys[i]].lastUsed.Before(c.clones[keys[j]].lastUsed)
+	})
+	evictions := []eviction{}
+	for _, key := range keys {
+		if c.size <= maxSize {
+			break
+		}
+		if _, inUse := c.repos[key]; inUse {
+			continue
+		}
+		entry := newCachedRepo()
+		entry.users = 1
+		// A new entry is free, so this doesn't block.
+		entry.held <- struct{}{}
+		c.repos[key] = entry
+		evictions = append(evictions, eviction{key: key, entry: entry, usage: c.clones[key]})
+		c.record(key, cloneUsage{})
+	}
+	return evictions
+}
+
+// evict removes clones claimed by claimEvictions. Requests for them
+// wait until they're gone and then clone them again.
+func (c *repoCache) evict(ctx context.Context, evictions []eviction) {
+	for _, e := range evictions {
+		dir := filepath.Join(c.dir, e.key)
+		if err := os.RemoveAll(dir); err != nil {
+			logging.FromContext(ctx).Warnf("error evicting %q from git cache %q: %v", e.key, c.dir, err)
+		}
+
+		// Whatever couldn't be removed still takes up space.
+		size, err := dirSize(dir)
+
+		c.mu.Lock()
+		if err == nil && size > 0 {
+			c.record(e.key, cloneUsage{size: size, lastUsed: e.usage.lastUsed})
+		}
+		e.entry.users--
+		if e.entry.users == 0 {
+			delete(c.repos, e.key)
+		}
+		c.mu.Unlock()
+		e.entry.unlock()
+	}
+}
+
+// openCachedRepo opens the clone of url in dir and fetches into it,
+// cloning afresh if there's no clone yet or the existing one can't be
+// used.
+func openCachedRepo(ctx context.Context, dir, url string, worktree billy.Filesystem) (*git.Repository, error) {
+	repository, err := git.Open(cacheStorage(dir), worktree)
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		return cloneCachedRepo(ctx, dir, url, worktree)
+	}
+	if err == nil {
+		// The index left by the last request describes a worktree
+		// that's gone, so start from an empty one.
+		err = repository.Storer.SetIndex(&index.Index{Version: 2})
+	}
+	if err == nil {
+		if err = fetchCachedRepo(ctx, repository); err == nil {
+			return repository, nil
+		}
+	}
+	if ctx.Err() != nil {
+		return nil, err
+	}
+	// The clone may have been corrupted, for example by the resolver
+	// stopping part way through writing to it, so start over rather
+	// than fail every request for the repository from now on.
+	logging.FromContext(ctx).Warnf("recloning cached repository %q after error: %v", url, err)
+	return cloneCachedRepo(ctx, dir, url, worktree)
+}
+
+// cloneCachedRepo replaces whatever is in dir with a bare clone of url.
+func cloneCachedRepo(ctx context.Context, dir, url string, worktree billy.Filesystem) (*git.Repository, error) {
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, err
+	}
+	bare, err := git.Init(cacheStorage(dir), nil)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := bare.CreateRemote(&config.RemoteConfig{
+		Name: git.DefaultRemoteName,
+		URLs: []string{url},
+	}); err != nil {
+		return nil, err
+	}
+	repository, err := git.Open(cacheStorage(dir), worktree)
+	if err == nil {
+		err = fetchCachedRepo(ctx, repository)
+	}
+	if err != nil {
+		// Don't leave a partial clone taking up space in the cache.
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+	return repository, nil
+}
+
+// fetchCachedRepo brings every branch and tag of a cached clone up to
+// date with its remote.
+func fetchCachedRepo(ctx context.Context, repository *git.Repository) error {
+	err := repository.FetchContext(ctx, &git.FetchOptions{
+		Tags:  git.AllTags,
+		Force: true,
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return err
+	}
+	return pruneCachedRepo(ctx, repository)
+}
+
+// pruneCachedRepo removes the branches and tags of a cached clone that
+// its remote no longer has. go-git can't prune when it fetches, so
+// without this a deleted branch or tag would go on resolving to the
+// commit it last pointed at.
+func pruneCachedRepo(ctx context.Context, repository *git.Repository) error {
+	remote, err := repository.Remote(git.DefaultRemoteName)
+	if err != nil {
+		return err
+	}
+	remoteRefs, err := remote.ListContext(ctx, &git.ListOptions{})
+	if err != nil {
+		return err
+	}
+	live := map[plumbing.ReferenceName]bool{}
+	for _, ref := range remoteRefs {
+		live[ref.Name()] = true
+	}
+
+	refs, err := repository.References()
+	if err != nil {
+		return err
+	}
+	remoteBranchPrefix := "refs/remotes/" + git.DefaultRemoteName + "/"
+	stale := []plumbing.ReferenceName{}
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		name := ref.Name()
+		switch {
+		case name.IsTag():
+			if !live[name] {
+				stale = append(stale, name)
+			}
+		case strings.HasPrefix(name.String(), remoteBranchPrefix):
+			branch := strings.TrimPrefix(name.String(), remoteBranchPrefix)
+			if branch != "HEAD" && !live[plumbing.NewBranchReferenceName(branch)] {
+				stale = append(stale, name)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, name := range stale {
+		if err := repository.Storer.RemoveReference(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func cacheStorage(dir string) *filesystem.Storage {
+	return filesystem.NewStorage(osfs.New(dir), cache.NewObjectLRUDefault())
+}
+
+// cacheKey returns the name of the directory the clone of url is
+// cached in.
+func cacheKey(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:])
+}
+
+// dirSize returns the total size of the files under dir. Files that
+// disappear while it runs, such as those a fetch writes to temporarily,
+// are ignored.
+func dirSize(dir string) (int64, error) {
+	size := int64(0)
+	err := filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err == nil && d.Type().IsRegular() {
+			var info fs.FileInfo
+			if info, err = d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	})
+	return size, err
+}
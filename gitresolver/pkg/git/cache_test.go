@@ -0,0 +1,224 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/tektoncd/resolution/pkg/resolver/framework"
+)
+
+func TestResolveWithCache(t *testing.T) {
+	withTemporaryGitConfig(t)
+	repoPath, _ := createTestRepo(t, []commitForRepo{{
+		Dir:      "foo",
+		Filename: "bar.yaml",
+		Content:  "first",
+	}})
+	cacheDir := t.TempDir()
+	resolver := &Resolver{}
+	ctx := framework.InjectResolverConfigToContext(context.Background(), map[string]string{
+		ConfigRevision:          plumbing.Master.Short(),
+		ConfigCacheDir:          cacheDir,
+		ConfigAllowedURLSchemes: "file",
+	})
+	params := map[string]string{
+		URLParam:  repoPath,
+		PathParam: "foo/bar.yaml",
+	}
+	expectContent := func(expected string) {
+		t.Helper()
+		output, err := resolver.Resolve(ctx, params)
+		if err != nil {
+			t.Fatalf("unexpected error resolving: %v", err)
+		}
+		if string(output.Data()) != expected {
+			t.Fatalf("expected content %q, got %q", expected, output.Data())
+		}
+	}
+
+	expectContent("first")
+
+	// New commits are fetched into the cached clone.
+	repo, err := git.PlainOpen(repoPath)
+	if err != nil {
+		t.Fatalf("error opening test repo: %v", err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("error getting test worktree: %v", err)
+	}
+	writeAndCommitToTestRepo(t, worktree, repoPath, "foo", "bar.yaml", []byte("second"))
+	expectContent("second")
+
+	entries, err := os.ReadDir(cacheDir)
+	if err != nil {
+		t.Fatalf("error reading cache dir: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != cacheKey(repoPath) {
+		t.Fatalf("expected only the clone of %q in the cache, got %v", repoPath, entries)
+	}
+
+	// A clone that can't be opened is replaced.
+	if err := ioutil.WriteFile(filepath.Join(cacheDir, cacheKey(repoPath), "config"), []byte("[core"), 0600); err != nil {
+		t.Fatalf("error corrupting cached clone: %v", err)
+	}
+	expectContent("second")
+}
+
+func TestResolveWithCacheDeletedRefs(t *testing.T) {
+	withTemporaryGitConfig(t)
+	repoPath, _ := createTestRepo(t, []commitForRepo{{
+		Dir:      "foo",
+		Filename: "bar.yaml",
+		Content:  "on a branch",
+		Branch:   "feature",
+		Tag:      "v1",
+	}})
+	repo, err := git.PlainOpen(repoPath)
+	if err != nil {
+		t.Fatalf("error opening test repo: %v", err)
+	}
+	cacheDir := t.TempDir()
+	resolver := &Resolver{}
+	ctx := framework.InjectResolverConfigToContext(context.Background(), map[string]string{
+		ConfigCacheDir:          cacheDir,
+		ConfigAllowedURLSchemes: "file",
+	})
+
+	for _, ref := range []plumbing.ReferenceName{
+		plumbing.NewBranchReferenceName("feature"),
+		plumbing.NewTagReferenceName("v1"),
+	} {
+		params := map[string]string{
+			URLParam:      repoPath,
+			PathParam:     "foo/bar.yaml",
+			RevisionParam: ref.Short(),
+		}
+		if _, err := resolver.Resolve(ctx, params); err != nil {
+			t.Fatalf("unexpected error resolving %s: %v", ref, err)
+		}
+		if err := repo.Storer.RemoveReference(ref); err != nil {
+			t.Fatalf("error deleting %s: %v", ref, err)
+		}
+		if _, err := resolver.Resolve(ctx, params); err == nil {
+			t.Errorf("expected error resolving deleted %s from the cache", ref)
+		}
+	}
+}
+
+func TestResolveWithCacheConcurrently(t *testing.T) {
+	withTemporaryGitConfig(t)
+	repoPath, _ := createTestRepo(t, []commitForRepo{{
+		Dir:      "foo",
+		Filename: "bar.yaml",
+		Content:  "some content",
+	}})
+	resolver := &Resolver{}
+	ctx := framework.InjectResolverConfigToContext(context.Background(), map[string]string{
+		ConfigRevision:          plumbing.Master.Short(),
+		ConfigCacheDir:          t.TempDir(),
+		ConfigAllowedURLSchemes: "file",
+	})
+
+	wg := sync.WaitGroup{}
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := resolver.Resolve(ctx, map[string]string{
+				URLParam:  repoPath,
+				PathParam: "foo/bar.yaml",
+			})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("unexpected error resolving: %v", err)
+		}
+	}
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := newRepoCache(t.TempDir())
+	start := time.Now().Add(-time.Hour)
+	for i, key := range []string{"in-use", "oldest", "newest"} {
+		dir := filepath.Join(cache.dir, key)
+		if err := os.Mkdir(dir, 0700); err != nil {
+			t.Fatalf("error creating %q: %v", dir, err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, "pack"), make([]byte, 10), 0600); err != nil {
+			t.Fatalf("error writing to %q: %v", dir, err)
+		}
+		used := start.Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(dir, used, used); err != nil {
+			t.Fatalf("error setting times of %q: %v", dir, err)
+		}
+	}
+	ctx := context.Background()
+	cache.load(ctx)
+	if cache.size != 30 {
+		t.Fatalf("expected the cache to measure 30 bytes, got %d", cache.size)
+	}
+	cache.repos["in-use"] = &cachedRepo{users: 1}
+
+	cache.mu.Lock()
+	evictions := cache.claimEvictions(20)
+	cache.mu.Unlock()
+	if len(evictions) != 1 || evictions[0].key != "oldest" {
+		t.Fatalf("expected only the oldest clone to be evicted, got %v", evictions)
+	}
+	if cache.size != 20 {
+		t.Errorf("expected the claimed clone not to count towards the cache's size, got %d", cache.size)
+	}
+	cache.evict(ctx, evictions)
+	if _, claimed := cache.repos["oldest"]; claimed {
+		t.Error("expected the evicted clone to be released")
+	}
+
+	for key, kept := range map[string]bool{"in-use": true, "oldest": false, "newest": true} {
+		_, err := os.Stat(filepath.Join(cache.dir, key))
+		if exists := err == nil; exists != kept {
+			t.Errorf("expected %q kept to be %t but it was %t", key, kept, exists)
+		}
+	}
+}
+
+func TestCacheAcquireRespectsContext(t *testing.T) {
+	cache := newRepoCache(t.TempDir())
+	entry, err := cache.acquire(context.Background(), "repo")
+	if err != nil {
+		t.Fatalf("unexpected error acquiring free clone: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := cache.acquire(ctx, "repo"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected waiting for a held clone to time out, got %v", err)
+	}
+	if entry.users != 1 {
+		t.Errorf("expected the request that gave up not to count as a user, got %d users", entry.users)
+	}
+
+	entry.unlock()
+	if _, err := cache.acquire(context.Background(), "repo"); err != nil {
+		t.Errorf("unexpected error acquiring released clone: %v", err)
+	}
+}
+
+func TestCacheOptionsFromConfigInvalidMaxSize(t *testing.T) {
+	if _, _, err := cacheOptionsFromConfig(map[string]string{ConfigCacheMaxSize: "lots"}); err == nil {
+		t.Fatalf("expected error for invalid %s", ConfigCacheMaxSize)
+	}
+}
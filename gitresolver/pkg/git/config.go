@@ -11,3 +11,72 @@ const ConfigURL = "default-url"
 // ConfigRevision is the configuration field name for controlling
 // the revision to fetch the remote resource from.
 const ConfigRevision = "default-revision"
+
+// ConfigMaxDirectoryFiles is the configuration field name for
+// controlling the maximum number of files resolved from a directory.
+const ConfigMaxDirectoryFiles = "max-directory-files"
+
+// ConfigVerifySignatures is the configuration field name for requiring
+// that the commits files are resolved from are signed by a trusted key.
+const ConfigVerifySignatures = "verify-signatures"
+
+// ConfigTrustedGPGKeys is the configuration field name for the armored
+// GPG public keys that commits may be signed with.
+const ConfigTrustedGPGKeys = "trusted-gpg-keys"
+
+// ConfigTrustedSSHKeys is the configuration field name for the SSH
+// public keys, in authorized_keys format, that commits may be signed
+// with.
+const ConfigTrustedSSHKeys = "trusted-ssh-keys"
+
+// ConfigTrustedKeysSecret is the configuration field name for a Secret
+// in the resolver's namespace holding more trusted keys under the
+// trusted-gpg-keys and trusted-ssh-keys keys.
+const ConfigTrustedKeysSecret = "trusted-keys-secret"
+
+// ConfigProtectedBranches is the configuration field name for a comma
+// separated list of branches that verified commits must be on.
+const ConfigProtectedBranches = "protected-branches"
+
+// ConfigCacheDir is the configuration field name for the directory
+// that clones of repositories are cached in between requests. Nothing
+// is cached if it isn't set.
+const ConfigCacheDir = "cache-dir"
+
+// ConfigCacheMaxSize is the configuration field name for controlling
+// the size in bytes that the cache of repositories is kept under.
+const ConfigCacheMaxSize = "cache-max-size"
+
+// ConfigAllowedURLSchemes is the configuration field name for a comma
+// separated list of the schemes that repos may be fetched with.
+// Defaults to https, ssh and git.
+const ConfigAllowedURLSchemes = "allowed-url-schemes"
+
+// ConfigAllowedHosts is the configuration field name for a comma
+// separated list of the hosts that repos may be fetched from. Any host
+// not denied is allowed if it isn't set.
+const ConfigAllowedHosts = "allowed-hosts"
+
+// ConfigDeniedHosts is the configuration field name for a comma
+// separated list of the hosts that repos may not be fetched from.
+const ConfigDeniedHosts = "denied-hosts"
+
+// ConfigFetchLFS is the configuration field name for fetching the
+// content of files stored with Git LFS rather than resolving their LFS
+// pointers. It's enabled when set to "true".
+const ConfigFetchLFS = "fetch-lfs"
+
+// ConfigFetchSubmodules is the configuration field name for checking
+// out the submodules that pathInRepo is inside of. It's enabled when
+// set to "true".
+const ConfigFetchSubmodules = "fetch-submodules"
+
+// ConfigEnableKustomize is the configuration field name for allowing
+// requests to resolve the output of a kustomize build with the
+// kustomize param. It's enabled when set to "true".
+const ConfigEnableKustomize = "enable-kustomize"
+
+// ConfigKustomizeAllowRemoteBases is the configuration field name for
+// allowing kustomizations to use bases in other repos. It's enabled
+// when set to "true".
+const ConfigKustomizeAllowRemoteBases = "kustomize-allow-remote-bases"
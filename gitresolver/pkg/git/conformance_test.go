@@ -0,0 +1,49 @@
+package git
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	frtesting "github.com/tektoncd/resolution/pkg/resolver/framework/testing"
+	"github.com/tektoncd/resolution/test/fixtures"
+)
+
+func TestConformance(t *testing.T) {
+	gitServer := fixtures.NewGitServer(t, fixtures.GitRepo{
+		Name: "catalog",
+		Commits: []fixtures.GitCommit{{
+			Files: map[string]string{"foo/bar/somefile": "some content"},
+		}},
+	})
+
+	// A git server that never answers, so that clones only finish
+	// once the resolver gives up.
+	done := make(chan struct{})
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+	defer svr.Close()
+	defer close(done)
+
+	frtesting.RunConformanceTests(context.Background(), t, frtesting.ConformanceSpec{
+		Resolver: &Resolver{},
+		Config: map[string]string{
+			ConfigRevision:          fixtures.DefaultBranch,
+			ConfigAllowedURLSchemes: "http",
+		},
+		ValidParams: map[string]string{
+			URLParam:  gitServer.RepoURL("catalog"),
+			PathParam: "foo/bar/somefile",
+		},
+		RequiredParams: []string{PathParam},
+		BlockingParams: map[string]string{
+			URLParam:  svr.URL + "/repo.git",
+			PathParam: "foo/bar/somefile",
+		},
+	})
+}
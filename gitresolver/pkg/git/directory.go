@@ -0,0 +1,183 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-git/go-billy/v5"
+	resolutioncommon "github.com/tektoncd/resolution/pkg/common"
+	"github.com/tektoncd/resolution/pkg/resolver/framework"
+)
+
+// defaultMaxDirectoryFiles is the number of files that may be resolved
+// from a directory unless the resolver's config says otherwise.
+const defaultMaxDirectoryFiles = 100
+
+// defaultGlobs are the patterns files are matched against when a
+// directory is resolved without a glob param: every YAML file directly
+// inside the directory.
+var defaultGlobs = []string{"*.yaml", "*.yml"}
+
+// directoryOptions control which files are read from a directory and
+// how much may be read.
+type directoryOptions struct {
+	globs    []string
+	maxFiles int
+	maxSize  int64
+
+	// smudge, if set, is given the content of each file read and
+	// returns what to resolve in its place, e.g. the content an LFS
+	// pointer points to.
+	smudge func(content []byte, limit int64) ([]byte, error)
+}
+
+// directoryOptionsFromParams returns the options for resolving a
+// directory with the given params and config.
+func directoryOptionsFromParams(params, conf map[string]string, maxSize int64) (directoryOptions, error) {
+	opts := directoryOptions{
+		globs:    defaultGlobs,
+		maxFiles: defaultMaxDirectoryFiles,
+		maxSize:  maxSize,
+	}
+	if glob := params[GlobParam]; glob != "" {
+		opts.globs = []string{glob}
+	}
+	if val, ok := conf[ConfigMaxDirectoryFiles]; ok {
+		maxFiles, err := strconv.Atoi(val)
+		if err != nil || maxFiles <= 0 {
+			return opts, fmt.Errorf("invalid value %q for %s", val, ConfigMaxDirectoryFiles)
+		}
+		opts.maxFiles = maxFiles
+	}
+	return opts, nil
+}
+
+// readDirectory returns the files under dir that match opts, in order
+// of their paths, joined into a multi-document YAML stream. It also
+// returns the sha256 digest of each file keyed by its path in the repo.
+func readDirectory(filesystem billy.Filesystem, dir string, opts directoryOptions) ([]byte, map[string]string, error) {
+	depth := 1
+	for _, glob := range opts.globs {
+		if d := strings.Count(glob, "/") + 1; d > depth {
+			depth = d
+		}
+	}
+	var matches []string
+	if err := matchFiles(filesystem, dir, "", depth, opts.globs, &matches); err != nil {
+		return nil, nil, err
+	}
+	if len(matches) == 0 {
+		return nil, nil, fmt.Errorf("no files in directory %q match %s", dir, strings.Join(opts.globs, " or "))
+	}
+	if len(matches) > opts.maxFiles {
+		return nil, nil, fmt.Errorf("directory %q has %d matching files, more than the limit of %d", dir, len(matches), opts.maxFiles)
+	}
+	sort.Strings(matches)
+
+	buf := &bytes.Buffer{}
+	digests := map[string]string{}
+	for _, match := range matches {
+		repoPath := strings.TrimPrefix(path.Join(dir, match), "/")
+		if buf.Len() > 0 {
+			buf.WriteString("---\n")
+		}
+		remaining := int64(0)
+		if opts.maxSize > 0 {
+			remaining = opts.maxSize - int64(buf.Len())
+			if remaining <= 0 {
+				return nil, nil, framework.NewErrorResolvedDataTooLarge(opts.maxSize)
+			}
+		}
+		content, err := readFile(filesystem, path.Join(dir, match), remaining)
+		if err == nil && opts.smudge != nil {
+			content, err = opts.smudge(content, remaining)
+		}
+		if err != nil {
+			var reasoned *resolutioncommon.Error
+			if errors.As(err, &reasoned) {
+				if reasoned.Reason == resolutioncommon.ReasonResolvedDataTooLarge {
+					// Report the limit on the whole directory rather
+					// than what was left of it for this file.
+					return nil, nil, framework.NewErrorResolvedDataTooLarge(opts.maxSize)
+				}
+				return nil, nil, err
+			}
+			return nil, nil, fmt.Errorf("error reading file %q: %w", repoPath, err)
+		}
+		buf.Write(content)
+		if len(content) > 0 && content[len(content)-1] != '\n' {
+			buf.WriteByte('\n')
+		}
+		digest := sha256.Sum256(content)
+		digests[repoPath] = "sha256:" + hex.EncodeToString(digest[:])
+	}
+	if opts.maxSize > 0 && int64(buf.Len()) > opts.maxSize {
+		return nil, nil, framework.NewErrorResolvedDataTooLarge(opts.maxSize)
+	}
+	return buf.Bytes(), digests, nil
+}
+
+// matchFiles appends the paths, relative to dir, of the regular files
+// under dir/rel that match any of globs. It descends no deeper than the
+// globs can match.
+func matchFiles(filesystem billy.Filesystem, dir, rel string, depth int, globs []string, matches *[]string) error {
+	infos, err := filesystem.ReadDir(path.Join(dir, rel))
+	if err != nil {
+		return fmt.Errorf("error reading directory %q: %w", path.Join(dir, rel), err)
+	}
+	for _, info := range infos {
+		name := path.Join(rel, info.Name())
+		if info.IsDir() {
+			if depth > 1 {
+				if err := matchFiles(filesystem, dir, name, depth-1, globs, matches); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		for _, glob := range globs {
+			if ok, _ := path.Match(glob, name); ok {
+				*matches = append(*matches, name)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// readFile reads a file, stopping once more than limit bytes have been
+// read. A limit of 0 reads the whole file.
+func readFile(filesystem billy.Filesystem, name string, limit int64) ([]byte, error) {
+	f, err := filesystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return framework.ReadAllWithLimit(f, limit)
+}
@@ -0,0 +1,466 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"sigs.k8s.io/kustomize/api/konfig"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/yaml"
+)
+
+// kustomizeModule is the module kustomize is built from, whose version
+// is given in the kustomize-version annotation.
+const kustomizeModule = "sigs.k8s.io/kustomize/api"
+
+// maxKustomizeRemoteBases is the number of remote bases that may be
+// fetched to render a single kustomization.
+const maxKustomizeRemoteBases = 10
+
+const (
+	// kustomizeRepoDir is where the repo being resolved from is put
+	// in the filesystem kustomize builds from.
+	kustomizeRepoDir = "/repo"
+
+	// kustomizeRemoteDir is where remote bases are put in the
+	// filesystem kustomize builds from, each in a numbered
+	// directory.
+	kustomizeRemoteDir = "/remote"
+)
+
+// remoteBasePrefixes are the prefixes that kustomize takes to mean a
+// base is in a remote git repo.
+var remoteBasePrefixes = []string{"git::", "gh:", "ssh://", "https://", "http://", "git@", "github.com:", "github.com/"}
+
+// kustomizeMu is held while kustomize builds. Kustomize keeps the
+// OpenAPI schema a build uses in a package variable, so two builds
+// can't safely run at once.
+var kustomizeMu sync.Mutex
+
+// kustomizeOptions control how a kustomization is rendered.
+type kustomizeOptions struct {
+	// allowRemoteBases is set if bases may be fetched from other
+	// repos. Their URLs must be allowed by conf like any other.
+	allowRemoteBases bool
+	conf             map[string]string
+}
+
+// checkKustomizeEnabled returns an error unless the resolver's config
+// allows kustomizations to be rendered.
+func checkKustomizeEnabled(conf map[string]string) error {
+	if conf[ConfigEnableKustomize] != "true" {
+		return fmt.Errorf("%s is not enabled: set %s to \"true\" in the resolver's config to use it", KustomizeParam, ConfigEnableKustomize)
+	}
+	return nil
+}
+
+// kustomizeOptionsFromConfig returns the options for rendering a
+// kustomization with the given config.
+func kustomizeOptionsFromConfig(conf map[string]string) kustomizeOptions {
+	return kustomizeOptions{
+		allowRemoteBases: conf[ConfigKustomizeAllowRemoteBases] == "true",
+		conf:             conf,
+	}
+}
+
+// renderKustomization runs a kustomize build of the kustomization in
+// dir of source and returns the resources it renders as a multi-document
+// YAML stream. The build runs in-process on an in-memory copy of
+// source. Only kustomize's builtin generators and transformers are
+// run, files can only be loaded from inside the kustomization's
+// directory and remote bases are fetched with go-git, if they're
+// allowed at all.
+func renderKustomization(ctx context.Context, source billy.Filesystem, dir string, opts kustomizeOptions) ([]byte, error) {
+	fSys := filesys.MakeFsInMemory()
+	if err := copyToKustomizeFS(source, "/", fSys, kustomizeRepoDir); err != nil {
+		return nil, err
+	}
+	root := path.Join(kustomizeRepoDir, dir)
+	if !fSys.IsDir(root) {
+		return nil, fmt.Errorf("%s %q must be a directory holding a kustomization", PathParam, dir)
+	}
+	if _, ok := kustomizationFile(fSys, root); !ok {
+		return nil, fmt.Errorf("no kustomization found in %q", dir)
+	}
+	bases := &kustomizeBases{ctx: ctx, fSys: fSys, opts: opts, visited: map[string]bool{}}
+	if err := bases.resolve(root); err != nil {
+		return nil, err
+	}
+
+	kustomizeMu.Lock()
+	defer kustomizeMu.Unlock()
+	resources, err := krusty.MakeKustomizer(krusty.MakeDefaultOptions()).Run(fSys, root)
+	if err != nil {
+		return nil, fmt.Errorf("error rendering kustomization %q: %w", dir, err)
+	}
+	return resources.AsYaml()
+}
+
+// kustomizeVersion returns the version of kustomize that
+// kustomizations are rendered with.
+func kustomizeVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range info.Deps {
+			if dep.Path == kustomizeModule {
+				return dep.Version
+			}
+		}
+	}
+	return "unknown"
+}
+
+// kustomizeBases finds the bases of a kustomization, fetching the ones
+// in remote repos into the filesystem kustomize builds from and
+// pointing the kustomizations that use them at their local copies, so
+// that kustomize never fetches anything itself.
+type kustomizeBases struct {
+	ctx     context.Context
+	fSys    filesys.FileSystem
+	opts    kustomizeOptions
+	visited map[string]bool
+	fetched int
+}
+
+// resolve checks the kustomization in dir, and those of its local
+// bases, for remote bases and files.
+func (b *kustomizeBases) resolve(dir string) error {
+	if b.visited[dir] {
+		return nil
+	}
+	b.visited[dir] = true
+	name, ok := kustomizationFile(b.fSys, dir)
+	if !ok {
+		// Kustomize reports the missing kustomization itself.
+		return nil
+	}
+	content, err := b.fSys.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	kustomization := &types.Kustomization{}
+	if err := yaml.Unmarshal(content, kustomization); err != nil {
+		return fmt.Errorf("error parsing %s: %w", b.repoPath(name), err)
+	}
+	if err := checkNoRemoteFiles(kustomization); err != nil {
+		return fmt.Errorf("%s: %w", b.repoPath(name), err)
+	}
+
+	changed := false
+	// Each of these may name a directory, which kustomize loads as a
+	// base and which may be in a remote repo.
+	for _, refs := range [][]string{
+		kustomization.Resources,
+		kustomization.Bases,
+		kustomization.Components,
+		kustomization.Generators,
+		kustomization.Transformers,
+		kustomization.Validators,
+	} {
+		for i, ref := range refs {
+			if !isRemoteBase(ref) {
+				if local := path.Join(dir, ref); b.fSys.IsDir(local) {
+					if err := b.resolve(local); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			local, err := b.fetch(ref)
+			if err != nil {
+				return fmt.Errorf("%s: %w", b.repoPath(name), err)
+			}
+			rel, err := filepath.Rel(dir, local)
+			if err != nil {
+				return err
+			}
+			refs[i] = rel
+			changed = true
+			if err := b.resolve(local); err != nil {
+				return err
+			}
+		}
+	}
+	if !changed {
+		return nil
+	}
+	content, err = yaml.Marshal(kustomization)
+	if err != nil {
+		return err
+	}
+	return b.fSys.WriteFile(name, content)
+}
+
+// fetch clones the repo a remote base is in and returns the directory
+// in the filesystem kustomize builds from that the base was put in.
+func (b *kustomizeBases) fetch(ref string) (string, error) {
+	if !b.opts.allowRemoteBases {
+		return "", fmt.Errorf("remote base %q is not allowed", ref)
+	}
+	if b.fetched == maxKustomizeRemoteBases {
+		return "", fmt.Errorf("remote base %q is one more than the limit of %d", ref, maxKustomizeRemoteBases)
+	}
+	repoURL, dir, revision, err := parseRemoteBase(ref)
+	if err != nil {
+		return "", fmt.Errorf("invalid remote base %q: %w", ref, err)
+	}
+	if err := validateRepoURL(repoURL, b.opts.conf); err != nil {
+		return "", fmt.Errorf("remote base %q: %w", ref, err)
+	}
+
+	source := memfs.New()
+	repository, err := git.CloneContext(b.ctx, memory.NewStorage(), source, &git.CloneOptions{URL: repoURL})
+	if err != nil {
+		return "", fmt.Errorf("error cloning remote base %q: %w", ref, err)
+	}
+	if revision != "" {
+		if err := checkoutRevision(b.ctx, repository, revision); err != nil {
+			return "", fmt.Errorf("error checking out remote base %q: %w", ref, err)
+		}
+	}
+
+	destDir := fmt.Sprintf("%s/%d", kustomizeRemoteDir, b.fetched)
+	b.fetched++
+	if err := copyToKustomizeFS(source, "/", b.fSys, destDir); err != nil {
+		return "", err
+	}
+	return path.Join(destDir, path.Clean("/"+dir)), nil
+}
+
+// repoPath returns the path in the repo being resolved from, or in a
+// remote base, of a file in the filesystem kustomize builds from.
+func (b *kustomizeBases) repoPath(name string) string {
+	if strings.HasPrefix(name, kustomizeRepoDir+"/") {
+		return strings.TrimPrefix(name, kustomizeRepoDir+"/")
+	}
+	return name
+}
+
+// checkoutRevision checks out a branch, tag or commit of a repository
+// cloned with its default branch checked out.
+func checkoutRevision(ctx context.Context, repository *git.Repository, revision string) error {
+	refSpec := config.RefSpec(fmt.Sprintf("+refs/heads/%s:refs/remotes/%s", revision, revision))
+	err := repository.FetchContext(ctx, &git.FetchOptions{
+		RefSpecs: []config.RefSpec{refSpec},
+	})
+	if err != nil {
+		var fetchErr git.NoMatchingRefSpecError
+		if !errors.As(err, &fetchErr) && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+			return fmt.Errorf("unexpected fetch error: %v", err)
+		}
+	}
+	h, err := repository.ResolveRevision(plumbing.Revision(revision))
+	if err != nil {
+		return fmt.Errorf("revision error: %v", err)
+	}
+	w, err := repository.Worktree()
+	if err != nil {
+		return fmt.Errorf("worktree error: %v", err)
+	}
+	return w.Checkout(&git.CheckoutOptions{Hash: *h})
+}
+
+// isRemoteBase returns true if kustomize would take ref to be a base in
+// a remote git repo rather than a local path. It errs on the side of
+// remote, so nothing kustomize would clone is mistaken for a local
+// path.
+func isRemoteBase(ref string) bool {
+	lower := strings.ToLower(ref)
+	for _, prefix := range remoteBasePrefixes {
+		if strings.HasPrefix(lower, prefix) {
+			return true
+		}
+	}
+	return strings.Contains(ref, "://") || strings.Contains(ref, "_git/")
+}
+
+// parseRemoteBase splits a kustomize remote base into the URL of the
+// repo it's in, the directory inside that repo and the revision to
+// check out, which is empty for the repo's default branch. The
+// directory follows a "//" after the repo, a ".git" suffix or, failing
+// those, the first two elements of the repo's path.
+func parseRemoteBase(ref string) (string, string, string, error) {
+	s := ref
+	if strings.HasPrefix(strings.ToLower(s), "git::") {
+		s = s[len("git::"):]
+	}
+	revision := ""
+	if i := strings.Index(s, "?"); i >= 0 {
+		query, err := url.ParseQuery(s[i+1:])
+		if err != nil {
+			return "", "", "", err
+		}
+		revision = query.Get("version")
+		if ref := query.Get("ref"); ref != "" {
+			revision = ref
+		}
+		s = s[:i]
+	}
+	switch lower := strings.ToLower(s); {
+	case strings.HasPrefix(lower, "github.com/"), strings.HasPrefix(lower, "github.com:"):
+		s = "https://github.com/" + s[len("github.com/"):]
+	case strings.HasPrefix(lower, "gh:"):
+		s = "https://github.com/" + s[len("gh:"):]
+	}
+
+	// Find the end of the host, after which comes the repo's path.
+	var hostEnd int
+	if i := strings.Index(s, "://"); i >= 0 {
+		j := strings.Index(s[i+3:], "/")
+		if j < 0 {
+			return "", "", "", errors.New("missing repository")
+		}
+		hostEnd = i + 3 + j + 1
+	} else {
+		i := strings.Index(s, ":")
+		if i < 0 {
+			return "", "", "", errors.New("missing host")
+		}
+		hostEnd = i + 1
+	}
+
+	repoPath := s[hostEnd:]
+	var repo, dir string
+	switch {
+	case strings.Contains(repoPath, "//"):
+		i := strings.Index(repoPath, "//")
+		repo, dir = repoPath[:i], repoPath[i+2:]
+	case strings.Contains(repoPath+"/", ".git/"):
+		i := strings.Index(repoPath+"/", ".git/") + len(".git")
+		repo, dir = repoPath[:i], strings.TrimPrefix(repoPath[i:], "/")
+	case strings.Contains(repoPath, "_git/"):
+		i := strings.Index(repoPath, "_git/") + len("_git/")
+		repo = repoPath
+		if j := strings.Index(repoPath[i:], "/"); j >= 0 {
+			repo, dir = repoPath[:i+j], repoPath[i+j+1:]
+		}
+	default:
+		elems := strings.SplitN(repoPath, "/", 3)
+		if len(elems) < 2 || elems[0] == "" || elems[1] == "" {
+			return "", "", "", errors.New("missing repository")
+		}
+		repo = elems[0] + "/" + elems[1]
+		if len(elems) == 3 {
+			dir = elems[2]
+		}
+	}
+	if repo == "" {
+		return "", "", "", errors.New("missing repository")
+	}
+	return s[:hostEnd] + repo, dir, revision, nil
+}
+
+// checkNoRemoteFiles returns an error if a kustomization names a file
+// to be downloaded over HTTP, which kustomize would fetch itself.
+func checkNoRemoteFiles(kustomization *types.Kustomization) error {
+	files := []string{kustomization.OpenAPI["path"]}
+	files = append(files, kustomization.Crds...)
+	files = append(files, kustomization.Configurations...)
+	for _, patch := range kustomization.PatchesStrategicMerge {
+		files = append(files, string(patch))
+	}
+	for _, patches := range [][]types.Patch{kustomization.PatchesJson6902, kustomization.Patches} {
+		for _, patch := range patches {
+			files = append(files, patch.Path)
+		}
+	}
+	for _, replacement := range kustomization.Replacements {
+		files = append(files, replacement.Path)
+	}
+	sources := []types.KvPairSources{}
+	for _, generator := range kustomization.ConfigMapGenerator {
+		sources = append(sources, generator.KvPairSources)
+	}
+	for _, generator := range kustomization.SecretGenerator {
+		sources = append(sources, generator.KvPairSources)
+	}
+	for _, source := range sources {
+		for _, file := range source.FileSources {
+			// File sources may be given a key with "key=path".
+			if i := strings.Index(file, "="); i >= 0 {
+				file = file[i+1:]
+			}
+			files = append(files, file)
+		}
+		files = append(files, source.EnvSources...)
+		files = append(files, source.EnvSource)
+	}
+	for _, file := range files {
+		if u, err := url.Parse(file); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+			return fmt.Errorf("remote file %q is not allowed", file)
+		}
+	}
+	return nil
+}
+
+// kustomizationFile returns the name of the kustomization file in dir,
+// if there is one.
+func kustomizationFile(fSys filesys.FileSystem, dir string) (string, bool) {
+	for _, name := range konfig.RecognizedKustomizationFileNames() {
+		if p := path.Join(dir, name); fSys.Exists(p) && !fSys.IsDir(p) {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// copyToKustomizeFS copies the regular files under dir of source to
+// destDir of dest.
+func copyToKustomizeFS(source billy.Filesystem, dir string, dest filesys.FileSystem, destDir string) error {
+	if err := dest.MkdirAll(destDir); err != nil {
+		return err
+	}
+	infos, err := source.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("error reading directory %q: %w", dir, err)
+	}
+	for _, info := range infos {
+		name := path.Join(dir, info.Name())
+		destName := path.Join(destDir, info.Name())
+		switch {
+		case info.IsDir():
+			if err := copyToKustomizeFS(source, name, dest, destName); err != nil {
+				return err
+			}
+		case info.Mode().IsRegular():
+			content, err := readFile(source, name, 0)
+			if err != nil {
+				return fmt.Errorf("error reading file %q: %w", name, err)
+			}
+			if err := dest.WriteFile(destName, content); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
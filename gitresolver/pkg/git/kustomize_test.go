@@ -0,0 +1,306 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tektoncd/resolution/pkg/resolver/framework"
+	"github.com/tektoncd/resolution/test/diff"
+	"github.com/tektoncd/resolution/test/fixtures"
+)
+
+const kustomizeTask = `apiVersion: tekton.dev/v1beta1
+kind: Task
+metadata:
+  name: build
+spec:
+  steps:
+  - name: build
+    image: golang
+`
+
+func TestResolveKustomization(t *testing.T) {
+	sharedServer := fixtures.NewGitServer(t, fixtures.GitRepo{
+		Name: "shared",
+		Commits: []fixtures.GitCommit{{
+			Files: map[string]string{
+				"base/kustomization.yaml": "resources:\n- task.yaml\n",
+				"base/task.yaml":          kustomizeTask,
+			},
+		}},
+	})
+	// The shared repo is fetched from a different host to the
+	// catalog, so that one can be denied but not the other.
+	sharedURL := strings.Replace(sharedServer.RepoURL("shared"), "127.0.0.1", "localhost", 1)
+	gitServer := fixtures.NewGitServer(t, fixtures.GitRepo{
+		Name: "catalog",
+		Commits: []fixtures.GitCommit{{
+			Files: map[string]string{
+				"base/kustomization.yaml":             "resources:\n- task.yaml\n",
+				"base/task.yaml":                      kustomizeTask,
+				"overlays/prod/kustomization.yaml":    "resources:\n- ../../base\nnamePrefix: prod-\ncommonLabels:\n  env: prod\n",
+				"overlays/shared/kustomization.yaml":  "resources:\n- " + sharedURL + "//base?ref=master\nnameSuffix: -shared\n",
+				"overlays/patched/kustomization.yaml": "resources:\n- ../../base\npatchesStrategicMerge:\n- https://example.com/patch.yaml\n",
+				"overlays/README.md":                  "# Overlays",
+			},
+		}},
+	})
+	resolver := &Resolver{}
+
+	for _, tc := range []struct {
+		name            string
+		path            string
+		conf            map[string]string
+		limit           int64
+		expectedContent string
+		expectedErr     string
+	}{{
+		name: "overlay",
+		path: "overlays/prod",
+		expectedContent: `apiVersion: tekton.dev/v1beta1
+kind: Task
+metadata:
+  labels:
+    env: prod
+  name: prod-build
+spec:
+  steps:
+  - image: golang
+    name: build
+`,
+	}, {
+		name:        "not enabled",
+		path:        "overlays/prod",
+		conf:        map[string]string{ConfigEnableKustomize: "false"},
+		expectedErr: `kustomize is not enabled: set enable-kustomize to "true" in the resolver's config to use it`,
+	}, {
+		name:        "remote base not allowed",
+		path:        "overlays/shared",
+		expectedErr: `overlays/shared/kustomization.yaml: remote base "` + sharedURL + `//base?ref=master" is not allowed`,
+	}, {
+		name: "remote base",
+		path: "overlays/shared",
+		conf: map[string]string{ConfigKustomizeAllowRemoteBases: "true"},
+		expectedContent: `apiVersion: tekton.dev/v1beta1
+kind: Task
+metadata:
+  name: build-shared
+spec:
+  steps:
+  - image: golang
+    name: build
+`,
+	}, {
+		name: "remote base from denied host",
+		path: "overlays/shared",
+		conf: map[string]string{
+			ConfigKustomizeAllowRemoteBases: "true",
+			ConfigDeniedHosts:               "localhost",
+		},
+		expectedErr: `overlays/shared/kustomization.yaml: remote base "` + sharedURL + `//base?ref=master": url "` + sharedURL + `" has host "localhost", which is denied`,
+	}, {
+		name:        "remote file",
+		path:        "overlays/patched",
+		conf:        map[string]string{ConfigKustomizeAllowRemoteBases: "true"},
+		expectedErr: `overlays/patched/kustomization.yaml: remote file "https://example.com/patch.yaml" is not allowed`,
+	}, {
+		name:        "no kustomization",
+		path:        "overlays",
+		expectedErr: `no kustomization found in "overlays"`,
+	}, {
+		name:        "not a directory",
+		path:        "base/task.yaml",
+		expectedErr: `pathInRepo "base/task.yaml" must be a directory holding a kustomization`,
+	}, {
+		name:        "larger than limit",
+		path:        "overlays/prod",
+		limit:       20,
+		expectedErr: "resolved data is larger than the limit of 20 bytes",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			conf := map[string]string{
+				ConfigRevision:          fixtures.DefaultBranch,
+				ConfigAllowedURLSchemes: "http",
+				ConfigEnableKustomize:   "true",
+			}
+			for k, v := range tc.conf {
+				conf[k] = v
+			}
+			ctx := framework.InjectResolverConfigToContext(context.Background(), conf)
+			ctx = framework.InjectMaxResolvedDataSize(ctx, tc.limit)
+			params := map[string]string{
+				URLParam:       gitServer.RepoURL("catalog"),
+				PathParam:      tc.path,
+				KustomizeParam: "true",
+			}
+
+			output, err := resolver.Resolve(ctx, params)
+			if tc.expectedErr != "" {
+				if err == nil || err.Error() != tc.expectedErr {
+					t.Fatalf("expected error %q, got %v", tc.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if d := cmp.Diff(tc.expectedContent, string(output.Data())); d != "" {
+				t.Errorf("unexpected content %s", diff.PrintWantGot(d))
+			}
+			annotations := output.Annotations()
+			if overlay := annotations[AnnotationKeyKustomizeOverlay]; overlay != tc.path {
+				t.Errorf("expected %s annotation %q, got %q", AnnotationKeyKustomizeOverlay, tc.path, overlay)
+			}
+			if version := annotations[AnnotationKeyKustomizeVersion]; !strings.HasPrefix(version, "v") {
+				t.Errorf("expected %s annotation to be a module version, got %q", AnnotationKeyKustomizeVersion, version)
+			}
+		})
+	}
+}
+
+func TestValidateParamsKustomize(t *testing.T) {
+	resolver := Resolver{}
+	enabled := framework.InjectResolverConfigToContext(context.Background(), map[string]string{ConfigEnableKustomize: "true"})
+
+	for _, tc := range []struct {
+		name        string
+		ctx         context.Context
+		params      map[string]string
+		expectedErr string
+	}{{
+		name:   "enabled",
+		ctx:    enabled,
+		params: map[string]string{PathParam: "overlays/prod", KustomizeParam: "true"},
+	}, {
+		name:   "not requested",
+		ctx:    context.Background(),
+		params: map[string]string{PathParam: "overlays/prod", KustomizeParam: "false"},
+	}, {
+		name:        "not enabled",
+		ctx:         context.Background(),
+		params:      map[string]string{PathParam: "overlays/prod", KustomizeParam: "true"},
+		expectedErr: `kustomize is not enabled: set enable-kustomize to "true" in the resolver's config to use it`,
+	}, {
+		name:        "invalid",
+		ctx:         enabled,
+		params:      map[string]string{PathParam: "overlays/prod", KustomizeParam: "yes"},
+		expectedErr: `invalid kustomize "yes": must be "true" or "false"`,
+	}, {
+		name:        "with glob",
+		ctx:         enabled,
+		params:      map[string]string{PathParam: "overlays/prod", KustomizeParam: "true", GlobParam: "*.yaml"},
+		expectedErr: "glob can't be used with kustomize",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := resolver.ValidateParams(tc.ctx, tc.params)
+			if tc.expectedErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tc.expectedErr {
+				t.Fatalf("expected error %q, got %v", tc.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestParseRemoteBase(t *testing.T) {
+	for _, tc := range []struct {
+		ref              string
+		expectedURL      string
+		expectedDir      string
+		expectedRevision string
+		expectedErr      bool
+	}{{
+		ref:              "https://github.com/tektoncd/catalog//task/git-clone?ref=v1",
+		expectedURL:      "https://github.com/tektoncd/catalog",
+		expectedDir:      "task/git-clone",
+		expectedRevision: "v1",
+	}, {
+		ref:         "https://example.com/tektoncd/catalog.git/task/git-clone",
+		expectedURL: "https://example.com/tektoncd/catalog.git",
+		expectedDir: "task/git-clone",
+	}, {
+		ref:              "github.com/tektoncd/catalog/task/git-clone?version=main",
+		expectedURL:      "https://github.com/tektoncd/catalog",
+		expectedDir:      "task/git-clone",
+		expectedRevision: "main",
+	}, {
+		ref:         "git::ssh://git@example.com/tektoncd/catalog",
+		expectedURL: "ssh://git@example.com/tektoncd/catalog",
+	}, {
+		ref:         "git@github.com:tektoncd/catalog.git",
+		expectedURL: "git@github.com:tektoncd/catalog.git",
+	}, {
+		ref:         "https://dev.azure.com/org/project/_git/repo/task",
+		expectedURL: "https://dev.azure.com/org/project/_git/repo",
+		expectedDir: "task",
+	}, {
+		ref:         "https://github.com/tektoncd",
+		expectedErr: true,
+	}, {
+		ref:         "https://github.com",
+		expectedErr: true,
+	}} {
+		t.Run(tc.ref, func(t *testing.T) {
+			if !isRemoteBase(tc.ref) {
+				t.Fatalf("expected %q to be a remote base", tc.ref)
+			}
+			repoURL, dir, revision, err := parseRemoteBase(tc.ref)
+			if tc.expectedErr {
+				if err == nil {
+					t.Fatalf("expected error, got url %q", repoURL)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if repoURL != tc.expectedURL || dir != tc.expectedDir || revision != tc.expectedRevision {
+				t.Errorf("expected %q, %q, %q, got %q, %q, %q", tc.expectedURL, tc.expectedDir, tc.expectedRevision, repoURL, dir, revision)
+			}
+		})
+	}
+
+	for _, ref := range []string{"../../base", "task.yaml", "github/base"} {
+		if isRemoteBase(ref) {
+			t.Errorf("expected %q to be a local path", ref)
+		}
+	}
+}
+
+func TestIsClusterShareableKustomize(t *testing.T) {
+	params := map[string]string{
+		URLParam:       "https://github.com/tektoncd/catalog.git",
+		PathParam:      "overlays/prod",
+		RevisionParam:  "a2ebb34d35df9f67c5bd3d8d5b9bd4f5b51a2a3c",
+		KustomizeParam: "true",
+	}
+	if !(&Resolver{}).IsClusterShareable(context.Background(), params) {
+		t.Error("expected kustomization without remote bases to be shareable")
+	}
+	ctx := framework.InjectResolverConfigToContext(context.Background(), map[string]string{ConfigKustomizeAllowRemoteBases: "true"})
+	if (&Resolver{}).IsClusterShareable(ctx, params) {
+		t.Error("expected kustomization that may use remote bases not to be shareable")
+	}
+}
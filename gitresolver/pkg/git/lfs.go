@@ -0,0 +1,269 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/tektoncd/resolution/pkg/resolver/framework"
+)
+
+const (
+	// lfsPointerVersion starts every Git LFS pointer.
+	lfsPointerVersion = "version https://git-lfs.github.com/spec/v1\n"
+
+	// lfsMaxPointerSize is the size Git LFS pointers are always
+	// smaller than.
+	lfsMaxPointerSize = 1024
+
+	// lfsMediaType is the media type of requests to and responses
+	// from the Git LFS batch API.
+	lfsMediaType = "application/vnd.git-lfs+json"
+
+	// lfsMaxBatchResponseSize limits how much of a response from the
+	// batch API is read.
+	lfsMaxBatchResponseSize = 1024 * 1024
+)
+
+var lfsOIDPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// lfsPointer identifies a file stored with Git LFS.
+type lfsPointer struct {
+	oid  string
+	size int64
+}
+
+// parseLFSPointer returns the pointer in content, or false if content
+// isn't a Git LFS pointer.
+func parseLFSPointer(content []byte) (lfsPointer, bool) {
+	pointer := lfsPointer{size: -1}
+	if len(content) >= lfsMaxPointerSize || !bytes.HasPrefix(content, []byte(lfsPointerVersion)) {
+		return pointer, false
+	}
+	for _, line := range strings.Split(string(content[len(lfsPointerVersion):]), "\n") {
+		switch {
+		case strings.HasPrefix(line, "oid sha256:"):
+			pointer.oid = strings.TrimPrefix(line, "oid sha256:")
+		case strings.HasPrefix(line, "size "):
+			size, err := strconv.ParseInt(strings.TrimPrefix(line, "size "), 10, 64)
+			if err != nil || size < 0 {
+				return pointer, false
+			}
+			pointer.size = size
+		}
+	}
+	return pointer, lfsOIDPattern.MatchString(pointer.oid) && pointer.size >= 0
+}
+
+// lfsClient downloads objects from a repo's Git LFS server using its
+// batch API.
+type lfsClient struct {
+	// endpoint is the URL of the repo's LFS server, which the batch
+	// API is relative to.
+	endpoint *url.URL
+
+	// user is set from the repo's URL, if it has one, so that objects
+	// are fetched with the same credentials as the repo.
+	user *url.Userinfo
+
+	// conf is the resolver's config, whose allowed and denied hosts
+	// apply to where objects are downloaded from too.
+	conf map[string]string
+}
+
+// newLFSClient returns a client for the LFS server of the repo at
+// repoURL. Like git-lfs, it assumes the server is at the repo's URL, or
+// the https equivalent of an SSH or git URL, followed by info/lfs.
+func newLFSClient(repoURL string, conf map[string]string) (*lfsClient, error) {
+	scheme, host, err := parseRepoURL(repoURL)
+	if err != nil {
+		return nil, err
+	}
+	endpoint := &url.URL{Scheme: "https", Host: host}
+	var user *url.Userinfo
+	switch scheme {
+	case "http", "https":
+		u, err := url.Parse(repoURL)
+		if err != nil {
+			return nil, err
+		}
+		endpoint.Scheme = scheme
+		endpoint.Host = u.Host
+		endpoint.Path = u.Path
+		user = u.User
+	case "ssh", "git":
+		if u, err := url.Parse(repoURL); err == nil && u.Scheme != "" {
+			endpoint.Path = u.Path
+		} else {
+			// An scp-like URL, e.g. git@github.com:tektoncd/catalog.git.
+			endpoint.Path = "/" + repoURL[strings.Index(repoURL, ":")+1:]
+		}
+	default:
+		return nil, fmt.Errorf("LFS objects can't be fetched for repos with the %q scheme", scheme)
+	}
+	endpoint.Path = strings.TrimSuffix(endpoint.Path, "/")
+	if !strings.HasSuffix(endpoint.Path, ".git") {
+		endpoint.Path += ".git"
+	}
+	endpoint.Path += "/info/lfs"
+	return &lfsClient{endpoint: endpoint, user: user, conf: conf}, nil
+}
+
+// lfsObject is an object in a request to or response from the batch
+// API.
+type lfsObject struct {
+	OID     string               `json:"oid"`
+	Size    int64                `json:"size"`
+	Actions map[string]lfsAction `json:"actions,omitempty"`
+	Error   *lfsObjectError      `json:"error,omitempty"`
+}
+
+type lfsAction struct {
+	Href   string            `json:"href"`
+	Header map[string]string `json:"header,omitempty"`
+}
+
+type lfsObjectError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// smudge returns the object that content points to if it's a Git LFS
+// pointer, or content otherwise. Objects larger than limit aren't
+// downloaded; a limit of 0 downloads objects of any size.
+func (c *lfsClient) smudge(ctx context.Context, content []byte, limit int64) ([]byte, error) {
+	pointer, ok := parseLFSPointer(content)
+	if !ok {
+		return content, nil
+	}
+	if limit > 0 && pointer.size > limit {
+		return nil, framework.NewErrorResolvedDataTooLarge(limit)
+	}
+	action, err := c.downloadAction(ctx, pointer)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching LFS object %s: %w", pointer.oid, err)
+	}
+	object, err := c.download(ctx, action, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching LFS object %s: %w", pointer.oid, err)
+	}
+	sum := sha256.Sum256(object)
+	if int64(len(object)) != pointer.size || hex.EncodeToString(sum[:]) != pointer.oid {
+		return nil, fmt.Errorf("LFS object %s doesn't match its pointer", pointer.oid)
+	}
+	return object, nil
+}
+
+// downloadAction asks the batch API how to download the object pointer
+// points to.
+func (c *lfsClient) downloadAction(ctx context.Context, pointer lfsPointer) (lfsAction, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"operation": "download",
+		"transfers": []string{"basic"},
+		"objects":   []lfsObject{{OID: pointer.oid, Size: pointer.size}},
+	})
+	if err != nil {
+		return lfsAction{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint.String()+"/objects/batch", bytes.NewReader(body))
+	if err != nil {
+		return lfsAction{}, err
+	}
+	req.Header.Set("Accept", lfsMediaType)
+	req.Header.Set("Content-Type", lfsMediaType)
+	c.setCredentials(req)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return lfsAction{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return lfsAction{}, fmt.Errorf("batch request returned status %d", resp.StatusCode)
+	}
+
+	batch := struct {
+		Objects []lfsObject `json:"objects"`
+	}{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, lfsMaxBatchResponseSize)).Decode(&batch); err != nil {
+		return lfsAction{}, fmt.Errorf("error parsing batch response: %w", err)
+	}
+	for _, object := range batch.Objects {
+		if object.OID != pointer.oid {
+			continue
+		}
+		if object.Error != nil {
+			return lfsAction{}, fmt.Errorf("%s (%d)", object.Error.Message, object.Error.Code)
+		}
+		if action, ok := object.Actions["download"]; ok {
+			return action, nil
+		}
+	}
+	return lfsAction{}, errors.New("batch response has no download action")
+}
+
+// download fetches an object as the batch API told it to.
+func (c *lfsClient) download(ctx context.Context, action lfsAction, limit int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, action.Href, nil)
+	if err != nil {
+		return nil, err
+	}
+	if req.URL.Scheme != "https" && req.URL.Scheme != c.endpoint.Scheme {
+		return nil, fmt.Errorf("download URL has scheme %q", req.URL.Scheme)
+	}
+	host := strings.ToLower(req.URL.Hostname())
+	if hostMatches(host, splitConfigList(c.conf[ConfigDeniedHosts])) {
+		return nil, fmt.Errorf("download URL has host %q, which is denied", host)
+	}
+	if allowed := splitConfigList(c.conf[ConfigAllowedHosts]); len(allowed) > 0 && !hostMatches(host, allowed) {
+		return nil, fmt.Errorf("download URL has host %q, which isn't one of the allowed hosts %s", host, strings.Join(allowed, ", "))
+	}
+	if req.URL.Host == c.endpoint.Host {
+		c.setCredentials(req)
+	}
+	for key, value := range action.Header {
+		req.Header.Set(key, value)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download returned status %d", resp.StatusCode)
+	}
+	return framework.ReadAllWithLimit(resp.Body, limit)
+}
+
+func (c *lfsClient) setCredentials(req *http.Request) {
+	if c.user == nil {
+		return
+	}
+	password, _ := c.user.Password()
+	req.SetBasicAuth(c.user.Username(), password)
+}
@@ -24,3 +24,11 @@ const PathParam string = "pathInRepo"
 
 // RevisionParam is the commit hash/branch/tag that a file should be fetched from
 const RevisionParam string = "revision"
+
+// GlobParam is the pattern that files must match to be included when
+// pathInRepo is a directory
+const GlobParam string = "glob"
+
+// KustomizeParam is set to "true" to resolve the output of a kustomize
+// build of the kustomization in the directory pathInRepo names
+const KustomizeParam string = "kustomize"
@@ -17,12 +17,15 @@ limitations under the License.
 package git
 
 import (
-	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
+	"os"
+	"path"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-git/go-billy/v5/memfs"
@@ -32,6 +35,11 @@ import (
 	"github.com/go-git/go-git/v5/storage/memory"
 	resolutioncommon "github.com/tektoncd/resolution/pkg/common"
 	"github.com/tektoncd/resolution/pkg/resolver/framework"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	kubeclient "knative.dev/pkg/client/injection/kube/client"
+	"knative.dev/pkg/system"
 )
 
 // LabelValueGitResolverType is the value to use for the
@@ -48,10 +56,36 @@ const YAMLContentType string = "application/x-yaml"
 var _ framework.Resolver = &Resolver{}
 
 // Resolver implements a framework.Resolver that can fetch files from git.
-type Resolver struct{}
+type Resolver struct {
+	cachesMu sync.Mutex
+	caches   map[string]*repoCache
+
+	// secrets lists the Secrets in the resolver's namespace, where
+	// trusted keys can be kept. It's nil when the resolver isn't
+	// running in a cluster.
+	secrets corev1listers.SecretNamespaceLister
+}
 
-// Initialize performs any setup required by the gitresolver.
+// Initialize starts watching the Secrets in the resolver's namespace if
+// there's a Kubernetes client to do it with. Without one, e.g. when
+// resolving locally, trusted keys can only be given in config.
 func (r *Resolver) Initialize(ctx context.Context) error {
+	client, ok := ctx.Value(kubeclient.Key{}).(kubernetes.Interface)
+	namespace := os.Getenv(system.NamespaceEnvKey)
+	if !ok || namespace == "" {
+		return nil
+	}
+	factory := informers.NewSharedInformerFactoryWithOptions(client, 0, informers.WithNamespace(namespace))
+	secrets := factory.Core().V1().Secrets()
+	// Registers the informer so that the factory starts it.
+	secrets.Informer()
+	factory.Start(ctx.Done())
+	for _, synced := range factory.WaitForCacheSync(ctx.Done()) {
+		if !synced {
+			return fmt.Errorf("error syncing secrets in namespace %q", namespace)
+		}
+	}
+	r.secrets = secrets.Lister().Secrets(namespace)
 	return nil
 }
 
@@ -69,9 +103,26 @@ func (r *Resolver) GetSelector(_ context.Context) map[string]string {
 	}
 }
 
+var _ framework.ClusterShareable = &Resolver{}
+
+// IsClusterShareable returns true if the request is for a full commit
+// hash. Files are fetched from git without any namespace-scoped
+// credentials and a commit never changes, so every namespace gets the
+// same result for the same parameters. Branches and tags can move, so
+// their results aren't shared, and neither are those of kustomizations
+// that may use remote bases.
+func (r *Resolver) IsClusterShareable(ctx context.Context, params map[string]string) bool {
+	if params[KustomizeParam] == "true" && framework.GetResolverConfigFromContext(ctx)[ConfigKustomizeAllowRemoteBases] == "true" {
+		// Remote bases may be on branches, so the output of a
+		// kustomization using them can change.
+		return false
+	}
+	return plumbing.IsHash(params[RevisionParam])
+}
+
 // ValidateParams returns an error if the given parameter map is not
 // valid for a resource request targeting the gitresolver.
-func (r *Resolver) ValidateParams(_ context.Context, params map[string]string) error {
+func (r *Resolver) ValidateParams(ctx context.Context, params map[string]string) error {
 	required := []string{
 		PathParam,
 	}
@@ -89,9 +140,33 @@ func (r *Resolver) ValidateParams(_ context.Context, params map[string]string) e
 	if len(missing) > 0 {
 		return fmt.Errorf("missing %v", strings.Join(missing, ", "))
 	}
-
-	// TODO(sbwsg): validate repo url is well-formed, git:// or https://
-	// TODO(sbwsg): validate pathInRepo is valid relative pathInRepo
+	if repo := params[URLParam]; repo != "" {
+		if err := validateRepoURL(repo, framework.GetResolverConfigFromContext(ctx)); err != nil {
+			return err
+		}
+	}
+	if _, err := cleanPathInRepo(params[PathParam]); err != nil {
+		return err
+	}
+	if glob, ok := params[GlobParam]; ok {
+		if _, err := path.Match(glob, ""); err != nil {
+			return fmt.Errorf("invalid %s %q: %w", GlobParam, glob, err)
+		}
+	}
+	if val, ok := params[KustomizeParam]; ok {
+		kustomize, err := strconv.ParseBool(val)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q: must be \"true\" or \"false\"", KustomizeParam, val)
+		}
+		if kustomize {
+			if err := checkKustomizeEnabled(framework.GetResolverConfigFromContext(ctx)); err != nil {
+				return err
+			}
+			if _, ok := params[GlobParam]; ok {
+				return fmt.Errorf("%s can't be used with %s", GlobParam, KustomizeParam)
+			}
+		}
+	}
 
 	return nil
 }
@@ -108,6 +183,15 @@ func (r *Resolver) Resolve(ctx context.Context, params map[string]string) (frame
 			return nil, fmt.Errorf("default Git Repo Url was not set during installation of the git resolver")
 		}
 	}
+	// The default url is validated too, so that changing the allowed
+	// schemes or hosts applies to it.
+	if err := validateRepoURL(repo, conf); err != nil {
+		return nil, err
+	}
+	path, err := cleanPathInRepo(params[PathParam])
+	if err != nil {
+		return nil, err
+	}
 
 	revision := params[RevisionParam]
 	if revision == "" {
@@ -118,23 +202,45 @@ func (r *Resolver) Resolve(ctx context.Context, params map[string]string) (frame
 		}
 	}
 
-	cloneOpts := &git.CloneOptions{
-		URL: repo,
+	cacheDir, cacheMaxSize, err := cacheOptionsFromConfig(conf)
+	if err != nil {
+		return nil, err
 	}
 	filesystem := memfs.New()
-	repository, err := git.Clone(memory.NewStorage(), filesystem, cloneOpts)
-	if err != nil {
-		return nil, fmt.Errorf("clone error: %w", err)
+	var repository *git.Repository
+	if cacheDir == "" {
+		cloneOpts := &git.CloneOptions{
+			URL: repo,
+		}
+		repository, err = git.CloneContext(ctx, memory.NewStorage(), filesystem, cloneOpts)
+		if err != nil {
+			return nil, fmt.Errorf("clone error: %w", err)
+		}
+	} else {
+		var release func()
+		repository, release, err = r.repoCache(cacheDir).open(ctx, repo, filesystem, cacheMaxSize)
+		if err != nil {
+			return nil, fmt.Errorf("clone error: %w", err)
+		}
+		defer release()
 	}
 
 	// try fetch the branch when the given revision refers to a branch name
 	refSpec := config.RefSpec(fmt.Sprintf("+refs/heads/%s:refs/remotes/%s", revision, revision))
-	err = repository.Fetch(&git.FetchOptions{
+	if !strings.HasPrefix(revision, git.DefaultRemoteName+"/") {
+		// A cached clone keeps the ref from the last request for
+		// the branch, which must not be used if the branch has
+		// since been deleted.
+		if err := repository.Storer.RemoveReference(plumbing.ReferenceName("refs/remotes/" + revision)); err != nil {
+			return nil, fmt.Errorf("unexpected fetch error: %v", err)
+		}
+	}
+	err = repository.FetchContext(ctx, &git.FetchOptions{
 		RefSpecs: []config.RefSpec{refSpec},
 	})
 	if err != nil {
 		var fetchErr git.NoMatchingRefSpecError
-		if !errors.As(err, &fetchErr) {
+		if !errors.As(err, &fetchErr) && !errors.Is(err, git.NoErrAlreadyUpToDate) {
 			return nil, fmt.Errorf("unexpected fetch error: %v", err)
 		}
 	}
@@ -149,6 +255,21 @@ func (r *Resolver) Resolve(ctx context.Context, params map[string]string) (frame
 		return nil, fmt.Errorf("revision error: %v", err)
 	}
 
+	verification, err := verificationOptionsFromConfig(conf, r.secrets)
+	if err != nil {
+		return nil, err
+	}
+	commitSigner := &signer{}
+	if verification != nil {
+		commit, err := repository.CommitObject(*h)
+		if err != nil {
+			return nil, fmt.Errorf("error reading commit %s: %w", h, err)
+		}
+		if commitSigner, err = verifyCommit(repository, commit, verification); err != nil {
+			return nil, err
+		}
+	}
+
 	err = w.Checkout(&git.CheckoutOptions{
 		Hash: *h,
 	})
@@ -156,24 +277,103 @@ func (r *Resolver) Resolve(ctx context.Context, params map[string]string) (frame
 		return nil, fmt.Errorf("checkout error: %v", err)
 	}
 
-	path := params[PathParam]
-	f, err := filesystem.Open(path)
+	lfsRepo := repo
+	if conf[ConfigFetchSubmodules] == "true" {
+		if lfsRepo, err = checkoutSubmodules(ctx, repository, repo, path, conf); err != nil {
+			return nil, err
+		}
+	}
+	var smudge func(content []byte, limit int64) ([]byte, error)
+	if conf[ConfigFetchLFS] == "true" {
+		lfs, err := newLFSClient(lfsRepo, conf)
+		if err != nil {
+			return nil, err
+		}
+		smudge = func(content []byte, limit int64) ([]byte, error) {
+			return lfs.smudge(ctx, content, limit)
+		}
+	}
+
+	limit := framework.MaxResolvedDataSize(ctx)
+	if params[KustomizeParam] == "true" {
+		// The config may have changed since the params were
+		// validated.
+		if err := checkKustomizeEnabled(conf); err != nil {
+			return nil, err
+		}
+		content, err := renderKustomization(ctx, filesystem, path, kustomizeOptionsFromConfig(conf))
+		if err != nil {
+			return nil, err
+		}
+		if limit > 0 && int64(len(content)) > limit {
+			return nil, framework.NewErrorResolvedDataTooLarge(limit)
+		}
+		return &ResolvedGitResource{
+			Revision:         revision,
+			Content:          content,
+			KustomizeOverlay: path,
+			KustomizeVersion: kustomizeVersion(),
+			Signer:           commitSigner.identity,
+			SignerKey:        commitSigner.key,
+		}, nil
+	}
+
+	info, err := filesystem.Stat(path)
 	if err != nil {
 		return nil, fmt.Errorf("error opening file %q: %v", path, err)
 	}
+	if info.IsDir() {
+		opts, err := directoryOptionsFromParams(params, conf, limit)
+		if err != nil {
+			return nil, err
+		}
+		opts.smudge = smudge
+		content, files, err := readDirectory(filesystem, path, opts)
+		if err != nil {
+			return nil, err
+		}
+		return &ResolvedGitResource{
+			Revision:  revision,
+			Content:   content,
+			Files:     files,
+			Signer:    commitSigner.identity,
+			SignerKey: commitSigner.key,
+		}, nil
+	}
+	if limit > 0 && info.Size() > limit {
+		return nil, framework.NewErrorResolvedDataTooLarge(limit)
+	}
 
-	buf := &bytes.Buffer{}
-	_, err = io.Copy(buf, f)
+	content, err := readFile(filesystem, path, limit)
+	if err == nil && smudge != nil {
+		content, err = smudge(content, limit)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("error reading file %q: %v", path, err)
+		return nil, fmt.Errorf("error reading file %q: %w", path, err)
 	}
 
 	return &ResolvedGitResource{
-		Revision: revision,
-		Content:  buf.Bytes(),
+		Revision:  revision,
+		Content:   content,
+		Signer:    commitSigner.identity,
+		SignerKey: commitSigner.key,
 	}, nil
 }
 
+// repoCache returns the cache of repositories kept in dir, shared by
+// every request resolved with the same config.
+func (r *Resolver) repoCache(dir string) *repoCache {
+	r.cachesMu.Lock()
+	defer r.cachesMu.Unlock()
+	if r.caches == nil {
+		r.caches = map[string]*repoCache{}
+	}
+	if _, ok := r.caches[dir]; !ok {
+		r.caches[dir] = newRepoCache(dir)
+	}
+	return r.caches[dir]
+}
+
 var _ framework.ConfigWatcher = &Resolver{}
 
 // GetConfigName returns the name of the git resolver's configmap.
@@ -202,6 +402,23 @@ func (r *Resolver) GetResolutionTimeout(ctx context.Context, defaultTimeout time
 type ResolvedGitResource struct {
 	Revision string
 	Content  []byte
+
+	// Files maps the path of each file resolved from a directory
+	// to its digest. It's nil if a single file was resolved.
+	Files map[string]string
+
+	// KustomizeOverlay and KustomizeVersion are the directory of
+	// the kustomization the content was rendered from and the
+	// version of kustomize that rendered it. They're empty unless
+	// kustomize was used.
+	KustomizeOverlay string
+	KustomizeVersion string
+
+	// Signer and SignerKey identify who signed the commit the
+	// content was resolved from and with which key. They're empty
+	// unless signatures are verified.
+	Signer    string
+	SignerKey string
 }
 
 var _ framework.ResolvedResource = &ResolvedGitResource{}
@@ -214,8 +431,23 @@ func (r *ResolvedGitResource) Data() []byte {
 // Annotations returns the metadata that accompanies the file fetched
 // from git.
 func (r *ResolvedGitResource) Annotations() map[string]string {
-	return map[string]string{
+	annotations := map[string]string{
 		AnnotationKeyRevision:                     r.Revision,
 		resolutioncommon.AnnotationKeyContentType: YAMLContentType,
 	}
+	if r.Files != nil {
+		// Marshalling a map of strings can't fail and sorts
+		// its keys, so the annotation is deterministic.
+		files, _ := json.Marshal(r.Files)
+		annotations[AnnotationKeyFiles] = string(files)
+	}
+	if r.KustomizeOverlay != "" {
+		annotations[AnnotationKeyKustomizeOverlay] = r.KustomizeOverlay
+		annotations[AnnotationKeyKustomizeVersion] = r.KustomizeVersion
+	}
+	if r.Signer != "" {
+		annotations[AnnotationKeySigner] = r.Signer
+		annotations[AnnotationKeySignerKey] = r.SignerKey
+	}
+	return annotations
 }
@@ -2,8 +2,10 @@ package git
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"io/ioutil"
 	"os"
@@ -11,6 +13,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
@@ -22,6 +26,7 @@ import (
 	frtesting "github.com/tektoncd/resolution/pkg/resolver/framework/testing"
 	"github.com/tektoncd/resolution/test"
 	"github.com/tektoncd/resolution/test/diff"
+	"github.com/tektoncd/resolution/test/fixtures"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"knative.dev/pkg/apis"
@@ -76,6 +81,18 @@ func TestValidateParamsMissing(t *testing.T) {
 	}
 }
 
+func TestValidateParamsInvalidGlob(t *testing.T) {
+	resolver := Resolver{}
+
+	params := map[string]string{
+		PathParam: "foo",
+		GlobParam: "[",
+	}
+	if err := resolver.ValidateParams(context.Background(), params); err == nil {
+		t.Fatalf("expected invalid glob err")
+	}
+}
+
 func TestGetResolutionTimeoutDefault(t *testing.T) {
 	resolver := Resolver{}
 	defaultTimeout := 30 * time.Minute
@@ -203,7 +220,8 @@ func TestResolve(t *testing.T) {
 			}
 
 			v := map[string]string{
-				ConfigRevision: plumbing.Master.Short(),
+				ConfigRevision:          plumbing.Master.Short(),
+				ConfigAllowedURLSchemes: "file",
 			}
 			output, err := resolver.Resolve(context.WithValue(context.Background(), struct{}{}, v), params)
 
@@ -237,6 +255,227 @@ func TestResolve(t *testing.T) {
 	}
 }
 
+// TestResolveOverHTTP resolves files from a repository served over
+// git's smart HTTP protocol rather than from disk.
+func TestResolveOverHTTP(t *testing.T) {
+	gitServer := fixtures.NewGitServer(t, fixtures.GitRepo{
+		Name: "catalog",
+		Commits: []fixtures.GitCommit{{
+			Files: map[string]string{"task/foo.yaml": "first"},
+			Tag:   "v1",
+		}, {
+			Branch: "other-branch",
+			Files:  map[string]string{"task/foo.yaml": "on other branch"},
+		}, {
+			Files: map[string]string{"task/foo.yaml": "second"},
+		}},
+	})
+	resolver := &Resolver{}
+	ctx := framework.InjectResolverConfigToContext(context.Background(), map[string]string{
+		ConfigRevision:          fixtures.DefaultBranch,
+		ConfigAllowedURLSchemes: "http",
+	})
+
+	for _, tc := range []struct {
+		revision        string
+		expectedContent string
+	}{
+		{revision: "", expectedContent: "second"},
+		{revision: "other-branch", expectedContent: "on other branch"},
+		{revision: "v1", expectedContent: "first"},
+		{revision: gitServer.CommitHash("catalog", 0), expectedContent: "first"},
+	} {
+		params := map[string]string{
+			URLParam:  gitServer.RepoURL("catalog"),
+			PathParam: "task/foo.yaml",
+		}
+		if tc.revision != "" {
+			params[RevisionParam] = tc.revision
+		}
+		output, err := resolver.Resolve(ctx, params)
+		if err != nil {
+			t.Fatalf("unexpected error resolving revision %q: %v", tc.revision, err)
+		}
+		if string(output.Data()) != tc.expectedContent {
+			t.Errorf("expected content %q at revision %q, got %q", tc.expectedContent, tc.revision, output.Data())
+		}
+	}
+}
+
+func TestResolveDirectory(t *testing.T) {
+	gitServer := fixtures.NewGitServer(t, fixtures.GitRepo{
+		Name: "catalog",
+		Commits: []fixtures.GitCommit{{
+			Files: map[string]string{
+				"pipelines/build/pipeline.yaml":     "kind: Pipeline",
+				"pipelines/build/clone.yaml":        "kind: Task\n",
+				"pipelines/build/README.md":         "# Build",
+				"pipelines/build/tasks/lint.yml":    "kind: Task\nmetadata:\n  name: lint\n",
+				"pipelines/build/tasks/nested/a.md": "# Nested",
+			},
+		}},
+	})
+	resolver := &Resolver{}
+
+	digest := func(content string) string {
+		sum := sha256.Sum256([]byte(content))
+		return "sha256:" + hex.EncodeToString(sum[:])
+	}
+
+	for _, tc := range []struct {
+		name            string
+		path            string
+		glob            string
+		conf            map[string]string
+		limit           int64
+		expectedContent string
+		expectedFiles   map[string]string
+		expectedErr     string
+	}{{
+		name:            "yaml files in directory",
+		path:            "pipelines/build",
+		expectedContent: "kind: Task\n---\nkind: Pipeline\n",
+		expectedFiles: map[string]string{
+			"pipelines/build/clone.yaml":    digest("kind: Task\n"),
+			"pipelines/build/pipeline.yaml": digest("kind: Pipeline"),
+		},
+	}, {
+		name:            "glob",
+		path:            "/pipelines/build/",
+		glob:            "tasks/*.yml",
+		expectedContent: "kind: Task\nmetadata:\n  name: lint\n",
+		expectedFiles: map[string]string{
+			"pipelines/build/tasks/lint.yml": digest("kind: Task\nmetadata:\n  name: lint\n"),
+		},
+	}, {
+		name:        "no matching files",
+		path:        "pipelines/build",
+		glob:        "*.json",
+		expectedErr: `no files in directory "pipelines/build" match *.json`,
+	}, {
+		name:        "too many files",
+		path:        "pipelines/build",
+		conf:        map[string]string{ConfigMaxDirectoryFiles: "1"},
+		expectedErr: `directory "pipelines/build" has 2 matching files, more than the limit of 1`,
+	}, {
+		name:        "larger than limit",
+		path:        "pipelines/build",
+		limit:       20,
+		expectedErr: "resolved data is larger than the limit of 20 bytes",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			conf := map[string]string{
+				ConfigRevision:          fixtures.DefaultBranch,
+				ConfigAllowedURLSchemes: "http",
+			}
+			for k, v := range tc.conf {
+				conf[k] = v
+			}
+			ctx := framework.InjectResolverConfigToContext(context.Background(), conf)
+			ctx = framework.InjectMaxResolvedDataSize(ctx, tc.limit)
+			params := map[string]string{
+				URLParam:  gitServer.RepoURL("catalog"),
+				PathParam: tc.path,
+			}
+			if tc.glob != "" {
+				params[GlobParam] = tc.glob
+			}
+
+			output, err := resolver.Resolve(ctx, params)
+			if tc.expectedErr != "" {
+				if err == nil || err.Error() != tc.expectedErr {
+					t.Fatalf("expected error %q, got %v", tc.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if d := cmp.Diff(tc.expectedContent, string(output.Data())); d != "" {
+				t.Errorf("unexpected content %s", diff.PrintWantGot(d))
+			}
+			files := map[string]string{}
+			if err := json.Unmarshal([]byte(output.Annotations()[AnnotationKeyFiles]), &files); err != nil {
+				t.Fatalf("error parsing %s annotation: %v", AnnotationKeyFiles, err)
+			}
+			if d := cmp.Diff(tc.expectedFiles, files); d != "" {
+				t.Errorf("unexpected files %s", diff.PrintWantGot(d))
+			}
+		})
+	}
+}
+
+func TestReadDirectoryKeepsErrorReasons(t *testing.T) {
+	filesystem := memfs.New()
+	if err := util.WriteFile(filesystem, "tasks/foo.yaml", []byte("kind: Task\n"), 0o644); err != nil {
+		t.Fatalf("error writing file: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name           string
+		smudgeErr      error
+		expectedReason string
+		expectedErr    string
+	}{{
+		name:           "too large",
+		smudgeErr:      framework.NewErrorResolvedDataTooLarge(5),
+		expectedReason: resolutioncommon.ReasonResolvedDataTooLarge,
+		expectedErr:    "resolved data is larger than the limit of 100 bytes",
+	}, {
+		name:           "other reason",
+		smudgeErr:      resolutioncommon.NewError(resolutioncommon.ReasonPolicyViolation, errors.New("not allowed")),
+		expectedReason: resolutioncommon.ReasonPolicyViolation,
+		expectedErr:    "not allowed",
+	}, {
+		name:           "no reason",
+		smudgeErr:      errors.New("lfs server unavailable"),
+		expectedReason: resolutioncommon.ReasonResolutionFailed,
+		expectedErr:    `error reading file "tasks/foo.yaml": lfs server unavailable`,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := readDirectory(filesystem, "tasks", directoryOptions{
+				globs:    defaultGlobs,
+				maxFiles: defaultMaxDirectoryFiles,
+				maxSize:  100,
+				smudge: func([]byte, int64) ([]byte, error) {
+					return nil, tc.smudgeErr
+				},
+			})
+			reason, reasonErr := resolutioncommon.ReasonError(err)
+			if reason != tc.expectedReason {
+				t.Errorf("expected reason %q, got %q: %v", tc.expectedReason, reason, err)
+			}
+			if reasonErr == nil || reasonErr.Error() != tc.expectedErr {
+				t.Errorf("expected error %q, got %v", tc.expectedErr, reasonErr)
+			}
+		})
+	}
+}
+
+func TestResolveLargerThanLimit(t *testing.T) {
+	gitServer := fixtures.NewGitServer(t, fixtures.GitRepo{
+		Name: "catalog",
+		Commits: []fixtures.GitCommit{{
+			Files: map[string]string{"task/foo.yaml": "some content"},
+		}},
+	})
+	resolver := &Resolver{}
+	ctx := framework.InjectResolverConfigToContext(context.Background(), map[string]string{
+		ConfigRevision:          fixtures.DefaultBranch,
+		ConfigAllowedURLSchemes: "http",
+	})
+	ctx = framework.InjectMaxResolvedDataSize(ctx, 5)
+
+	_, err := resolver.Resolve(ctx, map[string]string{
+		URLParam:  gitServer.RepoURL("catalog"),
+		PathParam: "task/foo.yaml",
+	})
+	reason, _ := resolutioncommon.ReasonError(err)
+	if reason != resolutioncommon.ReasonResolvedDataTooLarge {
+		t.Fatalf("expected error with reason %q, got %q: %v", resolutioncommon.ReasonResolvedDataTooLarge, reason, err)
+	}
+}
+
 func TestController(t *testing.T) {
 	withTemporaryGitConfig(t)
 
@@ -418,6 +657,18 @@ func TestController(t *testing.T) {
 					} else {
 						expectedStatus.Annotations[AnnotationKeyRevision] = plumbing.Master.Short()
 					}
+					// Only results for a commit hash are shared.
+					if plumbing.IsHash(request.Spec.Parameters[RevisionParam]) {
+						expectedStatus.Annotations[resolutioncommon.AnnotationKeyClusterShareable] = "true"
+					}
+					// The framework reports the request as in progress
+					// while it resolves in the background.
+					expectedStatus.Status.Conditions = duckv1.Conditions{{
+						Type:    apis.ConditionSucceeded,
+						Status:  corev1.ConditionUnknown,
+						Reason:  resolutioncommon.ReasonResolutionInProgress,
+						Message: `resolution in progress by resolver "Git"`,
+					}}
 				} else {
 					expectedStatus.Status.Conditions[0].Message = tc.expectedErr.Error()
 				}
@@ -429,8 +680,9 @@ func TestController(t *testing.T) {
 						Namespace: system.Namespace(),
 					},
 					Data: map[string]string{
-						ConfigFieldTimeout: "1m",
-						ConfigRevision:     plumbing.Master.Short(),
+						ConfigFieldTimeout:      "1m",
+						ConfigRevision:          plumbing.Master.Short(),
+						ConfigAllowedURLSchemes: "file",
 					},
 				}},
 				ResolutionRequests: []*v1alpha1.ResolutionRequest{request},
@@ -615,3 +867,23 @@ func createRequest(repoURL, pathInRepo, revision, specificCommit string, useNthC
 
 	return rr
 }
+
+func TestIsClusterShareable(t *testing.T) {
+	for revision, expected := range map[string]bool{
+		"a2ebb34d35df9f67c5bd3d8d5b9bd4f5b51a2a3c": true,
+		"":                        false,
+		"main":                    false,
+		"v0.3.0":                  false,
+		"a2ebb34":                 false,
+		"refs/heads/main":         false,
+		"zzebb34d35df9f67c5bd3d8": false,
+	} {
+		params := map[string]string{URLParam: "https://github.com/tektoncd/catalog.git", PathParam: "task.yaml"}
+		if revision != "" {
+			params[RevisionParam] = revision
+		}
+		if actual := (&Resolver{}).IsClusterShareable(context.Background(), params); actual != expected {
+			t.Errorf("expected revision %q to be shareable: %t, got %t", revision, expected, actual)
+		}
+	}
+}
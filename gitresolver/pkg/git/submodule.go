@@ -0,0 +1,188 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+)
+
+// maxSubmoduleDepth is how deeply submodules may be nested inside each
+// other on the way to pathInRepo.
+const maxSubmoduleDepth = 5
+
+// checkoutSubmodules checks out each submodule, and submodule of a
+// submodule, that pathInRepo is inside of, at the commit recorded by
+// the repository containing it. Their files appear in the worktree of
+// repository like any others. It returns the URL of the repository
+// pathInRepo is finally in. Submodule URLs must be allowed by the
+// resolver's config like any other.
+func checkoutSubmodules(ctx context.Context, repository *git.Repository, repoURL, pathInRepo string, conf map[string]string) (string, error) {
+	for depth := 0; ; depth++ {
+		submoduleConfig, err := submoduleContaining(repository, pathInRepo)
+		if err != nil || submoduleConfig == nil {
+			return repoURL, err
+		}
+		if depth == maxSubmoduleDepth {
+			return "", fmt.Errorf("%s %q is inside more than %d nested submodules", PathParam, pathInRepo, maxSubmoduleDepth)
+		}
+		if repoURL, err = submoduleURL(repoURL, submoduleConfig.URL); err != nil {
+			return "", fmt.Errorf("submodule %q has an invalid url: %w", submoduleConfig.Name, err)
+		}
+		if err := validateRepoURL(repoURL, conf); err != nil {
+			return "", fmt.Errorf("submodule %q: %w", submoduleConfig.Name, err)
+		}
+		if repository, err = checkoutSubmodule(ctx, repository, submoduleConfig, repoURL); err != nil {
+			return "", fmt.Errorf("error checking out submodule %q: %w", submoduleConfig.Name, err)
+		}
+		pathInRepo = strings.TrimPrefix(strings.TrimPrefix(pathInRepo, submoduleConfig.Path), "/")
+	}
+}
+
+// submoduleContaining returns the config, with a clean path, of the
+// submodule of repository that pathInRepo is inside of, or nil if it
+// isn't inside one.
+func submoduleContaining(repository *git.Repository, pathInRepo string) (*config.Submodule, error) {
+	w, err := repository.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	submodules, err := w.Submodules()
+	if err != nil {
+		return nil, fmt.Errorf("error reading submodules: %w", err)
+	}
+	for _, submodule := range submodules {
+		c := submodule.Config()
+		for _, elem := range strings.Split(c.Name, "/") {
+			// The name is used as a path in the repository's
+			// storage, which may be on disk.
+			if elem == ".." {
+				return nil, fmt.Errorf("submodule %q has an invalid name", c.Name)
+			}
+		}
+		submodulePath, err := cleanPathInRepo(c.Path)
+		if err != nil || submodulePath == "/" {
+			return nil, fmt.Errorf("submodule %q has an invalid path %q", c.Name, c.Path)
+		}
+		if pathInRepo == submodulePath || strings.HasPrefix(pathInRepo, submodulePath+"/") {
+			c.Path = submodulePath
+			return c, nil
+		}
+	}
+	return nil, nil
+}
+
+// submoduleURL returns the URL of a submodule, which may be relative to
+// the URL of the repository containing it.
+func submoduleURL(parentURL, rawURL string) (string, error) {
+	if !strings.HasPrefix(rawURL, "./") && !strings.HasPrefix(rawURL, "../") {
+		return rawURL, nil
+	}
+	if strings.Contains(parentURL, "://") {
+		u, err := url.Parse(parentURL)
+		if err != nil {
+			return "", err
+		}
+		u.Path = path.Join(u.Path, rawURL)
+		return u.String(), nil
+	}
+	if scheme, _, err := parseRepoURL(parentURL); err == nil && scheme == "ssh" {
+		// An scp-like URL, e.g. git@github.com:tektoncd/catalog.git.
+		colon := strings.Index(parentURL, ":")
+		return parentURL[:colon+1] + path.Join(parentURL[colon+1:], rawURL), nil
+	}
+	return path.Join(parentURL, rawURL), nil
+}
+
+// checkoutSubmodule fetches the submodule of parent described by c
+// from repoURL and checks out the commit recorded for it.
+func checkoutSubmodule(ctx context.Context, parent *git.Repository, c *config.Submodule, repoURL string) (*git.Repository, error) {
+	idx, err := parent.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+	entry, err := idx.Entry(c.Path)
+	if err != nil {
+		return nil, fmt.Errorf("no commit is recorded for it: %w", err)
+	}
+	parentWorktree, err := parent.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	worktree, err := parentWorktree.Filesystem.Chroot(c.Path)
+	if err != nil {
+		return nil, err
+	}
+	storer, err := parent.Storer.Module(c.Name)
+	if err != nil {
+		return nil, err
+	}
+	repository, err := git.Open(storer, worktree)
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		repository, err = git.Init(storer, worktree)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The submodule's storage may be left from an earlier request if
+	// repositories are cached, so make sure it has the URL resolved
+	// above and no index describing a worktree that's gone.
+	if err := repository.DeleteRemote(git.DefaultRemoteName); err != nil && !errors.Is(err, git.ErrRemoteNotFound) {
+		return nil, err
+	}
+	if _, err := repository.CreateRemote(&config.RemoteConfig{
+		Name: git.DefaultRemoteName,
+		URLs: []string{repoURL},
+	}); err != nil {
+		return nil, err
+	}
+	if err := repository.Storer.SetIndex(&index.Index{Version: 2}); err != nil {
+		return nil, err
+	}
+
+	err = repository.FetchContext(ctx, &git.FetchOptions{})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return nil, err
+	}
+	if _, err := repository.CommitObject(entry.Hash); err != nil {
+		// The commit isn't on any branch, so ask for it by hash,
+		// which not every server allows.
+		refSpec := config.RefSpec(fmt.Sprintf("+%s:%s", entry.Hash, entry.Hash))
+		err := repository.FetchContext(ctx, &git.FetchOptions{RefSpecs: []config.RefSpec{refSpec}})
+		if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+			return nil, err
+		}
+	}
+
+	w, err := repository.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	if err := w.Checkout(&git.CheckoutOptions{Hash: entry.Hash}); err != nil {
+		return nil, err
+	}
+	return repository, nil
+}
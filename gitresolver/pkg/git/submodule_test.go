@@ -0,0 +1,192 @@
+package git
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/google/go-cmp/cmp"
+	"github.com/tektoncd/resolution/pkg/resolver/framework"
+	"github.com/tektoncd/resolution/test/diff"
+	"github.com/tektoncd/resolution/test/fixtures"
+)
+
+func TestResolveSubmodules(t *testing.T) {
+	gitServer := fixtures.NewGitServer(t, fixtures.GitRepo{
+		Name: "shared",
+		Commits: []fixtures.GitCommit{{
+			Files: map[string]string{"task.yaml": "kind: Task\n"},
+		}},
+	}, fixtures.GitRepo{
+		Name: "tasks",
+		Commits: []fixtures.GitCommit{{
+			Files:      map[string]string{"build/task.yaml": "old"},
+			LFSFiles:   map[string]string{"lfs/task.yaml": "kind: Task\nmetadata:\n  name: lfs\n"},
+			Submodules: map[string]string{"vendor/shared": "shared"},
+		}, {
+			Files: map[string]string{"build/task.yaml": "kind: Task\nmetadata:\n  name: build\n"},
+		}},
+	}, fixtures.GitRepo{
+		Name: "catalog",
+		Commits: []fixtures.GitCommit{{
+			Files:      map[string]string{"pipeline.yaml": "kind: Pipeline\n"},
+			Submodules: map[string]string{"third_party/tasks": "tasks"},
+		}},
+	})
+
+	for _, tc := range []struct {
+		name            string
+		path            string
+		conf            map[string]string
+		expectedContent string
+		expectedErr     string
+	}{{
+		name:        "disabled",
+		path:        "third_party/tasks/build/task.yaml",
+		expectedErr: `error opening file "third_party/tasks/build/task.yaml": file does not exist`,
+	}, {
+		name:            "file",
+		path:            "third_party/tasks/build/task.yaml",
+		conf:            map[string]string{ConfigFetchSubmodules: "true"},
+		expectedContent: "kind: Task\nmetadata:\n  name: build\n",
+	}, {
+		name:            "directory",
+		path:            "/third_party/tasks/build/",
+		conf:            map[string]string{ConfigFetchSubmodules: "true"},
+		expectedContent: "kind: Task\nmetadata:\n  name: build\n",
+	}, {
+		name:            "nested",
+		path:            "third_party/tasks/vendor/shared/task.yaml",
+		conf:            map[string]string{ConfigFetchSubmodules: "true"},
+		expectedContent: "kind: Task\n",
+	}, {
+		name:            "LFS",
+		path:            "third_party/tasks/lfs/task.yaml",
+		conf:            map[string]string{ConfigFetchSubmodules: "true", ConfigFetchLFS: "true"},
+		expectedContent: "kind: Task\nmetadata:\n  name: lfs\n",
+	}, {
+		name:            "cached",
+		path:            "third_party/tasks/vendor/shared/task.yaml",
+		conf:            map[string]string{ConfigFetchSubmodules: "true", ConfigCacheDir: t.TempDir()},
+		expectedContent: "kind: Task\n",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			conf := map[string]string{
+				ConfigRevision:          fixtures.DefaultBranch,
+				ConfigAllowedURLSchemes: "http",
+			}
+			for k, v := range tc.conf {
+				conf[k] = v
+			}
+			ctx := framework.InjectResolverConfigToContext(context.Background(), conf)
+			resolver := &Resolver{}
+			params := map[string]string{
+				URLParam:  gitServer.RepoURL("catalog"),
+				PathParam: tc.path,
+			}
+
+			// Resolve twice so that cached clones are reused.
+			for i := 0; i < 2; i++ {
+				output, err := resolver.Resolve(ctx, params)
+				if tc.expectedErr != "" {
+					if err == nil || err.Error() != tc.expectedErr {
+						t.Fatalf("expected error %q, got %v", tc.expectedErr, err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if d := cmp.Diff(tc.expectedContent, string(output.Data())); d != "" {
+					t.Errorf("unexpected content %s", diff.PrintWantGot(d))
+				}
+			}
+		})
+	}
+}
+
+func TestResolveSubmoduleURLNotAllowed(t *testing.T) {
+	repoDir := t.TempDir()
+	repo, err := git.PlainInit(repoDir, false)
+	if err != nil {
+		t.Fatalf("error creating test repo: %v", err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("error getting test worktree: %v", err)
+	}
+	gitmodules := "[submodule \"tasks\"]\n\tpath = tasks\n\turl = http://git.example.com/tasks.git\n"
+	if err := ioutil.WriteFile(filepath.Join(repoDir, ".gitmodules"), []byte(gitmodules), 0600); err != nil {
+		t.Fatalf("error writing .gitmodules: %v", err)
+	}
+	if _, err := worktree.Add(".gitmodules"); err != nil {
+		t.Fatalf("error adding .gitmodules: %v", err)
+	}
+	idx, err := repo.Storer.Index()
+	if err != nil {
+		t.Fatalf("error reading index: %v", err)
+	}
+	entry := idx.Add("tasks")
+	entry.Hash = plumbing.NewHash("0123456789012345678901234567890123456789")
+	entry.Mode = filemode.Submodule
+	if err := repo.Storer.SetIndex(idx); err != nil {
+		t.Fatalf("error writing index: %v", err)
+	}
+	if _, err := worktree.Commit("add submodule", &git.CommitOptions{
+		Author: &object.Signature{Name: "Someone", Email: "someone@example.com", When: time.Now()},
+	}); err != nil {
+		t.Fatalf("error committing: %v", err)
+	}
+
+	ctx := framework.InjectResolverConfigToContext(context.Background(), map[string]string{
+		ConfigRevision:          plumbing.Master.Short(),
+		ConfigAllowedURLSchemes: "file",
+		ConfigFetchSubmodules:   "true",
+	})
+	_, err = (&Resolver{}).Resolve(ctx, map[string]string{
+		URLParam:  repoDir,
+		PathParam: "tasks/task.yaml",
+	})
+	expectedErr := `submodule "tasks": url "http://git.example.com/tasks.git" has scheme "http", which isn't one of the allowed schemes file`
+	if err == nil || err.Error() != expectedErr {
+		t.Fatalf("expected error %q, got %v", expectedErr, err)
+	}
+}
+
+func TestSubmoduleURL(t *testing.T) {
+	for _, tc := range []struct {
+		parent   string
+		url      string
+		expected string
+	}{{
+		parent:   "https://github.com/tektoncd/catalog.git",
+		url:      "../pipeline.git",
+		expected: "https://github.com/tektoncd/pipeline.git",
+	}, {
+		parent:   "https://github.com/tektoncd/catalog.git",
+		url:      "https://example.com/tasks.git",
+		expected: "https://example.com/tasks.git",
+	}, {
+		parent:   "git@github.com:tektoncd/catalog.git",
+		url:      "../../other/tasks.git",
+		expected: "git@github.com:other/tasks.git",
+	}, {
+		parent:   "/repos/catalog",
+		url:      "./tasks",
+		expected: "/repos/catalog/tasks",
+	}} {
+		actual, err := submoduleURL(tc.parent, tc.url)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if actual != tc.expected {
+			t.Errorf("expected %q relative to %q to be %q, got %q", tc.url, tc.parent, tc.expected, actual)
+		}
+	}
+}
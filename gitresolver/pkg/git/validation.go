@@ -0,0 +1,146 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// defaultAllowedURLSchemes are the schemes repos may be fetched with
+// unless the resolver's config says otherwise. Local paths are treated
+// as having the file scheme.
+var defaultAllowedURLSchemes = []string{"https", "ssh", "git"}
+
+// validateRepoURL returns an error if rawURL isn't a well-formed URL of
+// a repo that the resolver's config allows fetching from. As well as
+// URLs with a scheme, git's scp-like syntax for SSH URLs, e.g.
+// git@github.com:tektoncd/catalog.git, is accepted.
+func validateRepoURL(rawURL string, conf map[string]string) error {
+	scheme, host, err := parseRepoURL(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid %s %q: %w", URLParam, rawURL, err)
+	}
+
+	allowedSchemes := defaultAllowedURLSchemes
+	if val, ok := conf[ConfigAllowedURLSchemes]; ok {
+		allowedSchemes = splitConfigList(val)
+	}
+	if !contains(allowedSchemes, scheme) {
+		return fmt.Errorf("%s %q has scheme %q, which isn't one of the allowed schemes %s", URLParam, rawURL, scheme, strings.Join(allowedSchemes, ", "))
+	}
+
+	if denied := splitConfigList(conf[ConfigDeniedHosts]); hostMatches(host, denied) {
+		return fmt.Errorf("%s %q has host %q, which is denied", URLParam, rawURL, host)
+	}
+	if allowed := splitConfigList(conf[ConfigAllowedHosts]); len(allowed) > 0 && !hostMatches(host, allowed) {
+		return fmt.Errorf("%s %q has host %q, which isn't one of the allowed hosts %s", URLParam, rawURL, host, strings.Join(allowed, ", "))
+	}
+	return nil
+}
+
+// parseRepoURL returns the lower-cased scheme and host of a repo URL.
+func parseRepoURL(rawURL string) (string, string, error) {
+	if strings.TrimSpace(rawURL) != rawURL {
+		return "", "", errors.New("leading or trailing whitespace")
+	}
+	if !strings.Contains(rawURL, "://") {
+		// Like git, treat a colon before any slash as the end of
+		// the host in an scp-like URL and anything else as a
+		// local path.
+		colon := strings.Index(rawURL, ":")
+		if colon > 0 && !strings.Contains(rawURL[:colon], "/") {
+			host := rawURL[:colon]
+			if at := strings.LastIndex(host, "@"); at >= 0 {
+				host = host[at+1:]
+			}
+			if host == "" {
+				return "", "", errors.New("missing host")
+			}
+			return "ssh", strings.ToLower(host), nil
+		}
+		return "file", "", nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", "", err
+	}
+	scheme := strings.ToLower(u.Scheme)
+	if scheme != "file" && u.Hostname() == "" {
+		return "", "", errors.New("missing host")
+	}
+	return scheme, strings.ToLower(u.Hostname()), nil
+}
+
+// hostMatches returns true if host is one of patterns. A pattern
+// starting with "*." also matches any subdomain of the rest of it.
+func hostMatches(host string, patterns []string) bool {
+	if host == "" {
+		return false
+	}
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if host == pattern || strings.HasPrefix(pattern, "*.") && strings.HasSuffix(host, pattern[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanPathInRepo returns pathInRepo relative to the root of the repo
+// with any redundant elements removed. Paths with ".." elements are
+// rejected, so it can't name anything outside the repo.
+func cleanPathInRepo(pathInRepo string) (string, error) {
+	for _, elem := range strings.Split(pathInRepo, "/") {
+		if elem == ".." {
+			return "", fmt.Errorf("%s %q must not contain \"..\"", PathParam, pathInRepo)
+		}
+	}
+	if strings.ContainsRune(pathInRepo, 0) {
+		return "", fmt.Errorf("%s %q must not contain NUL characters", PathParam, pathInRepo)
+	}
+	cleaned := strings.TrimPrefix(path.Clean("/"+pathInRepo), "/")
+	if cleaned == "" {
+		return "/", nil
+	}
+	return cleaned, nil
+}
+
+// splitConfigList returns the non-empty elements of a comma separated
+// list from the resolver's config.
+func splitConfigList(val string) []string {
+	elems := []string{}
+	for _, elem := range strings.Split(val, ",") {
+		if elem = strings.TrimSpace(elem); elem != "" {
+			elems = append(elems, elem)
+		}
+	}
+	return elems
+}
+
+func contains(list []string, s string) bool {
+	for _, elem := range list {
+		if strings.EqualFold(elem, s) {
+			return true
+		}
+	}
+	return false
+}
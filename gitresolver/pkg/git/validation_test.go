@@ -0,0 +1,137 @@
+package git
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tektoncd/resolution/pkg/resolver/framework"
+)
+
+func TestValidateParamsURL(t *testing.T) {
+	for _, tc := range []struct {
+		url         string
+		conf        map[string]string
+		expectedErr string
+	}{{
+		url: "https://github.com/tektoncd/catalog.git",
+	}, {
+		url: "ssh://git@github.com/tektoncd/catalog.git",
+	}, {
+		url: "git@github.com:tektoncd/catalog.git",
+	}, {
+		url: "git://example.com/catalog.git",
+	}, {
+		url:         "http://github.com/tektoncd/catalog.git",
+		expectedErr: `url "http://github.com/tektoncd/catalog.git" has scheme "http", which isn't one of the allowed schemes https, ssh, git`,
+	}, {
+		url:         "file:///etc/secrets",
+		expectedErr: `url "file:///etc/secrets" has scheme "file", which isn't one of the allowed schemes https, ssh, git`,
+	}, {
+		url:         "/var/run/secrets/repo",
+		expectedErr: `url "/var/run/secrets/repo" has scheme "file", which isn't one of the allowed schemes https, ssh, git`,
+	}, {
+		url:  "/var/run/secrets/repo",
+		conf: map[string]string{ConfigAllowedURLSchemes: "https, file"},
+	}, {
+		url:         "https:///catalog.git",
+		expectedErr: `invalid url "https:///catalog.git": missing host`,
+	}, {
+		url:         " https://github.com/tektoncd/catalog.git",
+		expectedErr: `invalid url " https://github.com/tektoncd/catalog.git": leading or trailing whitespace`,
+	}, {
+		url:         "https://github.com/tektoncd/catalog.git",
+		conf:        map[string]string{ConfigDeniedHosts: "*.internal, GitHub.com"},
+		expectedErr: `url "https://github.com/tektoncd/catalog.git" has host "github.com", which is denied`,
+	}, {
+		url:         "https://git.corp.internal/catalog.git",
+		conf:        map[string]string{ConfigDeniedHosts: "*.internal"},
+		expectedErr: `url "https://git.corp.internal/catalog.git" has host "git.corp.internal", which is denied`,
+	}, {
+		url:  "git@git.example.com:catalog.git",
+		conf: map[string]string{ConfigAllowedHosts: "github.com,*.example.com"},
+	}, {
+		url:         "https://gitlab.com/catalog.git",
+		conf:        map[string]string{ConfigAllowedHosts: "github.com,*.example.com"},
+		expectedErr: `url "https://gitlab.com/catalog.git" has host "gitlab.com", which isn't one of the allowed hosts github.com, *.example.com`,
+	}} {
+		t.Run(tc.url, func(t *testing.T) {
+			ctx := framework.InjectResolverConfigToContext(context.Background(), tc.conf)
+			err := (&Resolver{}).ValidateParams(ctx, map[string]string{
+				URLParam:  tc.url,
+				PathParam: "task.yaml",
+			})
+			if tc.expectedErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tc.expectedErr {
+				t.Fatalf("expected error %q, got %v", tc.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateParamsPathInRepo(t *testing.T) {
+	for _, tc := range []struct {
+		path        string
+		expectedErr string
+	}{{
+		path: "/task/golang-build/0.3/golang-build.yaml",
+	}, {
+		path: "task/./golang-build//0.3/",
+	}, {
+		path: "task/..golang-build/build.yaml",
+	}, {
+		path:        "../../etc/passwd",
+		expectedErr: `pathInRepo "../../etc/passwd" must not contain ".."`,
+	}, {
+		path:        "task/../../secret.yaml",
+		expectedErr: `pathInRepo "task/../../secret.yaml" must not contain ".."`,
+	}} {
+		t.Run(tc.path, func(t *testing.T) {
+			err := (&Resolver{}).ValidateParams(context.Background(), map[string]string{
+				PathParam: tc.path,
+			})
+			if tc.expectedErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tc.expectedErr {
+				t.Fatalf("expected error %q, got %v", tc.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestCleanPathInRepo(t *testing.T) {
+	for path, expected := range map[string]string{
+		"/task/build.yaml":     "task/build.yaml",
+		"task/./build//a.yaml": "task/build/a.yaml",
+		"pipelines/build/":     "pipelines/build",
+		"/":                    "/",
+	} {
+		cleaned, err := cleanPathInRepo(path)
+		if err != nil {
+			t.Fatalf("unexpected error cleaning %q: %v", path, err)
+		}
+		if cleaned != expected {
+			t.Errorf("expected %q to be cleaned to %q, got %q", path, expected, cleaned)
+		}
+	}
+}
+
+func TestResolveValidatesDefaultURL(t *testing.T) {
+	ctx := framework.InjectResolverConfigToContext(context.Background(), map[string]string{
+		ConfigURL:      "file:///var/run/secrets",
+		ConfigRevision: "main",
+	})
+	_, err := (&Resolver{}).Resolve(ctx, map[string]string{PathParam: "task.yaml"})
+	expectedErr := `url "file:///var/run/secrets" has scheme "file", which isn't one of the allowed schemes https, ssh, git`
+	if err == nil || err.Error() != expectedErr {
+		t.Fatalf("expected error %q, got %v", expectedErr, err)
+	}
+}
@@ -0,0 +1,322 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	resolutioncommon "github.com/tektoncd/resolution/pkg/common"
+	"golang.org/x/crypto/ssh"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+)
+
+const (
+	beginPGPSignature = "-----BEGIN PGP SIGNATURE-----"
+	beginSSHSignature = "-----BEGIN SSH SIGNATURE-----"
+	endSSHSignature   = "-----END SSH SIGNATURE-----"
+
+	// sshSignatureMagic starts every SSH signature and the data it
+	// signs.
+	sshSignatureMagic = "SSHSIG"
+
+	// sshSignatureNamespace is the namespace git signs commits in
+	// with SSH keys.
+	sshSignatureNamespace = "git"
+)
+
+// verificationOptions say whether and how the commit being resolved is
+// verified.
+type verificationOptions struct {
+	keys              *trustedKeys
+	protectedBranches []string
+}
+
+// verificationOptionsFromConfig returns the options for verifying
+// commits set in the resolver's config, or nil if commits aren't
+// verified. Keys in the Secret named by the config are read from
+// secrets, which lists the resolver's namespace.
+func verificationOptionsFromConfig(conf map[string]string, secrets corev1listers.SecretNamespaceLister) (*verificationOptions, error) {
+	if conf[ConfigVerifySignatures] != "true" {
+		return nil, nil
+	}
+	gpgKeys := conf[ConfigTrustedGPGKeys]
+	sshKeys := conf[ConfigTrustedSSHKeys]
+	if secretName := conf[ConfigTrustedKeysSecret]; secretName != "" {
+		if secrets == nil {
+			return nil, fmt.Errorf("trusted keys secret %q can't be read without a connection to the cluster", secretName)
+		}
+		secret, err := secrets.Get(secretName)
+		if err != nil {
+			return nil, fmt.Errorf("error getting trusted keys secret %q: %w", secretName, err)
+		}
+		gpgKeys += "\n" + string(secret.Data[ConfigTrustedGPGKeys])
+		sshKeys += "\n" + string(secret.Data[ConfigTrustedSSHKeys])
+	}
+	keys, err := parseTrustedKeys(gpgKeys, sshKeys)
+	if err != nil {
+		return nil, err
+	}
+	return &verificationOptions{
+		keys:              keys,
+		protectedBranches: splitConfigList(conf[ConfigProtectedBranches]),
+	}, nil
+}
+
+// signer identifies who signed a commit.
+type signer struct {
+	// identity is the user ID of a GPG key or the comment of a
+	// trusted SSH key.
+	identity string
+
+	// key is the fingerprint of the key the commit was signed with.
+	key string
+}
+
+// verifyCommit checks that the commit was signed by a trusted key and,
+// if there are protected branches, that it's on one of them.
+func verifyCommit(repository *git.Repository, commit *object.Commit, opts *verificationOptions) (*signer, error) {
+	s, err := opts.keys.verify(commit)
+	if err != nil {
+		return nil, commitVerificationError(fmt.Errorf("commit %s: %w", commit.Hash, err))
+	}
+	if len(opts.protectedBranches) == 0 {
+		return s, nil
+	}
+	for _, branch := range opts.protectedBranches {
+		ref, err := repository.Reference(plumbing.NewRemoteReferenceName(git.DefaultRemoteName, branch), true)
+		if err != nil {
+			continue
+		}
+		tip, err := repository.CommitObject(ref.Hash())
+		if err != nil {
+			return nil, fmt.Errorf("error reading tip of branch %q: %w", branch, err)
+		}
+		onBranch, err := commit.IsAncestor(tip)
+		if err != nil {
+			return nil, fmt.Errorf("error checking whether commit %s is on branch %q: %w", commit.Hash, branch, err)
+		}
+		if onBranch {
+			return s, nil
+		}
+	}
+	return nil, commitVerificationError(fmt.Errorf("commit %s is not on any of the protected branches %s", commit.Hash, strings.Join(opts.protectedBranches, ", ")))
+}
+
+func commitVerificationError(err error) error {
+	return resolutioncommon.NewError(resolutioncommon.ReasonCommitVerificationFailed, err)
+}
+
+// trustedKeys are the keys commits may be signed with.
+type trustedKeys struct {
+	gpg openpgp.EntityList
+	ssh []trustedSSHKey
+}
+
+type trustedSSHKey struct {
+	key     ssh.PublicKey
+	comment string
+}
+
+// parseTrustedKeys parses armored GPG public keys, any number of which
+// may be concatenated, and SSH public keys in authorized_keys format.
+func parseTrustedKeys(gpgKeys, sshKeys string) (*trustedKeys, error) {
+	keys := &trustedKeys{}
+	reader := strings.NewReader(gpgKeys)
+	for {
+		block, err := armor.Decode(reader)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading trusted GPG keys: %w", err)
+		}
+		if block.Type != openpgp.PublicKeyType {
+			return nil, fmt.Errorf("error reading trusted GPG keys: unexpected %q block", block.Type)
+		}
+		entities, err := openpgp.ReadKeyRing(block.Body)
+		if err != nil {
+			return nil, fmt.Errorf("error reading trusted GPG keys: %w", err)
+		}
+		keys.gpg = append(keys.gpg, entities...)
+	}
+
+	rest := []byte(sshKeys)
+	for len(bytes.TrimSpace(rest)) > 0 {
+		key, comment, _, next, err := ssh.ParseAuthorizedKey(rest)
+		if err != nil {
+			return nil, fmt.Errorf("error reading trusted SSH keys: %w", err)
+		}
+		keys.ssh = append(keys.ssh, trustedSSHKey{key: key, comment: comment})
+		rest = next
+	}
+
+	if len(keys.gpg) == 0 && len(keys.ssh) == 0 {
+		return nil, errors.New("signature verification is enabled but there are no trusted keys")
+	}
+	return keys, nil
+}
+
+// verify checks the commit's signature against the trusted keys.
+func (k *trustedKeys) verify(commit *object.Commit) (*signer, error) {
+	signature := commit.PGPSignature
+	if signature == "" {
+		return nil, errors.New("not signed")
+	}
+	encoded := &plumbing.MemoryObject{}
+	if err := commit.EncodeWithoutSignature(encoded); err != nil {
+		return nil, err
+	}
+	reader, err := encoded.Reader()
+	if err != nil {
+		return nil, err
+	}
+	payload, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case strings.HasPrefix(signature, beginPGPSignature):
+		return k.verifyGPG(payload, signature)
+	case strings.HasPrefix(signature, beginSSHSignature):
+		return k.verifySSH(payload, signature)
+	default:
+		return nil, errors.New("signature is neither a GPG nor an SSH signature")
+	}
+}
+
+func (k *trustedKeys) verifyGPG(payload []byte, signature string) (*signer, error) {
+	if len(k.gpg) == 0 {
+		return nil, errors.New("signed with a GPG key but no GPG keys are trusted")
+	}
+	entity, err := openpgp.CheckArmoredDetachedSignature(k.gpg, bytes.NewReader(payload), strings.NewReader(signature), nil)
+	if err != nil {
+		return nil, fmt.Errorf("GPG signature can't be verified with a trusted key: %w", err)
+	}
+	s := &signer{key: strings.ToUpper(hex.EncodeToString(entity.PrimaryKey.Fingerprint[:]))}
+	if identity := entity.PrimaryIdentity(); identity != nil {
+		s.identity = identity.Name
+	}
+	if s.identity == "" {
+		s.identity = s.key
+	}
+	return s, nil
+}
+
+// sshSignature is the content of an armored SSH signature, following
+// its magic preamble, as described by OpenSSH's PROTOCOL.sshsig.
+type sshSignature struct {
+	Version       uint32
+	PublicKey     []byte
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Signature     []byte
+}
+
+// sshSignedData is what is actually signed by an SSH signature.
+type sshSignedData struct {
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Hash          []byte
+}
+
+func (k *trustedKeys) verifySSH(payload []byte, signature string) (*signer, error) {
+	if len(k.ssh) == 0 {
+		return nil, errors.New("signed with an SSH key but no SSH keys are trusted")
+	}
+	encoded := strings.TrimSpace(signature)
+	encoded = strings.TrimPrefix(encoded, beginSSHSignature)
+	encoded = strings.TrimSuffix(encoded, endSSHSignature)
+	blob, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(encoded), ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SSH signature: %w", err)
+	}
+	if !bytes.HasPrefix(blob, []byte(sshSignatureMagic)) {
+		return nil, errors.New("invalid SSH signature: missing preamble")
+	}
+	var sig sshSignature
+	if err := ssh.Unmarshal(blob[len(sshSignatureMagic):], &sig); err != nil {
+		return nil, fmt.Errorf("invalid SSH signature: %w", err)
+	}
+	if sig.Version != 1 {
+		return nil, fmt.Errorf("unsupported SSH signature version %d", sig.Version)
+	}
+	if sig.Namespace != sshSignatureNamespace {
+		return nil, fmt.Errorf("SSH signature is for namespace %q, not %q", sig.Namespace, sshSignatureNamespace)
+	}
+	publicKey, err := ssh.ParsePublicKey(sig.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SSH signature: %w", err)
+	}
+
+	var trusted *trustedSSHKey
+	for i := range k.ssh {
+		if bytes.Equal(k.ssh[i].key.Marshal(), publicKey.Marshal()) {
+			trusted = &k.ssh[i]
+			break
+		}
+	}
+	if trusted == nil {
+		return nil, fmt.Errorf("signed with SSH key %s which isn't trusted", ssh.FingerprintSHA256(publicKey))
+	}
+
+	var h hash.Hash
+	switch sig.HashAlgorithm {
+	case "sha256":
+		h = sha256.New()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return nil, fmt.Errorf("unsupported SSH signature hash algorithm %q", sig.HashAlgorithm)
+	}
+	h.Write(payload)
+	signed := append([]byte(sshSignatureMagic), ssh.Marshal(sshSignedData{
+		Namespace:     sig.Namespace,
+		Reserved:      sig.Reserved,
+		HashAlgorithm: sig.HashAlgorithm,
+		Hash:          h.Sum(nil),
+	})...)
+	var wireSignature ssh.Signature
+	if err := ssh.Unmarshal(sig.Signature, &wireSignature); err != nil {
+		return nil, fmt.Errorf("invalid SSH signature: %w", err)
+	}
+	if err := publicKey.Verify(signed, &wireSignature); err != nil {
+		return nil, fmt.Errorf("SSH signature can't be verified: %w", err)
+	}
+
+	s := &signer{identity: trusted.comment, key: ssh.FingerprintSHA256(publicKey)}
+	if s.identity == "" {
+		s.identity = s.key
+	}
+	return s, nil
+}
@@ -0,0 +1,269 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/google/go-cmp/cmp"
+	resolutioncommon "github.com/tektoncd/resolution/pkg/common"
+	ttesting "github.com/tektoncd/resolution/pkg/reconciler/testing"
+	"github.com/tektoncd/resolution/pkg/resolver/framework"
+	"github.com/tektoncd/resolution/test/diff"
+	"github.com/tektoncd/resolution/test/fixtures"
+	"golang.org/x/crypto/ssh"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakekubeclient "knative.dev/pkg/client/injection/kube/client/fake"
+	"knative.dev/pkg/system"
+)
+
+func TestResolveVerifiesSignatures(t *testing.T) {
+	gpgEntity, err := openpgp.NewEntity("Tekton Test", "", "signer@example.com", nil)
+	if err != nil {
+		t.Fatalf("error generating GPG key: %v", err)
+	}
+	gpgKey := armoredPublicKey(t, gpgEntity)
+	gpgFingerprint := strings.ToUpper(hex.EncodeToString(gpgEntity.PrimaryKey.Fingerprint[:]))
+
+	sshSigner, sshKey := newSSHKey(t, "jane@example.com")
+	untrustedSigner, _ := newSSHKey(t, "")
+
+	gitServer := fixtures.NewGitServer(t, fixtures.GitRepo{
+		Name: "catalog",
+		Commits: []fixtures.GitCommit{{
+			Files: map[string]string{"task.yaml": "unsigned"},
+			Tag:   "unsigned",
+		}, {
+			Files: map[string]string{"task.yaml": "signed with gpg"},
+			Tag:   "gpg",
+			Sign:  fixtures.GPGSigner(gpgEntity),
+		}, {
+			Files: map[string]string{"task.yaml": "signed with untrusted ssh key"},
+			Tag:   "untrusted",
+			Sign:  fixtures.SSHSigner(untrustedSigner),
+		}, {
+			Branch: "feature",
+			Files:  map[string]string{"task.yaml": "signed on feature branch"},
+			Sign:   fixtures.SSHSigner(sshSigner),
+		}, {
+			Files: map[string]string{"task.yaml": "signed with ssh"},
+			Sign:  fixtures.SSHSigner(sshSigner),
+		}},
+	})
+
+	ctx, _ := ttesting.SetupFakeContext(t)
+	if _, err := fakekubeclient.Get(ctx).CoreV1().Secrets(system.Namespace()).Create(ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "trusted-keys", Namespace: system.Namespace()},
+		Data:       map[string][]byte{ConfigTrustedGPGKeys: []byte(gpgKey)},
+	}, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("error creating secret: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name                string
+		revision            string
+		conf                map[string]string
+		expectedAnnotations map[string]string
+		expectedErr         string
+	}{{
+		name:     "verification disabled",
+		revision: "unsigned",
+		conf:     map[string]string{},
+	}, {
+		name:     "gpg",
+		revision: "gpg",
+		conf:     map[string]string{ConfigTrustedGPGKeys: gpgKey},
+		expectedAnnotations: map[string]string{
+			AnnotationKeySigner:    "Tekton Test <signer@example.com>",
+			AnnotationKeySignerKey: gpgFingerprint,
+		},
+	}, {
+		name:     "gpg key from secret",
+		revision: "gpg",
+		conf:     map[string]string{ConfigTrustedKeysSecret: "trusted-keys"},
+		expectedAnnotations: map[string]string{
+			AnnotationKeySigner:    "Tekton Test <signer@example.com>",
+			AnnotationKeySignerKey: gpgFingerprint,
+		},
+	}, {
+		name:     "ssh",
+		revision: fixtures.DefaultBranch,
+		conf:     map[string]string{ConfigTrustedSSHKeys: sshKey},
+		expectedAnnotations: map[string]string{
+			AnnotationKeySigner:    "jane@example.com",
+			AnnotationKeySignerKey: ssh.FingerprintSHA256(sshSigner.PublicKey()),
+		},
+	}, {
+		name:     "on protected branch",
+		revision: fixtures.DefaultBranch,
+		conf: map[string]string{
+			ConfigTrustedSSHKeys:    sshKey,
+			ConfigProtectedBranches: "release, " + fixtures.DefaultBranch,
+		},
+		expectedAnnotations: map[string]string{
+			AnnotationKeySigner:    "jane@example.com",
+			AnnotationKeySignerKey: ssh.FingerprintSHA256(sshSigner.PublicKey()),
+		},
+	}, {
+		name:        "unsigned",
+		revision:    "unsigned",
+		conf:        map[string]string{ConfigTrustedGPGKeys: gpgKey, ConfigTrustedSSHKeys: sshKey},
+		expectedErr: "commit " + gitServer.CommitHash("catalog", 0) + ": not signed",
+	}, {
+		name:        "untrusted key",
+		revision:    "untrusted",
+		conf:        map[string]string{ConfigTrustedGPGKeys: gpgKey, ConfigTrustedSSHKeys: sshKey},
+		expectedErr: "commit " + gitServer.CommitHash("catalog", 2) + ": signed with SSH key " + ssh.FingerprintSHA256(untrustedSigner.PublicKey()) + " which isn't trusted",
+	}, {
+		name:        "wrong kind of key",
+		revision:    "gpg",
+		conf:        map[string]string{ConfigTrustedSSHKeys: sshKey},
+		expectedErr: "commit " + gitServer.CommitHash("catalog", 1) + ": signed with a GPG key but no GPG keys are trusted",
+	}, {
+		name:     "not on protected branch",
+		revision: "feature",
+		conf: map[string]string{
+			ConfigTrustedSSHKeys:    sshKey,
+			ConfigProtectedBranches: fixtures.DefaultBranch,
+		},
+		expectedErr: "commit " + gitServer.CommitHash("catalog", 3) + " is not on any of the protected branches " + fixtures.DefaultBranch,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			conf := map[string]string{
+				ConfigRevision:          fixtures.DefaultBranch,
+				ConfigAllowedURLSchemes: "http",
+			}
+			if len(tc.conf) > 0 {
+				conf[ConfigVerifySignatures] = "true"
+			}
+			for k, v := range tc.conf {
+				conf[k] = v
+			}
+			resolver := &Resolver{}
+			if err := resolver.Initialize(ctx); err != nil {
+				t.Fatalf("error initializing resolver: %v", err)
+			}
+			output, err := resolver.Resolve(framework.InjectResolverConfigToContext(ctx, conf), map[string]string{
+				URLParam:      gitServer.RepoURL("catalog"),
+				PathParam:     "task.yaml",
+				RevisionParam: tc.revision,
+			})
+			if tc.expectedErr != "" {
+				if err == nil || err.Error() != tc.expectedErr {
+					t.Fatalf("expected error %q, got %v", tc.expectedErr, err)
+				}
+				if reason, _ := resolutioncommon.ReasonError(err); reason != resolutioncommon.ReasonCommitVerificationFailed {
+					t.Errorf("expected reason %q, got %q", resolutioncommon.ReasonCommitVerificationFailed, reason)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			annotations := output.Annotations()
+			for _, key := range []string{AnnotationKeySigner, AnnotationKeySignerKey} {
+				if value, ok := annotations[key]; ok {
+					if tc.expectedAnnotations == nil {
+						t.Errorf("unexpected %s annotation %q", key, value)
+					}
+				}
+			}
+			for key, expected := range tc.expectedAnnotations {
+				if d := cmp.Diff(expected, annotations[key]); d != "" {
+					t.Errorf("unexpected %s annotation %s", key, diff.PrintWantGot(d))
+				}
+			}
+		})
+	}
+}
+
+func TestResolveWithoutTrustedKeys(t *testing.T) {
+	gitServer := fixtures.NewGitServer(t, fixtures.GitRepo{
+		Name:    "catalog",
+		Commits: []fixtures.GitCommit{{Files: map[string]string{"task.yaml": "content"}}},
+	})
+	ctx := framework.InjectResolverConfigToContext(context.Background(), map[string]string{
+		ConfigRevision:          fixtures.DefaultBranch,
+		ConfigVerifySignatures:  "true",
+		ConfigAllowedURLSchemes: "http",
+	})
+	resolver := &Resolver{}
+	_, err := resolver.Resolve(ctx, map[string]string{
+		URLParam:  gitServer.RepoURL("catalog"),
+		PathParam: "task.yaml",
+	})
+	expectedErr := "signature verification is enabled but there are no trusted keys"
+	if err == nil || err.Error() != expectedErr {
+		t.Fatalf("expected error %q, got %v", expectedErr, err)
+	}
+}
+
+// TestResolveTrustedKeysSecretOutsideCluster checks that a resolver
+// initialized without a Kubernetes client, as when resolving locally,
+// reports that it can't read the trusted keys secret.
+func TestResolveTrustedKeysSecretOutsideCluster(t *testing.T) {
+	gitServer := fixtures.NewGitServer(t, fixtures.GitRepo{
+		Name:    "catalog",
+		Commits: []fixtures.GitCommit{{Files: map[string]string{"task.yaml": "content"}}},
+	})
+	resolver := &Resolver{}
+	if err := resolver.Initialize(context.Background()); err != nil {
+		t.Fatalf("error initializing resolver: %v", err)
+	}
+	ctx := framework.InjectResolverConfigToContext(context.Background(), map[string]string{
+		ConfigRevision:          fixtures.DefaultBranch,
+		ConfigVerifySignatures:  "true",
+		ConfigTrustedKeysSecret: "trusted-keys",
+		ConfigAllowedURLSchemes: "http",
+	})
+	_, err := resolver.Resolve(ctx, map[string]string{
+		URLParam:  gitServer.RepoURL("catalog"),
+		PathParam: "task.yaml",
+	})
+	expectedErr := `trusted keys secret "trusted-keys" can't be read without a connection to the cluster`
+	if err == nil || err.Error() != expectedErr {
+		t.Fatalf("expected error %q, got %v", expectedErr, err)
+	}
+}
+
+func armoredPublicKey(t *testing.T, entity *openpgp.Entity) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatalf("error armoring GPG key: %v", err)
+	}
+	if err := entity.Serialize(w); err != nil {
+		t.Fatalf("error serializing GPG key: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("error armoring GPG key: %v", err)
+	}
+	return buf.String()
+}
+
+// newSSHKey generates an SSH key, returning a signer for it and its
+// public key in authorized_keys format with the given comment.
+func newSSHKey(t *testing.T, comment string) (ssh.Signer, string) {
+	t.Helper()
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("error generating SSH key: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(privateKey)
+	if err != nil {
+		t.Fatalf("error creating SSH signer: %v", err)
+	}
+	authorizedKey := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(signer.PublicKey())))
+	if comment != "" {
+		authorizedKey += " " + comment
+	}
+	return signer, authorizedKey + "\n"
+}
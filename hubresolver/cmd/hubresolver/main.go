@@ -38,6 +38,6 @@ func main() {
 	fmt.Println("RUNNING WITH HUB URL PATTERN:", hubURL)
 	resolver := hub.Resolver{HubURL: hubURL}
 	sharedmain.Main("controller",
-		framework.NewController(context.Background(), &resolver),
+		framework.NewController(context.Background(), &resolver, framework.WithContentPolicyFromConfigMap(framework.ContentPolicyConfigMapName)),
 	)
 }
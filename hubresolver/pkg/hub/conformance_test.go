@@ -0,0 +1,42 @@
+package hub
+
+import (
+	"context"
+	"testing"
+
+	frtesting "github.com/tektoncd/resolution/pkg/resolver/framework/testing"
+	"github.com/tektoncd/resolution/test/fixtures"
+)
+
+func TestConformance(t *testing.T) {
+	hub := fixtures.NewHub(t, fixtures.HubResource{
+		Catalog: "tekton",
+		Kind:    "task",
+		Name:    "foo",
+		Version: "0.1",
+		YAML:    "some content",
+	}, fixtures.HubResource{
+		Catalog: "tekton",
+		Kind:    "task",
+		Name:    "blocking",
+		Version: "0.1",
+		Hang:    true,
+	})
+
+	frtesting.RunConformanceTests(context.Background(), t, frtesting.ConformanceSpec{
+		Resolver: &Resolver{HubURL: hub.URL + "/" + YamlEndpoint},
+		Config: map[string]string{
+			ConfigCatalog: "tekton",
+			ConfigKind:    "task",
+		},
+		ValidParams: map[string]string{
+			ParamName:    "foo",
+			ParamVersion: "0.1",
+		},
+		RequiredParams: []string{ParamName, ParamVersion},
+		BlockingParams: map[string]string{
+			ParamName:    "blocking",
+			ParamVersion: "0.1",
+		},
+	})
+}
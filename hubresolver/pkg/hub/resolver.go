@@ -18,8 +18,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
+	"regexp"
 
 	"github.com/tektoncd/resolution/pkg/common"
 	"github.com/tektoncd/resolution/pkg/resolver/framework"
@@ -29,6 +29,14 @@ import (
 // resolution.tekton.dev/type label on resource requests
 const LabelValueHubResolverType string = "hub"
 
+// pinnedVersionPattern matches versions that always refer to the same
+// release of a resource.
+var pinnedVersionPattern = regexp.MustCompile(`^v?[0-9]+(\.[0-9]+)+$`)
+
+// responseOverhead is the room allowed in a response from the hub for
+// the JSON surrounding a resource's YAML.
+const responseOverhead = 4096
+
 // Resolver implements a framework.Resolver that can fetch files from OCI bundles.
 type Resolver struct {
 	// HubURL is the URL for hub resolver
@@ -57,6 +65,17 @@ func (r *Resolver) GetSelector(context.Context) map[string]string {
 	}
 }
 
+var _ framework.ClusterShareable = &Resolver{}
+
+// IsClusterShareable returns true if the request is for a pinned
+// version, e.g. 0.3 or 0.3.1, of a resource. Resources are fetched from
+// the Tekton Hub without any namespace-scoped credentials and a
+// published version doesn't change, so every namespace gets the same
+// result for the same parameters.
+func (r *Resolver) IsClusterShareable(_ context.Context, params map[string]string) bool {
+	return pinnedVersionPattern.MatchString(params[ParamVersion])
+}
+
 // ValidateParams ensures parameters from a request are as expected.
 func (r *Resolver) ValidateParams(ctx context.Context, params map[string]string) error {
 	if _, ok := params[ParamName]; !ok {
@@ -84,9 +103,10 @@ type hubResponse struct {
 // Resolve uses the given params to resolve the requested file or resource.
 func (r *Resolver) Resolve(ctx context.Context, params map[string]string) (framework.ResolvedResource, error) {
 	conf := framework.GetResolverConfigFromContext(ctx)
-	if _, ok := params[ParamCatalog]; !ok {
+	catalog, ok := params[ParamCatalog]
+	if !ok {
 		if catalogString, ok := conf[ConfigCatalog]; ok {
-			params[ParamCatalog] = catalogString
+			catalog = catalogString
 		} else {
 			return nil, fmt.Errorf("default catalog was not set during installation of the hub resolver")
 		}
@@ -104,16 +124,29 @@ func (r *Resolver) Resolve(ctx context.Context, params map[string]string) (frame
 		return nil, fmt.Errorf("kind param must be task or pipeline")
 	}
 
-	params[ParamKind] = kind
-	url := fmt.Sprintf(r.HubURL, params[ParamCatalog], params[ParamKind], params[ParamName], params[ParamVersion])
-	// #nosec G107 -- URL cannot be constant in this case.
-	resp, err := http.Get(url)
+	url := fmt.Sprintf(r.HubURL, catalog, kind, params[ParamName], params[ParamVersion])
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request to hub: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("error requesting resource from hub: %w", err)
 	}
 	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+	// The YAML is escaped inside the JSON response so the body is
+	// allowed to be somewhat larger than the limit on the YAML itself.
+	limit := framework.MaxResolvedDataSize(ctx)
+	bodyLimit := limit
+	if limit > 0 {
+		bodyLimit = 2*limit + responseOverhead
+	}
+	body, err := framework.ReadAllWithLimit(resp.Body, bodyLimit)
 	if err != nil {
+		var tooLarge *common.Error
+		if errors.As(err, &tooLarge) {
+			return nil, framework.NewErrorResolvedDataTooLarge(limit)
+		}
 		return nil, fmt.Errorf("error reading response body: %w", err)
 	}
 	hr := hubResponse{}
@@ -121,6 +154,9 @@ func (r *Resolver) Resolve(ctx context.Context, params map[string]string) (frame
 	if err != nil {
 		return nil, fmt.Errorf("error unmarshalling json response: %w", err)
 	}
+	if limit > 0 && int64(len(hr.Data.YAML)) > limit {
+		return nil, framework.NewErrorResolvedDataTooLarge(limit)
+	}
 	return &ResolvedHubResource{
 		Content: []byte(hr.Data.YAML),
 	}, nil
@@ -5,11 +5,14 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
 	resolutioncommon "github.com/tektoncd/resolution/pkg/common"
+	"github.com/tektoncd/resolution/pkg/resolver/framework"
 	"github.com/tektoncd/resolution/test/diff"
+	"github.com/tektoncd/resolution/test/fixtures"
 )
 
 func TestGetSelector(t *testing.T) {
@@ -171,3 +174,46 @@ func TestResolve(t *testing.T) {
 		})
 	}
 }
+
+func TestResolveLargerThanLimit(t *testing.T) {
+	hub := fixtures.NewHub(t, fixtures.HubResource{
+		Catalog: "tekton",
+		Kind:    "task",
+		Name:    "foo",
+		Version: "0.1",
+		YAML:    strings.Repeat("a", 100),
+	})
+	resolver := &Resolver{HubURL: hub.URL + "/" + YamlEndpoint}
+	params := map[string]string{
+		ParamKind:    "task",
+		ParamName:    "foo",
+		ParamVersion: "0.1",
+		ParamCatalog: "tekton",
+	}
+
+	if _, err := resolver.Resolve(framework.InjectMaxResolvedDataSize(context.Background(), 100), params); err != nil {
+		t.Fatalf("unexpected error resolving resource at the limit: %v", err)
+	}
+	_, err := resolver.Resolve(framework.InjectMaxResolvedDataSize(context.Background(), 99), params)
+	reason, _ := resolutioncommon.ReasonError(err)
+	if reason != resolutioncommon.ReasonResolvedDataTooLarge {
+		t.Fatalf("expected error with reason %q, got %q: %v", resolutioncommon.ReasonResolvedDataTooLarge, reason, err)
+	}
+}
+
+func TestIsClusterShareable(t *testing.T) {
+	for version, expected := range map[string]bool{
+		"0.3":    true,
+		"0.3.1":  true,
+		"v1.2":   true,
+		"":       false,
+		"latest": false,
+		"0":      false,
+		"0.x":    false,
+	} {
+		params := map[string]string{ParamName: "golang-build", ParamVersion: version}
+		if actual := (&Resolver{}).IsClusterShareable(context.Background(), params); actual != expected {
+			t.Errorf("expected version %q to be shareable: %t, got %t", version, expected, actual)
+		}
+	}
+}
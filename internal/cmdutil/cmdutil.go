@@ -0,0 +1,101 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cmdutil holds the flag parsing and output helpers shared by the
+// commands in this repo.
+package cmdutil
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/tektoncd/resolution/hubresolver/pkg/hub"
+	"github.com/tektoncd/resolution/pkg/resource"
+)
+
+// ParamFlags collects repeated -param key=value flags.
+type ParamFlags map[string]string
+
+func (p ParamFlags) String() string {
+	var pairs []string
+	for key, val := range p {
+		pairs = append(pairs, key+"="+val)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (p ParamFlags) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return fmt.Errorf("param %q must be in the form key=value", value)
+	}
+	p[parts[0]] = parts[1]
+	return nil
+}
+
+// HubURL returns the URL pattern the hub resolver fetches resources
+// from, given the base URL of a custom hub API if there is one.
+func HubURL(apiURL string) string {
+	if apiURL == "" {
+		return hub.DefaultHubURL
+	}
+	if !strings.HasSuffix(apiURL, "/") {
+		apiURL += "/"
+	}
+	return apiURL + hub.YamlEndpoint
+}
+
+// WriteResolved writes a resolved resource's annotations, as YAML
+// comments, followed by its data.
+func WriteResolved(out io.Writer, resolved resource.ResolvedResource) error {
+	data, err := resolved.Data()
+	if err != nil {
+		return err
+	}
+	annotations := resolved.Annotations()
+	for _, key := range SortedKeys(annotations) {
+		if _, err := fmt.Fprintf(out, "# %s: %s\n", key, annotations[key]); err != nil {
+			return err
+		}
+	}
+	return WriteData(out, data)
+}
+
+// WriteData writes data, adding a trailing newline if it doesn't
+// already end with one.
+func WriteData(out io.Writer, data []byte) error {
+	if _, err := out.Write(data); err != nil {
+		return err
+	}
+	var err error
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		_, err = io.WriteString(out, "\n")
+	}
+	return err
+}
+
+// SortedKeys returns the keys of m in order.
+func SortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
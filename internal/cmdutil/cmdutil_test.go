@@ -0,0 +1,73 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmdutil
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tektoncd/resolution/hubresolver/pkg/hub"
+	rtesting "github.com/tektoncd/resolution/pkg/resource/testing"
+	"github.com/tektoncd/resolution/test/diff"
+)
+
+func TestParamFlags(t *testing.T) {
+	params := ParamFlags{}
+	for _, value := range []string{"url=https://example.com/repo.git?a=b", "revision="} {
+		if err := params.Set(value); err != nil {
+			t.Fatalf("unexpected error setting %q: %v", value, err)
+		}
+	}
+	expected := ParamFlags{"url": "https://example.com/repo.git?a=b", "revision": ""}
+	if d := cmp.Diff(expected, params); d != "" {
+		t.Errorf("unexpected params %s", diff.PrintWantGot(d))
+	}
+	if err := params.Set("no-equals"); err == nil {
+		t.Errorf("expected error for param without a value")
+	}
+}
+
+func TestHubURL(t *testing.T) {
+	for apiURL, expected := range map[string]string{
+		"":                         hub.DefaultHubURL,
+		"https://hub.example.com":  "https://hub.example.com/" + hub.YamlEndpoint,
+		"https://hub.example.com/": "https://hub.example.com/" + hub.YamlEndpoint,
+	} {
+		if actual := HubURL(apiURL); actual != expected {
+			t.Errorf("expected hub URL %q for %q, got %q", expected, apiURL, actual)
+		}
+	}
+}
+
+func TestWriteResolved(t *testing.T) {
+	out := &bytes.Buffer{}
+	err := WriteResolved(out, &rtesting.ResolvedResource{
+		ResolvedData: []byte("kind: Task"),
+		ResolvedAnnotations: map[string]string{
+			"revision":     "main",
+			"content-type": "application/x-yaml",
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "# content-type: application/x-yaml\n# revision: main\nkind: Task\n"
+	if d := cmp.Diff(expected, out.String()); d != "" {
+		t.Errorf("unexpected output %s", diff.PrintWantGot(d))
+	}
+}
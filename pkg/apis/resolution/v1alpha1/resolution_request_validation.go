@@ -20,6 +20,7 @@ import (
 	"context"
 
 	"github.com/tektoncd/resolution/pkg/common"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"knative.dev/pkg/apis"
 )
 
@@ -27,9 +28,28 @@ import (
 // sound before the controller receives it.
 func (rr *ResolutionRequest) Validate(ctx context.Context) (errs *apis.FieldError) {
 	errs = errs.Also(validateTypeLabel(rr))
+	if apis.IsInUpdate(ctx) {
+		if original, ok := apis.GetBaseline(ctx).(*ResolutionRequest); ok {
+			errs = errs.Also(validateUnchanged(original, rr))
+		}
+	}
 	return errs.Also(rr.Spec.Validate(ctx).ViaField("spec"))
 }
 
+// validateUnchanged rejects updates to the parts of a request that
+// decide what is resolved and by which resolver. They're checked
+// against the resolution policy when the request is created, so
+// changing them afterwards would get around it.
+func validateUnchanged(original, rr *ResolutionRequest) (errs *apis.FieldError) {
+	if getTypeLabel(original.ObjectMeta.Labels) != getTypeLabel(rr.ObjectMeta.Labels) {
+		errs = errs.Also(apis.ErrGeneric("field is immutable", common.LabelKeyResolverType).ViaField("labels").ViaField("meta"))
+	}
+	if !equality.Semantic.DeepEqual(original.Spec, rr.Spec) {
+		errs = errs.Also(apis.ErrGeneric("field is immutable", "spec"))
+	}
+	return errs
+}
+
 // Validate checks the the spec field of a ResolutionRequest is valid.
 func (rs *ResolutionRequestSpec) Validate(ctx context.Context) *apis.FieldError {
 	return nil
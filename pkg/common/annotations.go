@@ -20,4 +20,24 @@ const (
 	// AnnotationKeyContentType is the annotation key passed back
 	// with a resolved resource's content type.
 	AnnotationKeyContentType = "content-type"
+
+	// AnnotationKeyClaimedBy is the annotation a resolver sets on a
+	// ResolutionRequest to record which replica is resolving it.
+	AnnotationKeyClaimedBy = "resolution.tekton.dev/claimed-by"
+
+	// AnnotationKeyClaimedAt is the annotation a resolver sets on a
+	// ResolutionRequest to record when it started resolving it, in
+	// RFC3339 format.
+	AnnotationKeyClaimedAt = "resolution.tekton.dev/claimed-at"
+
+	// AnnotationKeyClusterShareable is the annotation passed back with
+	// a resolved resource whose content doesn't depend on the
+	// namespace it was requested from, and so can be shared with
+	// requests from other namespaces.
+	AnnotationKeyClusterShareable = "resolution.tekton.dev/cluster-shareable"
+
+	// AnnotationKeyPolicyViolations is the annotation the webhook sets
+	// on a ResolutionRequest that breaks a resolution policy rule in
+	// audit mode, describing each violation on its own line.
+	AnnotationKeyPolicyViolations = "resolution.tekton.dev/policy-violations"
 )
@@ -19,3 +19,8 @@ package common
 // LabelKeyResolverType is the label that determines which resolver will
 // ultimately receive the request for a resource.
 const LabelKeyResolverType string = "resolution.tekton.dev/type"
+
+// LabelKeyShared is the label set to "true" on ResolutionRequests that
+// are made in a shared namespace on behalf of other namespaces. They
+// are deleted some time after they complete.
+const LabelKeyShared string = "resolution.tekton.dev/shared"
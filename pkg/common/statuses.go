@@ -40,4 +40,21 @@ const (
 	// ReasonResolutionTimedOut indicates that a resolver did not
 	// manage to respond to a ResolutionRequest within a timeout.
 	ReasonResolutionTimedOut = "ResolutionTimedOut"
+
+	// ReasonPolicyViolation indicates that a resolved resource was
+	// rejected by one of the rules of a content policy.
+	ReasonPolicyViolation = "PolicyViolation"
+
+	// ReasonInvalidResource indicates that the resolved data was not
+	// the Tekton resource that the request expected.
+	ReasonInvalidResource = "InvalidResource"
+
+	// ReasonResolvedDataTooLarge indicates that the resolved data was
+	// larger than the resolver is allowed to return.
+	ReasonResolvedDataTooLarge = "ResolvedDataTooLarge"
+
+	// ReasonCommitVerificationFailed indicates that the commit a
+	// resource was resolved from was not signed by a trusted key or
+	// was not on a protected branch.
+	ReasonCommitVerificationFailed = "CommitVerificationFailed"
 )
@@ -0,0 +1,103 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/tektoncd/resolution/pkg/common"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Admission evaluates the Policy stored in the context against
+// ResolutionRequests as they're admitted. Its methods are used as
+// webhook callbacks.
+type Admission struct {
+	// KubeClient is used to look up the labels of a request's
+	// namespace when a rule has a namespace selector.
+	KubeClient kubernetes.Interface
+}
+
+// Validate rejects a ResolutionRequest that breaks any rule in
+// enforce mode.
+func (a *Admission) Validate(ctx context.Context, u *unstructured.Unstructured) error {
+	violations, err := a.violations(ctx, u)
+	if err != nil {
+		return err
+	}
+	var enforced []string
+	for _, v := range violations {
+		if v.Mode == ModeEnforce {
+			enforced = append(enforced, v.String())
+		}
+	}
+	if len(enforced) > 0 {
+		return fmt.Errorf("resolution policy: %s", strings.Join(enforced, "; "))
+	}
+	return nil
+}
+
+// Annotate records any rules in audit mode that a ResolutionRequest
+// breaks in its resolution.tekton.dev/policy-violations annotation.
+func (a *Admission) Annotate(ctx context.Context, u *unstructured.Unstructured) error {
+	violations, err := a.violations(ctx, u)
+	if err != nil {
+		return err
+	}
+	var audited []string
+	for _, v := range violations {
+		if v.Mode == ModeAudit {
+			audited = append(audited, v.String())
+		}
+	}
+	if len(audited) == 0 {
+		return nil
+	}
+	annotations := u.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[common.AnnotationKeyPolicyViolations] = strings.Join(audited, "\n")
+	u.SetAnnotations(annotations)
+	return nil
+}
+
+func (a *Admission) violations(ctx context.Context, u *unstructured.Unstructured) ([]Violation, error) {
+	policy := FromContext(ctx)
+	if len(policy.Rules) == 0 {
+		return nil, nil
+	}
+	resolverType := u.GetLabels()[common.LabelKeyResolverType]
+	params, _, err := unstructured.NestedStringMap(u.Object, "spec", "params")
+	if err != nil {
+		return nil, fmt.Errorf("error reading params: %w", err)
+	}
+
+	var namespaceLabels map[string]string
+	if policy.NeedsNamespaceLabels(resolverType) {
+		namespace, err := a.KubeClient.CoreV1().Namespaces().Get(ctx, u.GetNamespace(), metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("error getting namespace %q to check resolution policy: %w", u.GetNamespace(), err)
+		}
+		namespaceLabels = namespace.Labels
+	}
+	return policy.Evaluate(resolverType, namespaceLabels, params), nil
+}
@@ -0,0 +1,227 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tektoncd/resolution/pkg/apis/resolution/v1alpha1"
+	"github.com/tektoncd/resolution/pkg/common"
+	"github.com/tektoncd/resolution/test/diff"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakekube "k8s.io/client-go/kubernetes/fake"
+	"knative.dev/pkg/apis"
+)
+
+func TestAdmission(t *testing.T) {
+	policy, err := NewPolicyFromConfigMap(&corev1.ConfigMap{Data: map[string]string{
+		"enforced": `
+resolver: git
+params:
+  url:
+    allow: ["https://github.com/my-org/*"]
+`,
+		"audited": `
+resolver: git
+mode: audit
+namespaceSelector:
+  matchLabels:
+    team: payments
+params:
+  revision:
+    deny: ["main"]
+`,
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx := ToContext(context.Background(), policy)
+	admission := &Admission{KubeClient: fakekube.NewSimpleClientset(&corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "payments", Labels: map[string]string{"team": "payments"}},
+	})}
+
+	for _, tc := range []struct {
+		name                string
+		namespace           string
+		params              map[string]interface{}
+		expectedErr         string
+		expectedAnnotations map[string]string
+	}{{
+		name:      "allowed",
+		namespace: "payments",
+		params:    map[string]interface{}{"url": "https://github.com/my-org/catalog.git", "revision": "v1"},
+	}, {
+		name:        "enforced rule broken",
+		namespace:   "payments",
+		params:      map[string]interface{}{"url": "https://github.com/other-org/catalog.git", "revision": "v1"},
+		expectedErr: `resolution policy: param "url" value "https://github.com/other-org/catalog.git" is not allowed by rule "enforced"`,
+	}, {
+		name:      "audited rule broken",
+		namespace: "payments",
+		params:    map[string]interface{}{"url": "https://github.com/my-org/catalog.git", "revision": "main"},
+		expectedAnnotations: map[string]string{
+			"foo":                                "bar",
+			common.AnnotationKeyPolicyViolations: `param "revision" value "main" is not allowed by rule "audited"`,
+		},
+	}, {
+		name:        "namespace missing",
+		namespace:   "unknown",
+		params:      map[string]interface{}{"url": "https://github.com/my-org/catalog.git"},
+		expectedErr: `error getting namespace "unknown" to check resolution policy: namespaces "unknown" not found`,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			u := &unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "resolution.tekton.dev/v1alpha1",
+				"kind":       "ResolutionRequest",
+				"metadata": map[string]interface{}{
+					"name":        "rr",
+					"namespace":   tc.namespace,
+					"labels":      map[string]interface{}{common.LabelKeyResolverType: "git"},
+					"annotations": map[string]interface{}{"foo": "bar"},
+				},
+				"spec": map[string]interface{}{"params": tc.params},
+			}}
+
+			err := admission.Validate(ctx, u)
+			if tc.expectedErr != "" {
+				if err == nil || err.Error() != tc.expectedErr {
+					t.Fatalf("expected error %q, got %v", tc.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error validating: %v", err)
+			}
+
+			if err := admission.Annotate(ctx, u); err != nil {
+				t.Fatalf("unexpected error annotating: %v", err)
+			}
+			expectedAnnotations := tc.expectedAnnotations
+			if expectedAnnotations == nil {
+				expectedAnnotations = map[string]string{"foo": "bar"}
+			}
+			if d := cmp.Diff(expectedAnnotations, u.GetAnnotations()); d != "" {
+				t.Errorf("unexpected annotations %s", diff.PrintWantGot(d))
+			}
+		})
+	}
+}
+
+// TestAdmissionOfUpdates checks that a request admitted by the policy
+// can't be updated to ask for something the policy denies.
+func TestAdmissionOfUpdates(t *testing.T) {
+	policy, err := NewPolicyFromConfigMap(&corev1.ConfigMap{Data: map[string]string{
+		"enforced": `
+resolver: git
+params:
+  url:
+    allow: ["https://github.com/my-org/*"]
+`,
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx := ToContext(context.Background(), policy)
+	admission := &Admission{KubeClient: fakekube.NewSimpleClientset()}
+
+	admitted := &v1alpha1.ResolutionRequest{
+		TypeMeta: metav1.TypeMeta{APIVersion: "resolution.tekton.dev/v1alpha1", Kind: "ResolutionRequest"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "rr",
+			Namespace: "foo",
+			Labels:    map[string]string{common.LabelKeyResolverType: "git"},
+		},
+		Spec: v1alpha1.ResolutionRequestSpec{Parameters: map[string]string{
+			"url": "https://github.com/my-org/catalog.git",
+		}},
+	}
+	if err := admission.Validate(ctx, toUnstructured(t, admitted)); err != nil {
+		t.Fatalf("expected request to be admitted, got %v", err)
+	}
+
+	for _, tc := range []struct {
+		name        string
+		update      func(rr *v1alpha1.ResolutionRequest)
+		expectedErr string
+	}{{
+		name: "denied source",
+		update: func(rr *v1alpha1.ResolutionRequest) {
+			rr.Spec.Parameters["url"] = "https://github.com/other-org/catalog.git"
+		},
+		expectedErr: "field is immutable: spec",
+	}, {
+		name: "other resolver",
+		update: func(rr *v1alpha1.ResolutionRequest) {
+			rr.Labels[common.LabelKeyResolverType] = "hub"
+		},
+		expectedErr: "field is immutable: meta.labels.resolution.tekton.dev/type",
+	}, {
+		name: "status",
+		update: func(rr *v1alpha1.ResolutionRequest) {
+			rr.Status.Data = "a2luZDogVGFzawo="
+		},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			updated := admitted.DeepCopy()
+			tc.update(updated)
+
+			err := updated.Validate(apis.WithinUpdate(ctx, admitted))
+			if tc.expectedErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tc.expectedErr {
+				t.Fatalf("expected error %q, got %v", tc.expectedErr, err)
+			}
+		})
+	}
+}
+
+// toUnstructured converts rr in the same way as the webhook before it
+// calls Admission's callbacks.
+func toUnstructured(t *testing.T, rr *v1alpha1.ResolutionRequest) *unstructured.Unstructured {
+	t.Helper()
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(rr)
+	if err != nil {
+		t.Fatalf("error converting request: %v", err)
+	}
+	return &unstructured.Unstructured{Object: obj}
+}
+
+func TestAdmissionWithoutPolicy(t *testing.T) {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "rr", "namespace": "foo"},
+		"spec":     map[string]interface{}{"params": map[string]interface{}{"url": "anything"}},
+	}}
+	admission := &Admission{}
+	if err := admission.Validate(context.Background(), u); err != nil {
+		t.Errorf("unexpected error validating: %v", err)
+	}
+	if err := admission.Annotate(context.Background(), u); err != nil {
+		t.Errorf("unexpected error annotating: %v", err)
+	}
+	if u.GetAnnotations() != nil {
+		t.Errorf("expected no annotations, got %v", u.GetAnnotations())
+	}
+}
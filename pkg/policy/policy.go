@@ -0,0 +1,242 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package policy restricts the sources that ResolutionRequests may
+// resolve from. Policies are read from a ConfigMap and evaluated by the
+// webhook when a ResolutionRequest is created.
+package policy
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+)
+
+// ConfigMapName is the name of the ConfigMap in the system namespace
+// holding the resolution policy.
+const ConfigMapName = "config-resolution-policy"
+
+// Mode is what happens to a ResolutionRequest that breaks a Rule.
+type Mode string
+
+const (
+	// ModeEnforce rejects ResolutionRequests that break a rule.
+	ModeEnforce Mode = "enforce"
+
+	// ModeAudit lets ResolutionRequests that break a rule through but
+	// records the violation in an annotation.
+	ModeAudit Mode = "audit"
+)
+
+// Policy is the set of rules that ResolutionRequests are checked
+// against.
+type Policy struct {
+	// Rules are sorted by name.
+	Rules []Rule
+}
+
+// Rule restricts the params of ResolutionRequests for a resolver made
+// from the namespaces it selects.
+type Rule struct {
+	// Name is the key the rule was read from.
+	Name string `json:"-"`
+
+	// Resolver is the value of the resolution.tekton.dev/type label of
+	// the requests the rule applies to. The rule applies to every
+	// resolver if it's empty.
+	Resolver string `json:"resolver,omitempty"`
+
+	// NamespaceSelector selects the namespaces whose requests the rule
+	// applies to. The rule applies to every namespace if it's nil.
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// Mode is what happens to requests that break the rule. Defaults
+	// to ModeEnforce.
+	Mode Mode `json:"mode,omitempty"`
+
+	// Params maps the names of params to the values they may have.
+	Params map[string]ParamRule `json:"params,omitempty"`
+
+	selector labels.Selector
+}
+
+// ParamRule lists the patterns a param's value is matched against. In
+// a pattern "*" matches any sequence of characters, including none.
+type ParamRule struct {
+	// Allow, if it isn't empty, lists the patterns a value must match
+	// at least one of.
+	Allow []string `json:"allow,omitempty"`
+
+	// Deny lists patterns a value must not match any of. Deny takes
+	// precedence over Allow.
+	Deny []string `json:"deny,omitempty"`
+
+	allow []*regexp.Regexp
+	deny  []*regexp.Regexp
+}
+
+// Violation describes a param of a ResolutionRequest that breaks a
+// Rule.
+type Violation struct {
+	Rule  string
+	Mode  Mode
+	Param string
+	Value string
+}
+
+// String describes the violation.
+func (v Violation) String() string {
+	return fmt.Sprintf("param %q value %q is not allowed by rule %q", v.Param, v.Value, v.Rule)
+}
+
+// NewPolicyFromConfigMap parses a Policy from a ConfigMap. Each key of
+// the ConfigMap other than those starting with "_" is the name of a
+// rule and its value is the rule in YAML.
+func NewPolicyFromConfigMap(cm *corev1.ConfigMap) (*Policy, error) {
+	policy := &Policy{}
+	names := make([]string, 0, len(cm.Data))
+	for name := range cm.Data {
+		if !strings.HasPrefix(name, "_") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		rule := Rule{Name: name}
+		if err := yaml.UnmarshalStrict([]byte(cm.Data[name]), &rule); err != nil {
+			return nil, fmt.Errorf("error parsing rule %q: %w", name, err)
+		}
+		if err := rule.compile(); err != nil {
+			return nil, fmt.Errorf("invalid rule %q: %w", name, err)
+		}
+		policy.Rules = append(policy.Rules, rule)
+	}
+	return policy, nil
+}
+
+// compile checks the rule, filling in defaults and preparing its
+// selector and patterns for matching.
+func (r *Rule) compile() error {
+	switch r.Mode {
+	case "":
+		r.Mode = ModeEnforce
+	case ModeEnforce, ModeAudit:
+	default:
+		return fmt.Errorf("mode must be %q or %q, not %q", ModeEnforce, ModeAudit, r.Mode)
+	}
+	if len(r.Params) == 0 {
+		return fmt.Errorf("no params to check")
+	}
+
+	r.selector = labels.Everything()
+	if r.NamespaceSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(r.NamespaceSelector)
+		if err != nil {
+			return fmt.Errorf("invalid namespaceSelector: %w", err)
+		}
+		r.selector = selector
+	}
+
+	for name, param := range r.Params {
+		param.allow = compilePatterns(param.Allow)
+		param.deny = compilePatterns(param.Deny)
+		if len(param.allow) == 0 && len(param.deny) == 0 {
+			return fmt.Errorf("param %q has no patterns", name)
+		}
+		r.Params[name] = param
+	}
+	return nil
+}
+
+// NeedsNamespaceLabels returns true if any of the rules that apply to
+// the resolver depend on the labels of the request's namespace.
+func (p *Policy) NeedsNamespaceLabels(resolverType string) bool {
+	for _, rule := range p.Rules {
+		if rule.appliesTo(resolverType) && rule.NamespaceSelector != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Evaluate checks the params of a request for a resolver made from a
+// namespace with the given labels against every rule that applies to
+// it. Params that aren't given aren't checked since the resolver's
+// defaults, which are set by an admin, apply to them.
+func (p *Policy) Evaluate(resolverType string, namespaceLabels map[string]string, params map[string]string) []Violation {
+	var violations []Violation
+	for _, rule := range p.Rules {
+		if !rule.appliesTo(resolverType) || !rule.selector.Matches(labels.Set(namespaceLabels)) {
+			continue
+		}
+		names := make([]string, 0, len(rule.Params))
+		for name := range rule.Params {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			value, ok := params[name]
+			if !ok {
+				continue
+			}
+			if !rule.Params[name].allows(value) {
+				violations = append(violations, Violation{Rule: rule.Name, Mode: rule.Mode, Param: name, Value: value})
+			}
+		}
+	}
+	return violations
+}
+
+func (r *Rule) appliesTo(resolverType string) bool {
+	return r.Resolver == "" || r.Resolver == resolverType
+}
+
+func (p ParamRule) allows(value string) bool {
+	for _, pattern := range p.deny {
+		if pattern.MatchString(value) {
+			return false
+		}
+	}
+	if len(p.allow) == 0 {
+		return true
+	}
+	for _, pattern := range p.allow {
+		if pattern.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// compilePatterns turns patterns in which "*" matches any sequence of
+// characters into regular expressions.
+func compilePatterns(patterns []string) []*regexp.Regexp {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		parts := strings.Split(pattern, "*")
+		for i, part := range parts {
+			parts[i] = regexp.QuoteMeta(part)
+		}
+		compiled = append(compiled, regexp.MustCompile("^"+strings.Join(parts, ".*")+"$"))
+	}
+	return compiled
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tektoncd/resolution/test/diff"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestNewPolicyFromConfigMap(t *testing.T) {
+	policy, err := NewPolicyFromConfigMap(&corev1.ConfigMap{Data: map[string]string{
+		"_example": "not: [a rule",
+		"b-rule": `
+params:
+  url:
+    allow: ["https://github.com/*"]
+`,
+		"a-rule": `
+resolver: bundles
+mode: audit
+namespaceSelector:
+  matchLabels:
+    team: foo
+params:
+  bundle:
+    deny: ["docker.io/*"]
+`,
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var summary []string
+	for _, rule := range policy.Rules {
+		summary = append(summary, rule.Name+" "+rule.Resolver+" "+string(rule.Mode))
+	}
+	if d := cmp.Diff([]string{"a-rule bundles audit", "b-rule  enforce"}, summary); d != "" {
+		t.Errorf("unexpected rules %s", diff.PrintWantGot(d))
+	}
+}
+
+func TestNewPolicyFromConfigMapInvalid(t *testing.T) {
+	for name, rule := range map[string]string{
+		"unknown field":      "params: {url: {allow: [foo]}}\nunknown: true",
+		"unknown mode":       "mode: dry-run\nparams: {url: {allow: [foo]}}",
+		"no params":          "resolver: git",
+		"param with no rule": "params: {url: {}}",
+		"invalid selector":   "namespaceSelector: {matchExpressions: [{key: team, operator: Sometimes}]}\nparams: {url: {allow: [foo]}}",
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewPolicyFromConfigMap(&corev1.ConfigMap{Data: map[string]string{"rule": rule}}); err == nil {
+				t.Errorf("expected error parsing rule %q", rule)
+			}
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	policy, err := NewPolicyFromConfigMap(&corev1.ConfigMap{Data: map[string]string{
+		"git-hosts": `
+resolver: git
+params:
+  url:
+    allow: ["https://github.com/my-org/*", "https://git.example.com/*"]
+    deny: ["https://github.com/my-org/secrets*"]
+`,
+		"team-registry": `
+resolver: bundles
+mode: audit
+namespaceSelector:
+  matchLabels:
+    team: payments
+params:
+  bundle:
+    allow: ["registry.example.com/*"]
+`,
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name            string
+		resolverType    string
+		namespaceLabels map[string]string
+		params          map[string]string
+		expected        []Violation
+	}{{
+		name:         "allowed git url",
+		resolverType: "git",
+		params:       map[string]string{"url": "https://github.com/my-org/catalog.git"},
+	}, {
+		name:         "git url not in allow list",
+		resolverType: "git",
+		params:       map[string]string{"url": "https://github.com/other-org/catalog.git"},
+		expected: []Violation{{
+			Rule:  "git-hosts",
+			Mode:  ModeEnforce,
+			Param: "url",
+			Value: "https://github.com/other-org/catalog.git",
+		}},
+	}, {
+		name:         "denied git url",
+		resolverType: "git",
+		params:       map[string]string{"url": "https://github.com/my-org/secrets.git"},
+		expected: []Violation{{
+			Rule:  "git-hosts",
+			Mode:  ModeEnforce,
+			Param: "url",
+			Value: "https://github.com/my-org/secrets.git",
+		}},
+	}, {
+		name:         "git url left to default",
+		resolverType: "git",
+		params:       map[string]string{"pathInRepo": "task.yaml"},
+	}, {
+		name:         "rule for other resolver",
+		resolverType: "hub",
+		params:       map[string]string{"url": "https://github.com/other-org/catalog.git"},
+	}, {
+		name:            "bundle from namespace selected by rule",
+		resolverType:    "bundles",
+		namespaceLabels: map[string]string{"team": "payments"},
+		params:          map[string]string{"bundle": "docker.io/foo/bar:latest"},
+		expected: []Violation{{
+			Rule:  "team-registry",
+			Mode:  ModeAudit,
+			Param: "bundle",
+			Value: "docker.io/foo/bar:latest",
+		}},
+	}, {
+		name:            "bundle from namespace not selected by rule",
+		resolverType:    "bundles",
+		namespaceLabels: map[string]string{"team": "search"},
+		params:          map[string]string{"bundle": "docker.io/foo/bar:latest"},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			violations := policy.Evaluate(tc.resolverType, tc.namespaceLabels, tc.params)
+			if d := cmp.Diff(tc.expected, violations); d != "" {
+				t.Errorf("unexpected violations %s", diff.PrintWantGot(d))
+			}
+		})
+	}
+
+	if policy.NeedsNamespaceLabels("git") {
+		t.Errorf("expected no git rules to need namespace labels")
+	}
+	if !policy.NeedsNamespaceLabels("bundles") {
+		t.Errorf("expected bundles rule to need namespace labels")
+	}
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"context"
+
+	"knative.dev/pkg/configmap"
+)
+
+type policyKey struct{}
+
+// Store keeps the latest Policy read from its ConfigMap.
+type Store struct {
+	*configmap.UntypedStore
+}
+
+// NewStore returns a Store that logs to logger. Call WatchConfigs to
+// keep it up to date.
+func NewStore(logger configmap.Logger) *Store {
+	return &Store{
+		UntypedStore: configmap.NewUntypedStore(
+			"resolution-policy",
+			logger,
+			configmap.Constructors{
+				ConfigMapName: NewPolicyFromConfigMap,
+			},
+		),
+	}
+}
+
+// Load returns the latest Policy, or an empty Policy if none has been
+// read.
+func (s *Store) Load() *Policy {
+	if policy, ok := s.UntypedLoad(ConfigMapName).(*Policy); ok && policy != nil {
+		return policy
+	}
+	return &Policy{}
+}
+
+// ToContext returns a new context with the latest Policy stored in it.
+func (s *Store) ToContext(ctx context.Context) context.Context {
+	return ToContext(ctx, s.Load())
+}
+
+// ToContext returns a new context with policy stored in it.
+func ToContext(ctx context.Context, policy *Policy) context.Context {
+	return context.WithValue(ctx, policyKey{}, policy)
+}
+
+// FromContext returns the Policy stored in ctx, or an empty Policy if
+// there isn't one.
+func FromContext(ctx context.Context) *Policy {
+	if policy, ok := ctx.Value(policyKey{}).(*Policy); ok && policy != nil {
+		return policy
+	}
+	return &Policy{}
+}
@@ -23,6 +23,7 @@ import (
 	"knative.dev/pkg/configmap"
 	"knative.dev/pkg/controller"
 
+	rrclient "github.com/tektoncd/resolution/pkg/client/injection/client"
 	resolutionrequestinformer "github.com/tektoncd/resolution/pkg/client/injection/informers/resolution/v1alpha1/resolutionrequest"
 	resolutionrequestreconciler "github.com/tektoncd/resolution/pkg/client/injection/reconciler/resolution/v1alpha1/resolutionrequest"
 )
@@ -32,7 +33,8 @@ import (
 func NewController(clock clock.PassiveClock) func(ctx context.Context, cmw configmap.Watcher) *controller.Impl {
 	return func(ctx context.Context, cmw configmap.Watcher) *controller.Impl {
 		r := &Reconciler{
-			clock: clock,
+			clock:                      clock,
+			resolutionRequestClientSet: rrclient.Get(ctx),
 		}
 		impl := resolutionrequestreconciler.NewImpl(ctx, r)
 
@@ -22,8 +22,11 @@ import (
 	"time"
 
 	"github.com/tektoncd/resolution/pkg/apis/resolution/v1alpha1"
+	rrclient "github.com/tektoncd/resolution/pkg/client/clientset/versioned"
 	rrreconciler "github.com/tektoncd/resolution/pkg/client/injection/reconciler/resolution/v1alpha1/resolutionrequest"
 	resolutioncommon "github.com/tektoncd/resolution/pkg/common"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/utils/clock"
 	"knative.dev/pkg/apis"
 	"knative.dev/pkg/controller"
@@ -33,7 +36,8 @@ import (
 // Reconciler is a knative reconciler for processing ResolutionRequest
 // objects
 type Reconciler struct {
-	clock clock.PassiveClock
+	clock                      clock.PassiveClock
+	resolutionRequestClientSet rrclient.Interface
 }
 
 var _ rrreconciler.Interface = (*Reconciler)(nil)
@@ -42,6 +46,12 @@ var _ rrreconciler.Interface = (*Reconciler)(nil)
 // store similarly to Tekton Pipelines'.
 const defaultMaximumResolutionDuration = 1 * time.Minute
 
+// sharedRequestTTL is how long a ResolutionRequest made in a shared
+// namespace on behalf of other namespaces is kept once it completes.
+// Deleting it means the next namespace to ask for the same thing has
+// it resolved afresh, rather than sharing one result forever.
+const sharedRequestTTL = 24 * time.Hour
+
 // ReconcileKind processes updates to ResolutionRequests, sets status
 // fields on it, and returns any errors experienced along the way.
 func (r *Reconciler) ReconcileKind(ctx context.Context, rr *v1alpha1.ResolutionRequest) reconciler.Event {
@@ -50,7 +60,7 @@ func (r *Reconciler) ReconcileKind(ctx context.Context, rr *v1alpha1.ResolutionR
 	}
 
 	if rr.IsDone() {
-		return nil
+		return r.expireShared(ctx, rr)
 	}
 
 	if rr.Status.GetCondition(apis.ConditionSucceeded) == nil {
@@ -63,13 +73,41 @@ func (r *Reconciler) ReconcileKind(ctx context.Context, rr *v1alpha1.ResolutionR
 	case requestDuration(rr) > defaultMaximumResolutionDuration:
 		rr.Status.MarkFailed(resolutioncommon.ReasonResolutionTimedOut, timeoutMessage())
 	default:
-		rr.Status.MarkInProgress(resolutioncommon.MessageWaitingForResolver)
+		// A resolver may have already reported its own progress,
+		// e.g. that the request is queued, which shouldn't be
+		// clobbered by the generic message.
+		if rr.Status.GetCondition(apis.ConditionSucceeded).GetMessage() == "" {
+			rr.Status.MarkInProgress(resolutioncommon.MessageWaitingForResolver)
+		}
 		return controller.NewRequeueAfter(defaultMaximumResolutionDuration - requestDuration(rr))
 	}
 
 	return nil
 }
 
+// expireShared deletes a completed shared ResolutionRequest once it
+// is older than sharedRequestTTL, or requeues it until then. Requests
+// that aren't shared are left alone.
+func (r *Reconciler) expireShared(ctx context.Context, rr *v1alpha1.ResolutionRequest) reconciler.Event {
+	if rr.Labels[resolutioncommon.LabelKeyShared] != "true" {
+		return nil
+	}
+	completedAt := rr.Status.GetCondition(apis.ConditionSucceeded).LastTransitionTime.Inner.Time
+	if completedAt.IsZero() {
+		completedAt = rr.CreationTimestamp.Time
+	}
+	if remaining := sharedRequestTTL - r.clock.Since(completedAt); remaining > 0 {
+		return controller.NewRequeueAfter(remaining)
+	}
+	err := r.resolutionRequestClientSet.ResolutionV1alpha1().ResolutionRequests(rr.Namespace).Delete(ctx, rr.Name, metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{UID: &rr.UID},
+	})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("error deleting expired shared resolutionrequest %s/%s: %w", rr.Namespace, rr.Name, err)
+	}
+	return nil
+}
+
 // requestDuration returns the amount of time that has passed since a
 // given ResolutionRequest was created.
 func requestDuration(rr *v1alpha1.ResolutionRequest) time.Duration {
@@ -31,6 +31,7 @@ import (
 	"github.com/tektoncd/resolution/test/diff"
 	"github.com/tektoncd/resolution/test/names"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/clock"
@@ -134,6 +135,37 @@ func TestReconcile(t *testing.T) {
 				},
 				ResolutionRequestStatusFields: v1alpha1.ResolutionRequestStatusFields{},
 			},
+		}, {
+			name: "request with progress reported by resolver",
+			input: &v1alpha1.ResolutionRequest{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "rr",
+					Namespace:         "foo",
+					CreationTimestamp: metav1.Time{Time: time.Now()},
+				},
+				Spec: v1alpha1.ResolutionRequestSpec{},
+				Status: v1alpha1.ResolutionRequestStatus{
+					Status: duckv1.Status{
+						Conditions: duckv1.Conditions{{
+							Type:    apis.ConditionSucceeded,
+							Status:  corev1.ConditionUnknown,
+							Reason:  resolutioncommon.ReasonResolutionInProgress,
+							Message: "queued: resolver has reached its limit of 1 concurrent resolutions",
+						}},
+					},
+				},
+			},
+			expectedStatus: &v1alpha1.ResolutionRequestStatus{
+				Status: duckv1.Status{
+					Conditions: duckv1.Conditions{{
+						Type:    apis.ConditionSucceeded,
+						Status:  corev1.ConditionUnknown,
+						Reason:  resolutioncommon.ReasonResolutionInProgress,
+						Message: "queued: resolver has reached its limit of 1 concurrent resolutions",
+					}},
+				},
+				ResolutionRequestStatusFields: v1alpha1.ResolutionRequestStatusFields{},
+			},
 		}, {
 			name: "populated request",
 			input: &v1alpha1.ResolutionRequest{
@@ -193,3 +225,67 @@ func TestReconcile(t *testing.T) {
 func getRequestName(rr *v1alpha1.ResolutionRequest) string {
 	return strings.Join([]string{rr.Namespace, rr.Name}, "/")
 }
+
+func TestReconcileExpiresSharedRequests(t *testing.T) {
+	for _, tc := range []struct {
+		name            string
+		labels          map[string]string
+		completedAt     time.Time
+		expectedRequeue time.Duration
+		expectedDeleted bool
+	}{{
+		name:            "expired shared request",
+		labels:          map[string]string{resolutioncommon.LabelKeyShared: "true"},
+		completedAt:     now.Add(-sharedRequestTTL),
+		expectedDeleted: true,
+	}, {
+		name:            "recent shared request",
+		labels:          map[string]string{resolutioncommon.LabelKeyShared: "true"},
+		completedAt:     now.Add(-time.Hour),
+		expectedRequeue: sharedRequestTTL - time.Hour,
+	}, {
+		name:        "old request that isn't shared",
+		completedAt: now.Add(-2 * sharedRequestTTL),
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := &v1alpha1.ResolutionRequest{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "rr",
+					Namespace:         "shared",
+					Labels:            tc.labels,
+					CreationTimestamp: metav1.Time{Time: tc.completedAt},
+				},
+				Status: v1alpha1.ResolutionRequestStatus{
+					Status: duckv1.Status{
+						Conditions: duckv1.Conditions{{
+							Type:               apis.ConditionSucceeded,
+							Status:             corev1.ConditionTrue,
+							LastTransitionTime: apis.VolatileTime{Inner: metav1.Time{Time: tc.completedAt}},
+						}},
+					},
+					ResolutionRequestStatusFields: v1alpha1.ResolutionRequestStatusFields{
+						Data: "some data",
+					},
+				},
+			}
+			testAssets, cancel := getResolutionRequestController(t, test.Data{
+				ResolutionRequests: []*v1alpha1.ResolutionRequest{rr},
+			})
+			defer cancel()
+
+			err := testAssets.Controller.Reconciler.Reconcile(testAssets.Ctx, getRequestName(rr))
+			if tc.expectedRequeue != 0 {
+				if ok, requeueAfter := controller.IsRequeueKey(err); !ok || requeueAfter != tc.expectedRequeue {
+					t.Fatalf("expected to be requeued after %s, got %v", tc.expectedRequeue, err)
+				}
+			} else if err != nil {
+				t.Fatalf("did not expect an error, but got %v", err)
+			}
+
+			_, err = testAssets.Clients.ResolutionRequests.ResolutionV1alpha1().ResolutionRequests(rr.Namespace).Get(testAssets.Ctx, rr.Name, metav1.GetOptions{})
+			if deleted := apierrors.IsNotFound(err); deleted != tc.expectedDeleted {
+				t.Errorf("expected deleted to be %t, got error %v", tc.expectedDeleted, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker/decls"
+	"google.golang.org/protobuf/proto"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/configmap"
+	"knative.dev/pkg/logging"
+)
+
+// ContentPolicyConfigMapName is the name of the ConfigMap that the
+// shipped resolvers read their content policy from.
+const ContentPolicyConfigMapName = "resolvers-content-policy"
+
+const (
+	// celCostLimit bounds the work a CEL rule can do for a single
+	// document, so that a rule iterating over a large resource can't
+	// hold up the reconciler. It's the same limit that Kubernetes
+	// puts on each CEL validation rule.
+	celCostLimit = 1000000
+
+	// celInterruptCheckFrequency is how many comprehension
+	// iterations a CEL rule runs between checks of whether its
+	// context is done.
+	celInterruptCheckFrequency = 100
+)
+
+// celRule is a ContentRule written as a CEL expression.
+type celRule struct {
+	name    string
+	program cel.Program
+}
+
+var _ ContentRule = &celRule{}
+
+// NewCELRule returns a ContentRule that allows a resource if the CEL
+// expression evaluates to true for it. The expression can refer to
+// resource, the resolved resource parsed from YAML, and annotations,
+// the annotations the resolver returned with it. Evaluation fails if it
+// exceeds a cost limit or its context is done. For example:
+//
+//	resource.kind != "Task" || resource.spec.steps.all(s, !has(s.securityContext))
+func NewCELRule(name, expression string) (ContentRule, error) {
+	env, err := cel.NewEnv(cel.Declarations(
+		decls.NewVar("resource", decls.NewMapType(decls.String, decls.Dyn)),
+		decls.NewVar("annotations", decls.NewMapType(decls.String, decls.String)),
+	))
+	if err != nil {
+		return nil, err
+	}
+	ast, issues := env.Compile(expression)
+	if issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	if t := ast.ResultType(); !proto.Equal(t, decls.Bool) && !proto.Equal(t, decls.Dyn) {
+		return nil, fmt.Errorf("expression must evaluate to a bool, not %v", t)
+	}
+	program, err := env.Program(ast,
+		cel.CostLimit(celCostLimit),
+		cel.InterruptCheckFrequency(celInterruptCheckFrequency),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &celRule{name: name, program: program}, nil
+}
+
+// Name returns the name the rule was created with.
+func (r *celRule) Name() string {
+	return r.name
+}
+
+// Allows evaluates the rule's expression for resource and annotations.
+func (r *celRule) Allows(ctx context.Context, resource map[string]interface{}, annotations map[string]string) (bool, error) {
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	out, _, err := r.program.ContextEval(ctx, map[string]interface{}{
+		"resource":    resource,
+		"annotations": annotations,
+	})
+	if err != nil {
+		return false, err
+	}
+	allowed, ok := out.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("expression evaluated to %v rather than a bool", out.Value())
+	}
+	return allowed, nil
+}
+
+// NewContentRulesFromConfigMap returns the CEL rules in a ConfigMap,
+// in order of their names. Each key other than those starting with "_"
+// is the name of a rule and its value is the rule's expression.
+func NewContentRulesFromConfigMap(cm *corev1.ConfigMap) ([]ContentRule, error) {
+	names := make([]string, 0, len(cm.Data))
+	for name := range cm.Data {
+		if !strings.HasPrefix(name, "_") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	rules := make([]ContentRule, 0, len(names))
+	for _, name := range names {
+		rule, err := NewCELRule(name, cm.Data[name])
+		if err != nil {
+			return nil, fmt.Errorf("invalid content policy rule %q: %w", name, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// WithContentPolicyFromConfigMap returns a ReconcilerModifier that
+// checks every resource the resolver returns against the CEL rules in
+// the named ConfigMap, after any rules given to WithContentPolicy. The
+// rules are updated whenever the ConfigMap changes. If the ConfigMap
+// doesn't exist no rules are applied, and if it holds an invalid rule
+// the rules it held before are kept.
+func WithContentPolicyFromConfigMap(configMapName string) ReconcilerModifier {
+	return func(r *Reconciler) {
+		r.contentPolicy = &contentPolicyWatch{configMapName: configMapName}
+	}
+}
+
+// contentPolicyWatch holds the rules most recently read from a content
+// policy ConfigMap.
+type contentPolicyWatch struct {
+	configMapName string
+
+	mu    sync.Mutex
+	rules []ContentRule
+}
+
+func (w *contentPolicyWatch) set(rules []ContentRule) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.rules = rules
+}
+
+func (w *contentPolicyWatch) get() []ContentRule {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.rules
+}
+
+// watchContentPolicy keeps a reconciler's content policy up to date
+// with its ConfigMap.
+func watchContentPolicy(ctx context.Context, r *Reconciler, cmw configmap.Watcher) {
+	if r.contentPolicy == nil {
+		return
+	}
+	logger := logging.FromContext(ctx)
+	observer := func(cm *corev1.ConfigMap) {
+		rules, err := NewContentRulesFromConfigMap(cm)
+		if err != nil {
+			logger.Errorf("ignoring invalid content policy in %q, keeping previous rules: %v", r.contentPolicy.configMapName, err)
+			return
+		}
+		r.contentPolicy.set(rules)
+	}
+	if dw, ok := cmw.(configmap.DefaultingWatcher); ok {
+		// The ConfigMap is optional; without it there are no rules.
+		dw.WatchWithDefault(corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: r.contentPolicy.configMapName},
+		}, observer)
+		return
+	}
+	cmw.Watch(r.contentPolicy.configMapName, observer)
+}
@@ -0,0 +1,230 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tektoncd/resolution/pkg/apis/resolution/v1alpha1"
+	resolutioncommon "github.com/tektoncd/resolution/pkg/common"
+	ttesting "github.com/tektoncd/resolution/pkg/reconciler/testing"
+	"github.com/tektoncd/resolution/test"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
+	"knative.dev/pkg/system"
+)
+
+func TestCELRule(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		expression  string
+		resource    string
+		annotations map[string]string
+		expected    bool
+		expectedErr string
+	}{{
+		name:       "allowed",
+		expression: `resource.kind == "Task"`,
+		resource:   "kind: Task",
+		expected:   true,
+	}, {
+		name:       "denied",
+		expression: `resource.kind == "Task"`,
+		resource:   "kind: Pipeline",
+	}, {
+		name:       "nested fields",
+		expression: `!has(resource.spec.steps) || resource.spec.steps.all(s, !has(s.securityContext) || s.securityContext.privileged != true)`,
+		resource:   "kind: Task\nspec:\n  steps:\n  - image: alpine\n  - image: alpine\n    securityContext:\n      privileged: true\n",
+	}, {
+		name:        "annotations",
+		expression:  `annotations["source"] == "approved"`,
+		resource:    "kind: Task",
+		annotations: map[string]string{"source": "approved"},
+		expected:    true,
+	}, {
+		name:        "missing field",
+		expression:  `resource.spec.steps.size() < 10`,
+		resource:    "kind: Task",
+		expectedErr: "no such key: spec",
+	}, {
+		name:       "integer field",
+		expression: `resource.spec.replicas <= 3`,
+		resource:   "spec:\n  replicas: 2\n",
+		expected:   true,
+	}, {
+		name:       "fractional field",
+		expression: `resource.spec.ratio > 0.5`,
+		resource:   "spec:\n  ratio: 0.75\n",
+		expected:   true,
+	}, {
+		name:        "cost limit",
+		expression:  `resource.items.all(a, resource.items.all(b, a + b >= 0))`,
+		resource:    "items: [" + strings.TrimSuffix(strings.Repeat("1, ", 2000), ", ") + "]",
+		expectedErr: "operation cancelled: actual cost limit exceeded",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			rule, err := NewCELRule(tc.name, tc.expression)
+			if err != nil {
+				t.Fatalf("unexpected error compiling rule: %v", err)
+			}
+			resource, err := parseDocument([]byte(tc.resource))
+			if err != nil {
+				t.Fatalf("error parsing resource: %v", err)
+			}
+			allowed, err := rule.Allows(context.Background(), resource, tc.annotations)
+			if tc.expectedErr != "" {
+				if err == nil || err.Error() != tc.expectedErr {
+					t.Fatalf("expected error %q, got %v", tc.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if allowed != tc.expected {
+				t.Errorf("expected allowed to be %t, got %t", tc.expected, allowed)
+			}
+		})
+	}
+}
+
+func TestCELRuleRespectsContext(t *testing.T) {
+	rule, err := NewCELRule("all-positive", `resource.items.all(i, i > 0)`)
+	if err != nil {
+		t.Fatalf("unexpected error compiling rule: %v", err)
+	}
+	resource, err := parseDocument([]byte("items: [" + strings.TrimSuffix(strings.Repeat("1, ", 1000), ", ") + "]"))
+	if err != nil {
+		t.Fatalf("error parsing resource: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := rule.Allows(ctx, resource, nil); err == nil || err.Error() != "operation interrupted" {
+		t.Errorf("expected evaluation to be interrupted, got %v", err)
+	}
+}
+
+func TestNewContentRulesFromConfigMap(t *testing.T) {
+	rules, err := NewContentRulesFromConfigMap(&corev1.ConfigMap{Data: map[string]string{
+		"_example":        "not an expression",
+		"tasks-only":      `resource.kind == "Task"`,
+		"approved-source": `annotations["source"] == "approved"`,
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var names []string
+	for _, rule := range rules {
+		names = append(names, rule.Name())
+	}
+	if strings.Join(names, ",") != "approved-source,tasks-only" {
+		t.Errorf("expected rules in order of their names, got %v", names)
+	}
+
+	for _, expression := range []string{`resource.kind ==`, `1 + 1`, `"Task"`} {
+		_, err := NewContentRulesFromConfigMap(&corev1.ConfigMap{Data: map[string]string{"invalid": expression}})
+		if err == nil || !strings.HasPrefix(err.Error(), `invalid content policy rule "invalid": `) {
+			t.Errorf("expected error for expression %q, got %v", expression, err)
+		}
+	}
+}
+
+func TestReconcileChecksContentPolicyFromConfigMap(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		configMap   *corev1.ConfigMap
+		content     string
+		expectedMsg string
+	}{{
+		name:    "no configmap",
+		content: "kind: PrivilegedTask",
+	}, {
+		name: "allowed",
+		configMap: &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: ContentPolicyConfigMapName, Namespace: system.Namespace()},
+			Data:       map[string]string{"no-privileged-tasks": `resource.kind != "PrivilegedTask"`},
+		},
+		content: "kind: Task",
+	}, {
+		name: "rejected",
+		configMap: &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: ContentPolicyConfigMapName, Namespace: system.Namespace()},
+			Data:       map[string]string{"no-privileged-tasks": `resource.kind != "PrivilegedTask"`},
+		},
+		content:     "kind: Task\n---\nkind: PrivilegedTask\n",
+		expectedMsg: `resolved resource is not allowed by policy rule "no-privileged-tasks"`,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			inputRequest := &v1alpha1.ResolutionRequest{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "rr",
+					Namespace:         "foo",
+					CreationTimestamp: metav1.Time{Time: time.Now()},
+					Labels: map[string]string{
+						resolutioncommon.LabelKeyResolverType: LabelValueFakeResolverType,
+					},
+				},
+				Spec: v1alpha1.ResolutionRequestSpec{
+					Parameters: map[string]string{
+						FakeParamName: "bar",
+					},
+				},
+			}
+			d := test.Data{
+				ResolutionRequests: []*v1alpha1.ResolutionRequest{inputRequest},
+			}
+			if tc.configMap != nil {
+				d.ConfigMaps = []*corev1.ConfigMap{tc.configMap}
+			}
+			resolver := &FakeResolver{ForParam: map[string]*FakeResolvedResource{"bar": {Content: tc.content}}}
+
+			ctx, _ := ttesting.SetupFakeContext(t)
+			testAssets, cancel := getResolverFrameworkController(ctx, t, d, resolver, setClockOnReconciler, WithContentPolicyFromConfigMap(ContentPolicyConfigMapName))
+			defer cancel()
+			r := testAssets.Controller.Reconciler.(*Reconciler)
+			key := getRequestName(inputRequest)
+
+			if err := r.Reconcile(testAssets.Ctx, key); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			waitForResolution(t, r, key)
+
+			c := testAssets.Clients.ResolutionRequests.ResolutionV1alpha1()
+			reconciledRR, err := c.ResolutionRequests(inputRequest.Namespace).Get(testAssets.Ctx, inputRequest.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("getting updated ResolutionRequest: %v", err)
+			}
+			condition := reconciledRR.Status.GetCondition(apis.ConditionSucceeded)
+			if tc.expectedMsg == "" {
+				if reconciledRR.Status.Data == "" {
+					t.Fatalf("expected resource to be written, got condition %v", condition)
+				}
+				return
+			}
+			if condition == nil || !condition.IsFalse() || condition.Reason != resolutioncommon.ReasonPolicyViolation {
+				t.Fatalf("expected request to fail with reason %q, got condition %v", resolutioncommon.ReasonPolicyViolation, condition)
+			}
+			if condition.Message != tc.expectedMsg {
+				t.Errorf("expected message %q, got %q", tc.expectedMsg, condition.Message)
+			}
+		})
+	}
+}
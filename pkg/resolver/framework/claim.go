@@ -0,0 +1,132 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/tektoncd/resolution/pkg/apis/resolution/v1alpha1"
+	resolutioncommon "github.com/tektoncd/resolution/pkg/common"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"knative.dev/pkg/logging"
+)
+
+// claimStaleAfter is how long a claim made by another replica is
+// honoured. A replica refreshes its claim every claimRefreshInterval
+// for as long as it is resolving a request, however long that takes,
+// so a claim that isn't refreshed in this time is treated as orphaned,
+// e.g. because the replica crashed, and the request is resolved again.
+const claimStaleAfter = 30 * time.Second
+
+// claimRefreshInterval is how often a replica refreshes the claims on
+// the requests it is resolving. It leaves room for a couple of failed
+// refreshes before a claim goes stale.
+const claimRefreshInterval = claimStaleAfter / 3
+
+// claimOf returns the replica that claimed a request and when. ok is
+// false if the request hasn't been claimed or its claim can't be read.
+func claimOf(rr *v1alpha1.ResolutionRequest) (holder string, claimedAt time.Time, ok bool) {
+	holder = rr.GetAnnotations()[resolutioncommon.AnnotationKeyClaimedBy]
+	if holder == "" {
+		return "", time.Time{}, false
+	}
+	claimedAt, err := time.Parse(time.RFC3339, rr.GetAnnotations()[resolutioncommon.AnnotationKeyClaimedAt])
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	return holder, claimedAt, true
+}
+
+// heldElsewhere returns how long to wait before a request can be
+// resolved by this replica if it is currently claimed by another one,
+// or zero if it is free to be resolved.
+func (r *Reconciler) heldElsewhere(rr *v1alpha1.ResolutionRequest) time.Duration {
+	holder, claimedAt, ok := claimOf(rr)
+	if !ok || holder == r.identity {
+		return 0
+	}
+	if age := r.Clock.Now().Sub(claimedAt); age < claimStaleAfter {
+		return claimStaleAfter - age
+	}
+	return 0
+}
+
+// claimedHere returns true if a request was last claimed by this
+// replica.
+func (r *Reconciler) claimedHere(rr *v1alpha1.ResolutionRequest) bool {
+	holder, _, ok := claimOf(rr)
+	return ok && holder == r.identity
+}
+
+// claimPatch is the json structure that will be PATCHed into a
+// ResolutionRequest's metadata when a replica claims it.
+type claimPatch struct {
+	Annotations map[string]string `json:"annotations"`
+}
+
+// claim records on a ResolutionRequest that this replica has started
+// resolving it and returns the request as updated.
+func (r *Reconciler) claim(ctx context.Context, rr *v1alpha1.ResolutionRequest) (*v1alpha1.ResolutionRequest, error) {
+	patchBytes, err := json.Marshal(map[string]claimPatch{
+		"metadata": {
+			Annotations: map[string]string{
+				resolutioncommon.AnnotationKeyClaimedBy: r.identity,
+				resolutioncommon.AnnotationKeyClaimedAt: r.Clock.Now().UTC().Format(time.RFC3339),
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.resolutionRequestClientSet.ResolutionV1alpha1().ResolutionRequests(rr.Namespace).Patch(ctx, rr.Name, types.MergePatchType, patchBytes, metav1.PatchOptions{})
+}
+
+// refreshClaim claims rr again every interval until the returned stop
+// func is called, so that other replicas know it is still being
+// resolved. Once stop returns no more refreshes are made.
+func (r *Reconciler) refreshClaim(ctx context.Context, rr *v1alpha1.ResolutionRequest, interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := r.claim(ctx, rr); err != nil {
+					logging.FromContext(ctx).Warnf("error refreshing claim on %s/%s: %v", rr.Namespace, rr.Name, err)
+				}
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			<-stopped
+		})
+	}
+}
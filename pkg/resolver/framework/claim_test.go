@@ -0,0 +1,223 @@
+/*
+ Copyright 2022 The Tekton Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package framework
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tektoncd/resolution/pkg/apis/resolution/v1alpha1"
+	resolutioncommon "github.com/tektoncd/resolution/pkg/common"
+	ttesting "github.com/tektoncd/resolution/pkg/reconciler/testing"
+	"github.com/tektoncd/resolution/test"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"knative.dev/pkg/controller"
+)
+
+func TestReconcileClaims(t *testing.T) {
+	for _, tc := range []struct {
+		name            string
+		annotations     map[string]string
+		expectedRequeue time.Duration
+	}{{
+		name: "unclaimed request",
+	}, {
+		name: "stale claim by another replica",
+		annotations: map[string]string{
+			resolutioncommon.AnnotationKeyClaimedBy: "crashed-replica",
+			resolutioncommon.AnnotationKeyClaimedAt: now.Add(-claimStaleAfter - time.Second).Format(time.RFC3339),
+		},
+	}, {
+		name: "fresh claim by another replica",
+		annotations: map[string]string{
+			resolutioncommon.AnnotationKeyClaimedBy: "other-replica",
+			resolutioncommon.AnnotationKeyClaimedAt: now.Add(-2 * time.Second).Format(time.RFC3339),
+		},
+		expectedRequeue: claimStaleAfter - 2*time.Second,
+	}, {
+		name: "unreadable claim",
+		annotations: map[string]string{
+			resolutioncommon.AnnotationKeyClaimedBy: "other-replica",
+			resolutioncommon.AnnotationKeyClaimedAt: "yesterday",
+		},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			inputRequest := &v1alpha1.ResolutionRequest{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "rr",
+					Namespace:         "foo",
+					CreationTimestamp: metav1.Time{Time: time.Now()},
+					Labels: map[string]string{
+						resolutioncommon.LabelKeyResolverType: LabelValueFakeResolverType,
+					},
+					Annotations: tc.annotations,
+				},
+				Spec: v1alpha1.ResolutionRequestSpec{
+					Parameters: map[string]string{
+						FakeParamName: "bar",
+					},
+				},
+			}
+			d := test.Data{
+				ResolutionRequests: []*v1alpha1.ResolutionRequest{inputRequest},
+			}
+			fakeResolver := &FakeResolver{ForParam: map[string]*FakeResolvedResource{
+				"bar": {Content: "some content"},
+			}}
+
+			ctx, _ := ttesting.SetupFakeContext(t)
+			testAssets, cancel := getResolverFrameworkController(ctx, t, d, fakeResolver, setClockOnReconciler)
+			defer cancel()
+			r := testAssets.Controller.Reconciler.(*Reconciler)
+			key := getRequestName(inputRequest)
+
+			err := r.Reconcile(testAssets.Ctx, key)
+			if tc.expectedRequeue > 0 {
+				if ok, delay := controller.IsRequeueKey(err); !ok || delay != tc.expectedRequeue {
+					t.Fatalf("expected request to be requeued after %s, got %v", tc.expectedRequeue, err)
+				}
+				if r.inflight.has(key) {
+					t.Errorf("expected request claimed by another replica not to be resolved")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			waitForResolution(t, r, key)
+
+			c := testAssets.Clients.ResolutionRequests.ResolutionV1alpha1()
+			reconciledRR, err := c.ResolutionRequests(inputRequest.Namespace).Get(testAssets.Ctx, inputRequest.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("getting updated ResolutionRequest: %v", err)
+			}
+			if reconciledRR.Status.Data == "" {
+				t.Errorf("expected request to be resolved")
+			}
+			holder, claimedAt, ok := claimOf(reconciledRR)
+			if !ok {
+				t.Fatalf("expected request to be claimed, got annotations %v", reconciledRR.Annotations)
+			}
+			if holder != r.identity {
+				t.Errorf("expected request to be claimed by %q, got %q", r.identity, holder)
+			}
+			if !claimedAt.Equal(now) {
+				t.Errorf("expected request to be claimed at %s, got %s", now, claimedAt)
+			}
+		})
+	}
+}
+
+func TestReconcileChecksLiveRequestClaimedHere(t *testing.T) {
+	inputRequest := &v1alpha1.ResolutionRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "rr",
+			Namespace:         "foo",
+			CreationTimestamp: metav1.Time{Time: time.Now()},
+			Labels: map[string]string{
+				resolutioncommon.LabelKeyResolverType: LabelValueFakeResolverType,
+			},
+		},
+		Spec: v1alpha1.ResolutionRequestSpec{
+			Parameters: map[string]string{
+				FakeParamName: "bar",
+			},
+		},
+	}
+	d := test.Data{
+		ResolutionRequests: []*v1alpha1.ResolutionRequest{inputRequest},
+	}
+	resolver := &countingResolver{
+		FakeResolver: &FakeResolver{ForParam: map[string]*FakeResolvedResource{
+			"bar": {Content: "some content"},
+		}},
+		calls: map[string]int{},
+	}
+
+	ctx, _ := ttesting.SetupFakeContext(t)
+	testAssets, cancel := getResolverFrameworkController(ctx, t, d, resolver, setClockOnReconciler)
+	defer cancel()
+	r := testAssets.Controller.Reconciler.(*Reconciler)
+	key := getRequestName(inputRequest)
+
+	if err := r.Reconcile(testAssets.Ctx, key); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	waitForResolution(t, r, key)
+
+	// The lister is left as it was when this replica's claim was
+	// refreshed, as if the refresh had enqueued the request again
+	// before the result reached the lister.
+	stale := inputRequest.DeepCopy()
+	stale.Annotations = map[string]string{
+		resolutioncommon.AnnotationKeyClaimedBy: r.identity,
+		resolutioncommon.AnnotationKeyClaimedAt: now.Format(time.RFC3339),
+	}
+	if err := testAssets.Informers.ResolutionRequest.Informer().GetIndexer().Update(stale); err != nil {
+		t.Fatalf("error updating lister: %v", err)
+	}
+
+	if err := r.Reconcile(testAssets.Ctx, key); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	waitForResolution(t, r, key)
+	if calls := resolver.callsFor("bar"); calls != 1 {
+		t.Errorf("expected request to be resolved once, got %d resolutions", calls)
+	}
+}
+
+func TestRefreshClaim(t *testing.T) {
+	inputRequest := &v1alpha1.ResolutionRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "rr",
+			Namespace:         "foo",
+			CreationTimestamp: metav1.Time{Time: time.Now()},
+			Labels: map[string]string{
+				resolutioncommon.LabelKeyResolverType: LabelValueFakeResolverType,
+			},
+		},
+	}
+	d := test.Data{
+		ResolutionRequests: []*v1alpha1.ResolutionRequest{inputRequest},
+	}
+
+	ctx, _ := ttesting.SetupFakeContext(t)
+	testAssets, cancel := getResolverFrameworkController(ctx, t, d, &FakeResolver{}, setClockOnReconciler)
+	defer cancel()
+	r := testAssets.Controller.Reconciler.(*Reconciler)
+	c := testAssets.Clients.ResolutionRequests
+
+	stop := r.refreshClaim(testAssets.Ctx, inputRequest, 10*time.Millisecond)
+	if err := wait.PollImmediate(10*time.Millisecond, 10*time.Second, func() (bool, error) {
+		reconciledRR, err := c.ResolutionV1alpha1().ResolutionRequests(inputRequest.Namespace).Get(testAssets.Ctx, inputRequest.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		holder, _, ok := claimOf(reconciledRR)
+		return ok && holder == r.identity, nil
+	}); err != nil {
+		t.Fatalf("expected claim to be refreshed: %v", err)
+	}
+
+	stop()
+	c.ClearActions()
+	time.Sleep(50 * time.Millisecond)
+	if actions := c.Actions(); len(actions) != 0 {
+		t.Errorf("expected no more refreshes once stopped, got %v", actions)
+	}
+}
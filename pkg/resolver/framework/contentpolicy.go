@@ -0,0 +1,135 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	resolutioncommon "github.com/tektoncd/resolution/pkg/common"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+// ContentRule is a check that a resolved resource must pass before it
+// is returned to the requester, e.g. that a Task doesn't run any
+// privileged steps.
+type ContentRule interface {
+	// Name identifies the rule in the message of requests it
+	// rejects.
+	Name() string
+
+	// Allows returns false if the resource breaks the rule. The
+	// resource is the resolved data parsed from YAML, with whole
+	// numbers as int64 and other numbers as float64, and
+	// annotations are those the resolver returned with it.
+	Allows(ctx context.Context, resource map[string]interface{}, annotations map[string]string) (bool, error)
+}
+
+// WithContentPolicy returns a ReconcilerModifier that checks every
+// resource the resolver returns against rules, in order. A request
+// whose resource breaks a rule fails with the PolicyViolation reason
+// and a message naming the rule.
+func WithContentPolicy(rules ...ContentRule) ReconcilerModifier {
+	return func(r *Reconciler) {
+		r.contentRules = append(r.contentRules, rules...)
+	}
+}
+
+// checkContentPolicy returns an error if any document in the resource
+// breaks any of the reconciler's content rules. A rule that can't be
+// evaluated counts as broken.
+func (r *Reconciler) checkContentPolicy(ctx context.Context, resource ResolvedResource) error {
+	rules := append(append([]ContentRule{}, r.contentRules...), r.contentPolicy.get()...)
+	if len(rules) == 0 {
+		return nil
+	}
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(resource.Data())))
+	for {
+		document, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return resolutioncommon.NewError(resolutioncommon.ReasonPolicyViolation, fmt.Errorf("error parsing resolved resource to check it against policy: %w", err))
+		}
+		parsed, err := parseDocument(document)
+		if err != nil {
+			return resolutioncommon.NewError(resolutioncommon.ReasonPolicyViolation, fmt.Errorf("error parsing resolved resource to check it against policy: %w", err))
+		}
+		if len(parsed) == 0 {
+			// An empty document, e.g. after a trailing "---".
+			continue
+		}
+		for _, rule := range rules {
+			allowed, err := rule.Allows(ctx, parsed, resource.Annotations())
+			if err != nil {
+				return resolutioncommon.NewError(resolutioncommon.ReasonPolicyViolation, fmt.Errorf("error evaluating policy rule %q: %w", rule.Name(), err))
+			}
+			if !allowed {
+				return resolutioncommon.NewError(resolutioncommon.ReasonPolicyViolation, fmt.Errorf("resolved resource is not allowed by policy rule %q", rule.Name()))
+			}
+		}
+	}
+}
+
+// parseDocument parses a YAML document into the form that content
+// rules are given it in.
+func parseDocument(document []byte) (map[string]interface{}, error) {
+	parsed := map[string]interface{}{}
+	if err := yaml.Unmarshal(document, &parsed, useNumber); err != nil {
+		return nil, err
+	}
+	convertNumbers(parsed)
+	return parsed, nil
+}
+
+// useNumber keeps numbers as json.Number when decoding a resource so
+// that convertNumbers can tell whole numbers from others.
+func useNumber(d *json.Decoder) *json.Decoder {
+	d.UseNumber()
+	return d
+}
+
+// convertNumbers replaces the json.Numbers in a decoded value with
+// int64s, or float64s if they aren't whole numbers, so that rules can
+// compare integer fields with integers.
+func convertNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = convertNumbers(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = convertNumbers(item)
+		}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+	}
+	return value
+}
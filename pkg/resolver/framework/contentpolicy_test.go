@@ -0,0 +1,172 @@
+/*
+ Copyright 2022 The Tekton Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package framework
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tektoncd/resolution/pkg/apis/resolution/v1alpha1"
+	resolutioncommon "github.com/tektoncd/resolution/pkg/common"
+	ttesting "github.com/tektoncd/resolution/pkg/reconciler/testing"
+	"github.com/tektoncd/resolution/test"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
+)
+
+func TestReconcileChecksContentPolicy(t *testing.T) {
+	noPrivilegedSteps := &fakeContentRule{
+		name: "no-privileged-steps",
+		allows: func(resource map[string]interface{}, _ map[string]string) (bool, error) {
+			return resource["kind"] != "PrivilegedTask", nil
+		},
+	}
+	approvedSource := &fakeContentRule{
+		name: "approved-source",
+		allows: func(_ map[string]interface{}, annotations map[string]string) (bool, error) {
+			return annotations["source"] == "approved", nil
+		},
+	}
+	broken := &fakeContentRule{
+		name: "broken",
+		allows: func(map[string]interface{}, map[string]string) (bool, error) {
+			return false, errors.New("no such field")
+		},
+	}
+
+	for _, tc := range []struct {
+		name           string
+		resource       *FakeResolvedResource
+		rules          []ContentRule
+		expectedReason string
+		expectedMsg    string
+	}{{
+		name:     "allowed",
+		resource: &FakeResolvedResource{Content: "kind: Task", AnnotationMap: map[string]string{"source": "approved"}},
+		rules:    []ContentRule{noPrivilegedSteps, approvedSource},
+	}, {
+		name:           "content rejected",
+		resource:       &FakeResolvedResource{Content: "kind: PrivilegedTask", AnnotationMap: map[string]string{"source": "approved"}},
+		rules:          []ContentRule{noPrivilegedSteps, approvedSource},
+		expectedReason: resolutioncommon.ReasonPolicyViolation,
+		expectedMsg:    `resolved resource is not allowed by policy rule "no-privileged-steps"`,
+	}, {
+		name:           "annotations rejected",
+		resource:       &FakeResolvedResource{Content: "kind: Task", AnnotationMap: map[string]string{"source": "elsewhere"}},
+		rules:          []ContentRule{noPrivilegedSteps, approvedSource},
+		expectedReason: resolutioncommon.ReasonPolicyViolation,
+		expectedMsg:    `resolved resource is not allowed by policy rule "approved-source"`,
+	}, {
+		name:           "not yaml",
+		resource:       &FakeResolvedResource{Content: "- not\n- an object"},
+		rules:          []ContentRule{noPrivilegedSteps},
+		expectedReason: resolutioncommon.ReasonPolicyViolation,
+		expectedMsg:    "error parsing resolved resource to check it against policy: error unmarshaling JSON: while decoding JSON: json: cannot unmarshal array into Go value of type map[string]interface {}",
+	}, {
+		name:           "rule fails",
+		resource:       &FakeResolvedResource{Content: "kind: Task"},
+		rules:          []ContentRule{broken},
+		expectedReason: resolutioncommon.ReasonPolicyViolation,
+		expectedMsg:    `error evaluating policy rule "broken": no such field`,
+	}, {
+		name:           "second document rejected",
+		resource:       &FakeResolvedResource{Content: "kind: Task\n---\nkind: PrivilegedTask\n---\n", AnnotationMap: map[string]string{"source": "approved"}},
+		rules:          []ContentRule{noPrivilegedSteps, approvedSource},
+		expectedReason: resolutioncommon.ReasonPolicyViolation,
+		expectedMsg:    `resolved resource is not allowed by policy rule "no-privileged-steps"`,
+	}, {
+		name:     "every document allowed",
+		resource: &FakeResolvedResource{Content: "kind: Task\n---\nkind: Pipeline\n---\n", AnnotationMap: map[string]string{"source": "approved"}},
+		rules:    []ContentRule{noPrivilegedSteps, approvedSource},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			inputRequest := &v1alpha1.ResolutionRequest{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "rr",
+					Namespace:         "foo",
+					CreationTimestamp: metav1.Time{Time: time.Now()},
+					Labels: map[string]string{
+						resolutioncommon.LabelKeyResolverType: LabelValueFakeResolverType,
+					},
+				},
+				Spec: v1alpha1.ResolutionRequestSpec{
+					Parameters: map[string]string{
+						FakeParamName: "bar",
+					},
+				},
+			}
+			d := test.Data{
+				ResolutionRequests: []*v1alpha1.ResolutionRequest{inputRequest},
+			}
+			resolver := &FakeResolver{ForParam: map[string]*FakeResolvedResource{"bar": tc.resource}}
+
+			ctx, _ := ttesting.SetupFakeContext(t)
+			testAssets, cancel := getResolverFrameworkController(ctx, t, d, resolver, setClockOnReconciler, WithContentPolicy(tc.rules...))
+			defer cancel()
+			r := testAssets.Controller.Reconciler.(*Reconciler)
+			key := getRequestName(inputRequest)
+
+			if err := r.Reconcile(testAssets.Ctx, key); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			waitForResolution(t, r, key)
+
+			c := testAssets.Clients.ResolutionRequests.ResolutionV1alpha1()
+			reconciledRR, err := c.ResolutionRequests(inputRequest.Namespace).Get(testAssets.Ctx, inputRequest.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("getting updated ResolutionRequest: %v", err)
+			}
+			condition := reconciledRR.Status.GetCondition(apis.ConditionSucceeded)
+			if tc.expectedReason == "" {
+				if reconciledRR.Status.Data == "" {
+					t.Fatalf("expected resource to be written, got condition %v", condition)
+				}
+				return
+			}
+			if reconciledRR.Status.Data != "" {
+				t.Errorf("expected no data to be written, got %q", reconciledRR.Status.Data)
+			}
+			if condition == nil || !condition.IsFalse() {
+				t.Fatalf("expected request to fail, got condition %v", condition)
+			}
+			if condition.Reason != tc.expectedReason {
+				t.Errorf("expected reason %q, got %q", tc.expectedReason, condition.Reason)
+			}
+			if condition.Message != tc.expectedMsg {
+				t.Errorf("expected message %q, got %q", tc.expectedMsg, condition.Message)
+			}
+		})
+	}
+}
+
+// fakeContentRule is a ContentRule that delegates to a func.
+type fakeContentRule struct {
+	name   string
+	allows func(map[string]interface{}, map[string]string) (bool, error)
+}
+
+var _ ContentRule = &fakeContentRule{}
+
+func (r *fakeContentRule) Name() string {
+	return r.name
+}
+
+func (r *fakeContentRule) Allows(_ context.Context, resource map[string]interface{}, annotations map[string]string) (bool, error) {
+	return r.allows(resource, annotations)
+}
@@ -33,6 +33,7 @@ import (
 	kubeclient "knative.dev/pkg/client/injection/kube/client"
 	"knative.dev/pkg/configmap"
 	"knative.dev/pkg/controller"
+	"knative.dev/pkg/leaderelection"
 	"knative.dev/pkg/logging"
 	"knative.dev/pkg/reconciler"
 )
@@ -59,12 +60,21 @@ func NewController(ctx context.Context, resolver Resolver, modifiers ...Reconcil
 			panic(err.Error())
 		}
 
+		identity, err := leaderelection.UniqueID()
+		if err != nil {
+			panic(err.Error())
+		}
+
+		inflight := newInflightResolutions()
 		r := &Reconciler{
-			LeaderAwareFuncs:           leaderAwareFuncs(rrInformer.Lister()),
+			LeaderAwareFuncs:           leaderAwareFuncs(rrInformer.Lister(), resolver.GetSelector(ctx), inflight),
 			kubeClientSet:              kubeclientset,
 			resolutionRequestLister:    rrInformer.Lister(),
 			resolutionRequestClientSet: rrclientset,
 			resolver:                   resolver,
+			throttle:                   newResolutionThrottle(),
+			inflight:                   inflight,
+			identity:                   identity,
 		}
 
 		watchConfigChanges(ctx, r, cmw)
@@ -75,11 +85,14 @@ func NewController(ctx context.Context, resolver Resolver, modifiers ...Reconcil
 		resolverName = strings.ReplaceAll(resolverName, " ", "")
 
 		applyModifiersAndDefaults(ctx, r, modifiers)
+		watchContentPolicy(ctx, r, cmw)
+		r.pool.start(ctx)
 
 		impl := controller.NewContext(ctx, r, controller.ControllerOptions{
 			WorkQueueName: "TektonResolverFramework." + resolverName,
 			Logger:        logger,
 		})
+		r.enqueueAfter = impl.EnqueueKeyAfter
 
 		rrInformer.Informer().AddEventHandler(cache.FilteringResourceEventHandler{
 			FilterFunc: filterResolutionRequestsBySelector(resolver.GetSelector(ctx)),
@@ -88,19 +101,23 @@ func NewController(ctx context.Context, resolver Resolver, modifiers ...Reconcil
 				UpdateFunc: func(oldObj, newObj interface{}) {
 					impl.Enqueue(newObj)
 				},
-				// TODO(sbwsg): should we deliver delete events
-				// to the resolver?
-				// DeleteFunc: impl.Enqueue,
+				DeleteFunc: func(obj interface{}) {
+					r.cancelDeleted(ctx, obj)
+				},
 			},
 		})
 
+		watchFeatureFlags(ctx, r, cmw, func() {
+			impl.FilteredGlobalResync(filterResolutionRequestsBySelector(resolver.GetSelector(ctx)), rrInformer.Informer())
+		})
+
 		return impl
 	}
 }
 
 func filterResolutionRequestsBySelector(selector map[string]string) func(obj interface{}) bool {
 	return func(obj interface{}) bool {
-		rr, ok := obj.(*v1alpha1.ResolutionRequest)
+		rr, ok := resolutionRequestFromObject(obj)
 		if !ok {
 			return false
 		}
@@ -120,25 +137,64 @@ func filterResolutionRequestsBySelector(selector map[string]string) func(obj int
 	}
 }
 
-// TODO(sbwsg): I don't really understand the LeaderAwareness types beyond the
-// fact that the controller crashes if they're missing. It looks
-// like this is bucketing based on labels. Should we use the filter
-// selector from above in the call to lister.List here?
-func leaderAwareFuncs(lister rrlister.ResolutionRequestLister) reconciler.LeaderAwareFuncs {
+// resolutionRequestFromObject returns the ResolutionRequest from an
+// informer event, unwrapping it from a tombstone if the informer missed
+// its deletion.
+func resolutionRequestFromObject(obj interface{}) (*v1alpha1.ResolutionRequest, bool) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	rr, ok := obj.(*v1alpha1.ResolutionRequest)
+	return rr, ok
+}
+
+// leaderAwareFuncs returns the funcs that let a resolver take part in
+// knative's bucket-based leader election. The key space of
+// ResolutionRequests is split into the number of buckets set in the
+// config-leader-election ConfigMap and each replica only resolves the
+// requests in the buckets it currently leads, so resolvers can be
+// scaled out horizontally.
+//
+// When a bucket is promoted every unfinished request in it that
+// matches the resolver's selector is enqueued. Requests that are
+// still in progress may have been claimed by a replica that has since
+// gone away, and enqueuing them lets Reconcile pick them back up once
+// their claim goes stale rather than leaving them to time out.
+//
+// Resolutions run in the background, so when a bucket is demoted any
+// resolutions still running for requests in it are cancelled. Their
+// results are discarded and the new leader of the bucket starts over.
+func leaderAwareFuncs(lister rrlister.ResolutionRequestLister, selector map[string]string, inflight *inflightResolutions) reconciler.LeaderAwareFuncs {
 	return reconciler.LeaderAwareFuncs{
 		PromoteFunc: func(bkt reconciler.Bucket, enq func(reconciler.Bucket, types.NamespacedName)) error {
-			all, err := lister.List(labels.Everything())
+			all, err := lister.List(labels.SelectorFromSet(selector))
 			if err != nil {
 				return err
 			}
 			for _, elt := range all {
-				enq(bkt, types.NamespacedName{
+				if elt.IsDone() {
+					continue
+				}
+				nn := types.NamespacedName{
 					Namespace: elt.GetNamespace(),
 					Name:      elt.GetName(),
-				})
+				}
+				if !bkt.Has(nn) {
+					continue
+				}
+				enq(bkt, nn)
 			}
 			return nil
 		},
+		DemoteFunc: func(bkt reconciler.Bucket) {
+			inflight.cancelWhere(func(key string) bool {
+				namespace, name, err := cache.SplitMetaNamespaceKey(key)
+				if err != nil {
+					return false
+				}
+				return bkt.Has(types.NamespacedName{Namespace: namespace, Name: name})
+			})
+		},
 	}
 }
 
@@ -192,4 +248,13 @@ func applyModifiersAndDefaults(ctx context.Context, r *Reconciler, modifiers []R
 	if r.Clock == nil {
 		r.Clock = clock.RealClock{}
 	}
+
+	if r.MaxResolvedDataSize == 0 {
+		r.MaxResolvedDataSize = DefaultMaxResolvedDataSize
+	}
+
+	if r.Workers <= 0 {
+		r.Workers = defaultResolutionWorkers
+	}
+	r.pool = newResolutionPool(r.Workers)
 }
@@ -0,0 +1,202 @@
+/*
+ Copyright 2022 The Tekton Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package framework
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tektoncd/resolution/pkg/apis/resolution/v1alpha1"
+	resolutioncommon "github.com/tektoncd/resolution/pkg/common"
+	ttesting "github.com/tektoncd/resolution/pkg/reconciler/testing"
+	"github.com/tektoncd/resolution/test"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	"knative.dev/pkg/hash"
+	pkgreconciler "knative.dev/pkg/reconciler"
+)
+
+const shardingTestRequests = 30
+
+func TestPromoteEnqueuesOnlyBucketKeys(t *testing.T) {
+	requests := shardingTestRequestList()
+	requests = append(requests, &v1alpha1.ResolutionRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "other-resolver",
+			Namespace: "foo",
+			Labels: map[string]string{
+				resolutioncommon.LabelKeyResolverType: "other",
+			},
+		},
+	}, &v1alpha1.ResolutionRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "done",
+			Namespace: "foo",
+			Labels: map[string]string{
+				resolutioncommon.LabelKeyResolverType: LabelValueFakeResolverType,
+			},
+		},
+		Status: v1alpha1.ResolutionRequestStatus{
+			Status: duckv1.Status{
+				Conditions: duckv1.Conditions{{
+					Type:   apis.ConditionSucceeded,
+					Status: corev1.ConditionTrue,
+				}},
+			},
+		},
+	})
+	d := test.Data{ResolutionRequests: requests}
+
+	ctx, _ := ttesting.SetupFakeContext(t)
+	testAssets, cancel := getResolverFrameworkController(ctx, t, d, &FakeResolver{}, setClockOnReconciler)
+	defer cancel()
+	r := testAssets.Controller.Reconciler.(*Reconciler)
+	r.Demote(pkgreconciler.UniversalBucket())
+
+	enqueued := map[types.NamespacedName]int{}
+	for _, bkt := range shardingTestBuckets() {
+		if err := r.Promote(bkt, func(b pkgreconciler.Bucket, nn types.NamespacedName) {
+			if !b.Has(nn) {
+				t.Errorf("bucket %s enqueued key %s that it doesn't own", b.Name(), nn)
+			}
+			enqueued[nn]++
+		}); err != nil {
+			t.Fatalf("unexpected error promoting bucket %s: %v", bkt.Name(), err)
+		}
+	}
+
+	for _, rr := range requests[:shardingTestRequests] {
+		nn := types.NamespacedName{Namespace: rr.Namespace, Name: rr.Name}
+		if enqueued[nn] != 1 {
+			t.Errorf("expected %s to be enqueued exactly once, got %d", nn, enqueued[nn])
+		}
+	}
+	for _, name := range []string{"other-resolver", "done"} {
+		if n := enqueued[types.NamespacedName{Namespace: "foo", Name: name}]; n != 0 {
+			t.Errorf("expected %s not to be enqueued, got %d", name, n)
+		}
+	}
+}
+
+func TestShardedReplicasResolveEachRequestOnce(t *testing.T) {
+	requests := shardingTestRequestList()
+	resolver := &countingResolver{
+		FakeResolver: &FakeResolver{ForParam: map[string]*FakeResolvedResource{}},
+		calls:        map[string]int{},
+	}
+	for _, rr := range requests {
+		resolver.ForParam[rr.Name] = &FakeResolvedResource{Content: "some content"}
+	}
+
+	// Each replica gets its own bucket and, like a real replica,
+	// sees every request but should only resolve its own.
+	var replicas []*Reconciler
+	for _, bkt := range shardingTestBuckets() {
+		d := test.Data{ResolutionRequests: shardingTestRequestList()}
+		ctx, _ := ttesting.SetupFakeContext(t)
+		testAssets, cancel := getResolverFrameworkController(ctx, t, d, resolver, setClockOnReconciler, func(r *Reconciler) {
+			r.Workers = shardingTestRequests
+		})
+		defer cancel()
+		r := testAssets.Controller.Reconciler.(*Reconciler)
+		r.Demote(pkgreconciler.UniversalBucket())
+		if err := r.Promote(bkt, nil); err != nil {
+			t.Fatalf("unexpected error promoting bucket %s: %v", bkt.Name(), err)
+		}
+		replicas = append(replicas, r)
+	}
+
+	var wg sync.WaitGroup
+	for _, r := range replicas {
+		for _, rr := range requests {
+			wg.Add(1)
+			go func(r *Reconciler, key string) {
+				defer wg.Done()
+				if err := r.Reconcile(context.Background(), key); err != nil {
+					t.Errorf("unexpected error reconciling %s: %v", key, err)
+				}
+			}(r, getRequestName(rr))
+		}
+	}
+	wg.Wait()
+	for _, r := range replicas {
+		for _, rr := range requests {
+			waitForResolution(t, r, getRequestName(rr))
+		}
+	}
+
+	for _, rr := range requests {
+		if n := resolver.callsFor(rr.Name); n != 1 {
+			t.Errorf("expected %s to be resolved exactly once, got %d", rr.Name, n)
+		}
+	}
+}
+
+// countingResolver is a FakeResolver that counts how many times each
+// param value has been resolved.
+type countingResolver struct {
+	*FakeResolver
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func (r *countingResolver) Resolve(ctx context.Context, params map[string]string) (ResolvedResource, error) {
+	r.mu.Lock()
+	r.calls[params[FakeParamName]]++
+	r.mu.Unlock()
+	return r.FakeResolver.Resolve(ctx, params)
+}
+
+func (r *countingResolver) callsFor(value string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.calls[value]
+}
+
+func shardingTestBuckets() []pkgreconciler.Bucket {
+	return hash.NewBucketSet(sets.NewString("bucket-0", "bucket-1", "bucket-2")).Buckets()
+}
+
+func shardingTestRequestList() []*v1alpha1.ResolutionRequest {
+	var requests []*v1alpha1.ResolutionRequest
+	for i := 0; i < shardingTestRequests; i++ {
+		name := fmt.Sprintf("rr-%d", i)
+		requests = append(requests, &v1alpha1.ResolutionRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Namespace:         "foo",
+				CreationTimestamp: metav1.Time{Time: time.Now()},
+				Labels: map[string]string{
+					resolutioncommon.LabelKeyResolverType: LabelValueFakeResolverType,
+				},
+			},
+			Spec: v1alpha1.ResolutionRequestSpec{
+				Parameters: map[string]string{
+					FakeParamName: name,
+				},
+			},
+		})
+	}
+	return requests
+}
@@ -44,8 +44,8 @@ var _ Resolver = &FakeResolver{}
 // FakeResolvedResource is a framework.ResolvedResource implementation for use with the fake resolver.
 // If it's the value in the FakeResolver's ForParam map for the key given as the fake param value, the FakeResolver will
 // first check if it's got a value for ErrorWith. If so, that string will be returned as an error. Then, if WaitFor is
-// greater than zero, the FakeResolver will wait that long before returning. And finally, the FakeResolvedResource will
-// be returned.
+// greater than zero, the FakeResolver will wait that long, or until its context is done, before returning. And finally,
+// the FakeResolvedResource will be returned.
 type FakeResolvedResource struct {
 	Content       string
 	AnnotationMap map[string]string
@@ -118,7 +118,7 @@ func (r *FakeResolver) ValidateParams(_ context.Context, params map[string]strin
 
 // Resolve performs the work of fetching a file from the fake resolver given a map of
 // parameters.
-func (r *FakeResolver) Resolve(_ context.Context, params map[string]string) (ResolvedResource, error) {
+func (r *FakeResolver) Resolve(ctx context.Context, params map[string]string) (ResolvedResource, error) {
 	paramValue := params[FakeParamName]
 
 	frr, ok := r.ForParam[paramValue]
@@ -131,7 +131,11 @@ func (r *FakeResolver) Resolve(_ context.Context, params map[string]string) (Res
 	}
 
 	if frr.WaitFor.Seconds() > 0 {
-		time.Sleep(frr.WaitFor)
+		select {
+		case <-time.After(frr.WaitFor):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
 	}
 
 	return frr, nil
@@ -0,0 +1,128 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+
+	resolutioncommon "github.com/tektoncd/resolution/pkg/common"
+	corev1 "k8s.io/api/core/v1"
+	"knative.dev/pkg/configmap"
+	"knative.dev/pkg/logging"
+)
+
+// FeatureFlagsConfigMapName is the name of the ConfigMap that
+// resolvers running together in a single process are usually switched
+// on and off with.
+const FeatureFlagsConfigMapName = "resolvers-feature-flags"
+
+// EnabledFlag returns the key in a feature flags ConfigMap that
+// switches the resolver of the given type on or off, e.g.
+// "enable-git-resolver".
+func EnabledFlag(resolverType string) string {
+	return fmt.Sprintf("enable-%s-resolver", resolverType)
+}
+
+// WithFeatureFlags returns a ReconcilerModifier that lets a resolver
+// be switched on and off while it is running by setting its
+// EnabledFlag in the named ConfigMap. Resolvers are enabled unless
+// their flag is set to false.
+//
+// A disabled resolver leaves its requests alone, other than letting
+// resolutions that were already running finish. When the resolver is
+// enabled again every request waiting for it is enqueued.
+func WithFeatureFlags(configMapName string) ReconcilerModifier {
+	return func(r *Reconciler) {
+		r.featureFlags = &resolverSwitch{configMapName: configMapName, enabled: true}
+	}
+}
+
+// resolverSwitch records whether a resolver is currently enabled by
+// its feature flag.
+type resolverSwitch struct {
+	configMapName string
+
+	mu      sync.Mutex
+	enabled bool
+}
+
+// set updates whether the resolver is enabled, returning true if that
+// is a change.
+func (s *resolverSwitch) set(enabled bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changed := enabled != s.enabled
+	s.enabled = enabled
+	return changed
+}
+
+func (s *resolverSwitch) isEnabled() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enabled
+}
+
+// isEnabled returns false if the resolver has been switched off by its
+// feature flag.
+func (r *Reconciler) isEnabled() bool {
+	return r.featureFlags == nil || r.featureFlags.isEnabled()
+}
+
+// watchFeatureFlags keeps a reconciler's feature flag up to date with
+// its ConfigMap, calling resync whenever the resolver is switched back
+// on so that requests that arrived while it was off get resolved.
+func watchFeatureFlags(ctx context.Context, r *Reconciler, cmw configmap.Watcher, resync func()) {
+	if r.featureFlags == nil {
+		return
+	}
+	logger := logging.FromContext(ctx)
+	resolverType := r.resolver.GetSelector(ctx)[resolutioncommon.LabelKeyResolverType]
+	cmw.Watch(r.featureFlags.configMapName, func(cm *corev1.ConfigMap) {
+		enabled, err := resolverEnabled(cm, resolverType)
+		if err != nil {
+			logger.Warnf("ignoring invalid feature flag: %v", err)
+		}
+		if !r.featureFlags.set(enabled) {
+			return
+		}
+		if enabled {
+			logger.Infof("resolver %q enabled", resolverType)
+			resync()
+		} else {
+			logger.Infof("resolver %q disabled", resolverType)
+		}
+	})
+}
+
+// resolverEnabled returns whether the feature flags in cm enable the
+// resolver of the given type. Resolvers without a flag, or with one that
+// can't be parsed, are enabled.
+func resolverEnabled(cm *corev1.ConfigMap, resolverType string) (bool, error) {
+	flag := EnabledFlag(resolverType)
+	val, ok := cm.Data[flag]
+	if !ok {
+		return true, nil
+	}
+	enabled, err := strconv.ParseBool(val)
+	if err != nil {
+		return true, fmt.Errorf("%s: %q is not a boolean", flag, val)
+	}
+	return enabled, nil
+}
@@ -0,0 +1,151 @@
+/*
+ Copyright 2022 The Tekton Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package framework
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tektoncd/resolution/pkg/apis/resolution/v1alpha1"
+	resolutioncommon "github.com/tektoncd/resolution/pkg/common"
+	ttesting "github.com/tektoncd/resolution/pkg/reconciler/testing"
+	"github.com/tektoncd/resolution/test"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"knative.dev/pkg/system"
+)
+
+func TestResolverEnabled(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		data      map[string]string
+		expected  bool
+		expectErr bool
+	}{{
+		name:     "no flag",
+		expected: true,
+	}, {
+		name:     "enabled",
+		data:     map[string]string{"enable-fake-resolver": "true"},
+		expected: true,
+	}, {
+		name:     "disabled",
+		data:     map[string]string{"enable-fake-resolver": "false"},
+		expected: false,
+	}, {
+		name:     "other resolver disabled",
+		data:     map[string]string{"enable-git-resolver": "false"},
+		expected: true,
+	}, {
+		name:      "invalid flag",
+		data:      map[string]string{"enable-fake-resolver": "nope"},
+		expected:  true,
+		expectErr: true,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			enabled, err := resolverEnabled(&corev1.ConfigMap{Data: tc.data}, "fake")
+			if tc.expectErr && err == nil {
+				t.Errorf("expected error but got none")
+			} else if !tc.expectErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if enabled != tc.expected {
+				t.Errorf("expected enabled to be %t, got %t", tc.expected, enabled)
+			}
+		})
+	}
+}
+
+func TestReconcileSwitchedByFeatureFlag(t *testing.T) {
+	inputRequest := &v1alpha1.ResolutionRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "rr",
+			Namespace:         "foo",
+			CreationTimestamp: metav1.Time{Time: time.Now()},
+			Labels: map[string]string{
+				resolutioncommon.LabelKeyResolverType: LabelValueFakeResolverType,
+			},
+		},
+		Spec: v1alpha1.ResolutionRequestSpec{
+			Parameters: map[string]string{
+				FakeParamName: "bar",
+			},
+		},
+	}
+	featureFlags := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      FeatureFlagsConfigMapName,
+			Namespace: system.Namespace(),
+		},
+		Data: map[string]string{
+			EnabledFlag(LabelValueFakeResolverType): "false",
+		},
+	}
+	d := test.Data{
+		ResolutionRequests: []*v1alpha1.ResolutionRequest{inputRequest},
+		ConfigMaps:         []*corev1.ConfigMap{featureFlags},
+	}
+	fakeResolver := &FakeResolver{ForParam: map[string]*FakeResolvedResource{
+		"bar": {Content: "some content"},
+	}}
+
+	ctx, _ := ttesting.SetupFakeContext(t)
+	testAssets, cancel := getResolverFrameworkController(ctx, t, d, fakeResolver, setClockOnReconciler, WithFeatureFlags(FeatureFlagsConfigMapName))
+	defer cancel()
+	r := testAssets.Controller.Reconciler.(*Reconciler)
+	key := getRequestName(inputRequest)
+
+	if err := r.Reconcile(testAssets.Ctx, key); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.inflight.has(key) {
+		t.Fatalf("expected disabled resolver not to resolve request")
+	}
+	c := testAssets.Clients.ResolutionRequests.ResolutionV1alpha1()
+	untouched, err := c.ResolutionRequests(inputRequest.Namespace).Get(testAssets.Ctx, inputRequest.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting ResolutionRequest: %v", err)
+	}
+	if len(untouched.Status.Conditions) != 0 {
+		t.Errorf("expected disabled resolver to leave request alone, got conditions %v", untouched.Status.Conditions)
+	}
+
+	// Switching the resolver back on enqueues the requests that
+	// were left waiting while it was off.
+	featureFlags.Data[EnabledFlag(LabelValueFakeResolverType)] = "true"
+	if _, err := testAssets.Clients.Kube.CoreV1().ConfigMaps(system.Namespace()).Update(testAssets.Ctx, featureFlags, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("updating feature flags: %v", err)
+	}
+	if err := wait.PollImmediate(10*time.Millisecond, 10*time.Second, func() (bool, error) {
+		return r.isEnabled() && testAssets.Controller.WorkQueue().Len() > 0, nil
+	}); err != nil {
+		t.Fatalf("expected request to be enqueued once resolver was enabled: %v", err)
+	}
+
+	if err := r.Reconcile(testAssets.Ctx, key); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	waitForResolution(t, r, key)
+	reconciledRR, err := c.ResolutionRequests(inputRequest.Namespace).Get(testAssets.Ctx, inputRequest.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting updated ResolutionRequest: %v", err)
+	}
+	if reconciledRR.Status.Data == "" {
+		t.Errorf("expected request to be resolved once resolver was enabled")
+	}
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"context"
+	"sync"
+)
+
+// inflightResolutions tracks the resolutions that are currently
+// running, keyed by their ResolutionRequest's namespace/name, so that
+// they can be cancelled if the request goes away.
+type inflightResolutions struct {
+	mu          sync.Mutex
+	resolutions map[string]*inflightResolution
+}
+
+// inflightResolution is a single running resolution.
+type inflightResolution struct {
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	deleted bool
+}
+
+func newInflightResolutions() *inflightResolutions {
+	return &inflightResolutions{
+		resolutions: map[string]*inflightResolution{},
+	}
+}
+
+// add starts tracking a resolution for key. The given cancel func is
+// called if the resolution is cancelled.
+func (i *inflightResolutions) add(key string, cancel context.CancelFunc) *inflightResolution {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	resolution := &inflightResolution{cancel: cancel}
+	i.resolutions[key] = resolution
+	return resolution
+}
+
+// remove stops tracking a resolution once it has finished. It is a
+// no-op if a newer resolution has since been added for the same key.
+func (i *inflightResolutions) remove(key string, resolution *inflightResolution) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.resolutions[key] == resolution {
+		delete(i.resolutions, key)
+	}
+}
+
+// has returns true if a resolution is currently running for key.
+func (i *inflightResolutions) has(key string) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	_, ok := i.resolutions[key]
+	return ok
+}
+
+// cancelDeleted cancels the resolution running for key, if any, and
+// records that it was cancelled because its request was deleted. It
+// returns true if a resolution was cancelled.
+func (i *inflightResolutions) cancelDeleted(key string) bool {
+	i.mu.Lock()
+	resolution, ok := i.resolutions[key]
+	i.mu.Unlock()
+	if !ok {
+		return false
+	}
+	resolution.mu.Lock()
+	resolution.deleted = true
+	resolution.mu.Unlock()
+	resolution.cancel()
+	return true
+}
+
+// cancelWhere cancels every running resolution whose key matches.
+func (i *inflightResolutions) cancelWhere(match func(key string) bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for key, resolution := range i.resolutions {
+		if match(key) {
+			resolution.cancel()
+		}
+	}
+}
+
+// isDeleted returns true if the resolution was cancelled because its
+// request was deleted.
+func (r *inflightResolution) isDeleted() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.deleted
+}
@@ -89,6 +89,34 @@ type TimedResolution interface {
 	GetResolutionTimeout(context.Context, time.Duration) time.Duration
 }
 
+// ResolutionCleaner is an optional interface that a resolver can
+// implement to clean up after a resolution that was cancelled because
+// its ResolutionRequest was deleted, e.g. a PipelineRun was cancelled
+// while its Task was still being fetched.
+//
+// The framework cancels the context passed to Resolve as soon as the
+// request is deleted and calls Cleanup once Resolve has returned.
+type ResolutionCleaner interface {
+	// Cleanup receives the parameters of the deleted request
+	// along with a context that includes any request-scoped data
+	// like resolver config and the request's originating namespace.
+	Cleanup(context.Context, map[string]string) error
+}
+
+// ClusterShareable is an optional interface that a resolver can
+// implement to declare that the result of a request doesn't depend on
+// the namespace it was made from, e.g. because it is fetched from a
+// public location without any namespace-scoped credentials.
+//
+// Results that are shareable are annotated as such so that clients
+// using a shared resolution cache can hand them out to requests from
+// other namespaces.
+type ClusterShareable interface {
+	// IsClusterShareable receives the parameters of a request and
+	// returns true if its result can be shared across namespaces.
+	IsClusterShareable(context.Context, map[string]string) bool
+}
+
 // ResolvedResource returns the data and annotations of a successful
 // resource fetch.
 type ResolvedResource interface {
@@ -0,0 +1,72 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"context"
+	"sync"
+)
+
+// defaultResolutionWorkers is the number of resolutions that a
+// reconciler runs at once if not overridden.
+const defaultResolutionWorkers = 10
+
+// resolutionPool is a fixed-size pool of workers that run resolutions
+// in the background, so that a slow resolution doesn't tie up one of
+// the controller's workqueue threads.
+type resolutionPool struct {
+	work    chan func()
+	workers int
+	once    sync.Once
+}
+
+func newResolutionPool(workers int) *resolutionPool {
+	return &resolutionPool{
+		work:    make(chan func()),
+		workers: workers,
+	}
+}
+
+// start launches the pool's workers. They run until ctx is done.
+// Calling start more than once has no further effect.
+func (p *resolutionPool) start(ctx context.Context) {
+	p.once.Do(func() {
+		for i := 0; i < p.workers; i++ {
+			go func() {
+				for {
+					select {
+					case <-ctx.Done():
+						return
+					case job := <-p.work:
+						job()
+					}
+				}
+			}()
+		}
+	})
+}
+
+// trySubmit hands job to an idle worker. It returns false without
+// running job if every worker is busy.
+func (p *resolutionPool) trySubmit(job func()) bool {
+	select {
+	case p.work <- job:
+		return true
+	default:
+		return false
+	}
+}
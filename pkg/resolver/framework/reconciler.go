@@ -28,11 +28,13 @@ import (
 	rrclient "github.com/tektoncd/resolution/pkg/client/clientset/versioned"
 	rrv1alpha1 "github.com/tektoncd/resolution/pkg/client/listers/resolution/v1alpha1"
 	resolutioncommon "github.com/tektoncd/resolution/pkg/common"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/utils/clock"
+	"knative.dev/pkg/apis"
 	"knative.dev/pkg/controller"
 	"knative.dev/pkg/logging"
 	"knative.dev/pkg/reconciler"
@@ -49,12 +51,54 @@ type Reconciler struct {
 	// and can be overridden for tests.
 	Clock clock.PassiveClock
 
+	// ConcurrencyLimits are the default limits on how many requests
+	// are resolved at once. They can be overridden by an admin via
+	// the resolver's config.
+	ConcurrencyLimits ConcurrencyLimits
+
+	// MaxResolvedDataSize is the default limit on the size in bytes
+	// of the data a resolver may return. It can be overridden by an
+	// admin via the resolver's config. Defaults to
+	// DefaultMaxResolvedDataSize; a negative value removes the limit.
+	MaxResolvedDataSize int64
+
+	// Workers is the number of resolutions the reconciler will run
+	// in the background at once, regardless of ConcurrencyLimits.
+	Workers int
+
 	resolver                   Resolver
 	kubeClientSet              kubernetes.Interface
 	resolutionRequestLister    rrv1alpha1.ResolutionRequestLister
 	resolutionRequestClientSet rrclient.Interface
 
 	configStore *ConfigStore
+	throttle    *resolutionThrottle
+	inflight    *inflightResolutions
+	pool        *resolutionPool
+
+	// featureFlags is set if the resolver can be switched on and
+	// off by a feature flag.
+	featureFlags *resolverSwitch
+
+	// validateResources is set if resolved data must be a Tekton
+	// resource.
+	validateResources bool
+
+	// contentRules are checked against every resolved resource
+	// before it is written to its request.
+	contentRules []ContentRule
+
+	// contentPolicy is set if content rules are also read from a
+	// ConfigMap.
+	contentPolicy *contentPolicyWatch
+
+	// identity uniquely identifies this replica of the resolver in
+	// the claims it makes on requests.
+	identity string
+
+	// enqueueAfter schedules a request to be reconciled again, e.g.
+	// after a retryable error.
+	enqueueAfter func(types.NamespacedName, time.Duration)
 }
 
 var _ reconciler.LeaderAware = &Reconciler{}
@@ -68,8 +112,8 @@ var _ reconciler.LeaderAware = &Reconciler{}
 const defaultMaximumResolutionDuration = time.Minute
 
 // Reconcile receives the string key of a ResolutionRequest object, looks
-// it up, checks it for common errors, and then delegates
-// resolver-specific functionality to the reconciler's embedded
+// it up, checks it for common errors, and then hands the request off
+// to be resolved in the background by the reconciler's embedded
 // type-specific resolver. Any errors that occur during validation or
 // resolution are handled by updating or failing the ResolutionRequest.
 func (r *Reconciler) Reconcile(ctx context.Context, key string) error {
@@ -79,16 +123,61 @@ func (r *Reconciler) Reconcile(ctx context.Context, key string) error {
 		return controller.NewPermanentError(err)
 	}
 
+	if !r.IsLeaderFor(types.NamespacedName{Namespace: namespace, Name: name}) {
+		// Another replica leads the bucket this request falls
+		// into and is responsible for resolving it.
+		return nil
+	}
+
+	if !r.isEnabled() {
+		// The resolver has been switched off. Its requests are
+		// enqueued again if it is switched back on.
+		return nil
+	}
+
 	rr, err := r.resolutionRequestLister.ResolutionRequests(namespace).Get(name)
 	if err != nil {
 		err := &resolutioncommon.ErrorGettingResource{ResolverName: "resolutionrequest", Key: key, Original: err}
 		return controller.NewPermanentError(err)
 	}
 
-	if rr.IsDone() {
+	if isResolved(rr) {
+		return nil
+	}
+
+	if r.inflight.has(key) {
+		// Already being resolved; the result will be written
+		// when the running resolution completes.
 		return nil
 	}
 
+	if r.claimedHere(rr) {
+		// This replica has resolved the request before, and its
+		// own claim refreshes enqueue it again, so the lister may
+		// not have caught up with the result it wrote yet. The
+		// live object is checked so that the request isn't
+		// resolved a second time.
+		latest, err := r.resolutionRequestClientSet.ResolutionV1alpha1().ResolutionRequests(namespace).Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if isResolved(latest) {
+			return nil
+		}
+		rr = latest
+	}
+
+	if wait := r.heldElsewhere(rr); wait > 0 {
+		// Another replica claimed this request recently and may
+		// still be finishing it. If it doesn't, e.g. because it
+		// crashed, the request is picked up here once the claim
+		// goes stale.
+		return controller.NewRequeueAfter(wait)
+	}
+
 	// Inject request-scoped information into the context, such as
 	// the namespace that the request originates from and the
 	// configuration from the configmap this resolver is watching.
@@ -96,27 +185,117 @@ func (r *Reconciler) Reconcile(ctx context.Context, key string) error {
 	if r.configStore != nil {
 		ctx = r.configStore.ToContext(ctx)
 	}
+	ctx = InjectMaxResolvedDataSize(ctx, r.maxResolvedDataSize(ctx))
+
+	release, queuedMessage := r.throttle.acquire(r.concurrencyLimits(ctx), r.Clock.Now(), namespace, key)
+	if release == nil {
+		return r.queue(ctx, rr, queuedMessage)
+	}
+
+	// A new context is created for resolution so that it can be
+	// cancelled, e.g. if the request is deleted, without affecting
+	// other uses of ctx (e.g. sending Updates to ResolutionRequest
+	// objects).
+	resolutionCtx, cancelFn := context.WithCancel(ctx)
+	resolution := r.inflight.add(key, cancelFn)
+	submitted := r.pool.trySubmit(func() {
+		defer release()
+		defer r.inflight.remove(key, resolution)
+		defer cancelFn()
+		// Progress is reported on the request as returned by the
+		// claim, since the informer's cache won't have caught up
+		// with the claim yet.
+		latest, err := r.claim(ctx, rr)
+		if err != nil {
+			logging.FromContext(ctx).Warnf("error claiming %q: %v", key, err)
+			latest = rr
+		}
+		if err := r.markInProgress(ctx, latest, fmt.Sprintf("resolution in progress by resolver %q", r.resolver.GetName(ctx))); err != nil {
+			logging.FromContext(ctx).Warnf("error reporting resolution of %q as in progress: %v", key, err)
+		}
+		if err := r.resolve(ctx, resolutionCtx, key, rr, resolution); err != nil {
+			logging.FromContext(ctx).Debugf("resolution of %q failed: %v", key, err)
+		}
+	})
+	if !submitted {
+		cancelFn()
+		r.inflight.remove(key, resolution)
+		release()
+		return r.queue(ctx, rr, "waiting for a free resolution worker")
+	}
+
+	return nil
+}
+
+// isResolved returns true if a request is done or its data has been
+// written and it's waiting for the ResolutionRequest controller to
+// mark it as done.
+func isResolved(rr *v1alpha1.ResolutionRequest) bool {
+	return rr.IsDone() || rr.Status.Data != ""
+}
 
-	return r.resolve(ctx, key, rr)
+// queue reports that a request is waiting to be resolved and requeues
+// it to be tried again shortly. The request's status is only updated
+// when its message changes so that requests waiting a long time don't
+// add load to the API server every time they are retried.
+func (r *Reconciler) queue(ctx context.Context, rr *v1alpha1.ResolutionRequest, queuedMessage string) error {
+	if err := r.MarkInProgress(ctx, rr, fmt.Sprintf("queued: %s", queuedMessage)); err != nil {
+		return err
+	}
+	return controller.NewRequeueAfter(queuedRequeueDelay)
 }
 
-func (r *Reconciler) resolve(ctx context.Context, key string, rr *v1alpha1.ResolutionRequest) error {
-	errChan := make(chan error)
-	resourceChan := make(chan ResolvedResource)
+// concurrencyLimits returns the reconciler's default concurrency
+// limits with any overrides from the resolver's config applied.
+func (r *Reconciler) concurrencyLimits(ctx context.Context) ConcurrencyLimits {
+	limits, err := ConcurrencyLimitsFromConfig(r.ConcurrencyLimits, GetResolverConfigFromContext(ctx))
+	if err != nil {
+		logging.FromContext(ctx).Warnf("ignoring invalid resolver config: %v", err)
+	}
+	return limits
+}
+
+// maxResolvedDataSize returns the reconciler's default limit on the
+// size of resolved data with any override from the resolver's config
+// applied.
+func (r *Reconciler) maxResolvedDataSize(ctx context.Context) int64 {
+	defaultSize := r.MaxResolvedDataSize
+	if defaultSize < 0 {
+		defaultSize = 0
+	}
+	size, err := maxResolvedDataSize(defaultSize, GetResolverConfigFromContext(ctx))
+	if err != nil {
+		logging.FromContext(ctx).Warnf("ignoring invalid resolver config: %v", err)
+	}
+	return size
+}
+
+// resolve runs a single resolution to completion and records the
+// outcome on the ResolutionRequest. It is run by a worker from the
+// reconciler's pool.
+func (r *Reconciler) resolve(ctx, resolutionCtx context.Context, key string, rr *v1alpha1.ResolutionRequest, resolution *inflightResolution) error {
+	errChan := make(chan error, 1)
+	resourceChan := make(chan ResolvedResource, 1)
+	finished := make(chan struct{})
 
 	timeoutDuration := defaultMaximumResolutionDuration
 	if timed, ok := r.resolver.(TimedResolution); ok {
 		timeoutDuration = timed.GetResolutionTimeout(ctx, defaultMaximumResolutionDuration)
 	}
 
-	// A new context is created for resolution so that timeouts can
-	// be enforced without affecting other uses of ctx (e.g. sending
-	// Updates to ResolutionRequest objects).
-	resolutionCtx, cancelFn := context.WithTimeout(ctx, timeoutDuration)
+	resolutionCtx, cancelFn := context.WithTimeout(resolutionCtx, timeoutDuration)
 	defer cancelFn()
 
+	// Resolve in a separate goroutine so that the timeout is
+	// enforced, and the worker freed up, even if the resolver
+	// doesn't respect its context.
 	go func() {
+		defer close(finished)
 		validationError := r.resolver.ValidateParams(resolutionCtx, rr.Spec.Parameters)
+		if IsErrorRetryable(validationError) {
+			errChan <- validationError
+			return
+		}
 		if validationError != nil {
 			errChan <- &resolutioncommon.ErrorInvalidRequest{
 				ResolutionRequestKey: key,
@@ -126,30 +305,100 @@ func (r *Reconciler) resolve(ctx context.Context, key string, rr *v1alpha1.Resol
 		}
 		resource, resolveErr := r.resolver.Resolve(resolutionCtx, rr.Spec.Parameters)
 		if resolveErr != nil {
-			errChan <- &resolutioncommon.ErrorGettingResource{
+			var err error = &resolutioncommon.ErrorGettingResource{
 				ResolverName: r.resolver.GetName(resolutionCtx),
 				Key:          key,
 				Original:     resolveErr,
 			}
+			// Keep the reason of errors like exceeding the
+			// size limit that resolvers report themselves.
+			var reasoned *resolutioncommon.Error
+			if errors.As(resolveErr, &reasoned) {
+				err = resolutioncommon.NewError(reasoned.Reason, err)
+			}
+			if IsErrorRetryable(resolveErr) {
+				err = NewErrorRetryable(err)
+			}
+			errChan <- err
 			return
 		}
 		resourceChan <- resource
 	}()
 
+	// Keep the claim on the request fresh for as long as the
+	// resolver runs so that other replicas don't mistake a slow
+	// resolution for an orphaned one.
+	stopRefreshing := r.refreshClaim(ctx, rr, claimRefreshInterval)
+	defer stopRefreshing()
+
+	var resource ResolvedResource
+	var err error
 	select {
-	case err := <-errChan:
-		if err != nil {
-			return r.OnError(ctx, rr, err)
-		}
+	case err = <-errChan:
+	case resource = <-resourceChan:
 	case <-resolutionCtx.Done():
-		if err := resolutionCtx.Err(); err != nil {
-			return r.OnError(ctx, rr, err)
+		err = resolutionCtx.Err()
+	}
+	stopRefreshing()
+
+	if resolution.isDeleted() {
+		// The request is gone so there's nobody to report back
+		// to, but the resolver may want to clean up after itself
+		// once it has stopped.
+		go r.cleanupDeleted(ctx, key, rr, finished)
+		return nil
+	}
+
+	if !r.IsLeaderFor(types.NamespacedName{Namespace: rr.Namespace, Name: rr.Name}) {
+		// Leadership of this request moved elsewhere while it was
+		// being resolved. The new leader will resolve it again so
+		// the result is dropped rather than racing with theirs.
+		logging.FromContext(ctx).Infof("dropping result of %q: no longer leader", key)
+		return nil
+	}
+
+	if resource == nil {
+		// A resolver that gives up because its context expired
+		// should be reported as having timed out rather than
+		// with whatever error it returned.
+		if ctxErr := resolutionCtx.Err(); ctxErr != nil {
+			err = ctxErr
+		} else if IsErrorRetryable(err) {
+			return r.retryLater(ctx, rr, err)
 		}
-	case resource := <-resourceChan:
-		return r.writeResolvedData(ctx, rr, resource)
+		return r.OnError(ctx, rr, err)
 	}
+	if err := r.checkResolvedResource(ctx, rr.Spec.Parameters, resource); err != nil {
+		return r.OnError(ctx, rr, err)
+	}
+	return r.writeResolvedData(ctx, rr, resource)
+}
 
-	return errors.New("unknown error")
+// cancelDeleted cancels any in-flight resolution for a
+// ResolutionRequest that has been deleted.
+func (r *Reconciler) cancelDeleted(ctx context.Context, obj interface{}) {
+	rr, ok := resolutionRequestFromObject(obj)
+	if !ok {
+		return
+	}
+	key := fmt.Sprintf("%s/%s", rr.Namespace, rr.Name)
+	if r.inflight.cancelDeleted(key) {
+		logging.FromContext(ctx).Infof("cancelled in-flight resolution of deleted resolutionrequest %q", key)
+	}
+}
+
+// cleanupDeleted waits for a cancelled resolution to stop and then
+// gives the resolver a chance to clean up any partial state, if it
+// implements the ResolutionCleaner interface.
+func (r *Reconciler) cleanupDeleted(ctx context.Context, key string, rr *v1alpha1.ResolutionRequest, finished <-chan struct{}) {
+	cleaner, ok := r.resolver.(ResolutionCleaner)
+	if !ok {
+		return
+	}
+	<-finished
+	if err := cleaner.Cleanup(ctx, rr.Spec.Parameters); err != nil {
+		logging.FromContext(ctx).Warnf("error cleaning up after deleted resolutionrequest %q: %v", key, err)
+	}
 }
 
 // OnError is used to handle any situation where a ResolutionRequest has
@@ -188,6 +437,43 @@ func (r *Reconciler) MarkFailed(ctx context.Context, rr *v1alpha1.ResolutionRequ
 	return nil
 }
 
+// MarkInProgress updates a ResolutionRequest as still being in
+// progress with the given message. The request is read from the
+// informer's cache and nothing is written if it already has the
+// message. It returns errors that occur during the update process or
+// nil if the update appeared to succeed.
+func (r *Reconciler) MarkInProgress(ctx context.Context, rr *v1alpha1.ResolutionRequest, message string) error {
+	key := fmt.Sprintf("%s/%s", rr.Namespace, rr.Name)
+	latestGeneration, err := r.resolutionRequestLister.ResolutionRequests(rr.Namespace).Get(rr.Name)
+	if err != nil {
+		logging.FromContext(ctx).Warnf("error getting latest generation of resolutionrequest %q: %v", key, err)
+		return err
+	}
+	return r.markInProgress(ctx, latestGeneration, message)
+}
+
+// markInProgress updates latestGeneration, which may be shared with
+// the informer's cache, as still being in progress with the given
+// message unless it already has it. A stale latestGeneration is
+// rejected by the API server as a conflict.
+func (r *Reconciler) markInProgress(ctx context.Context, latestGeneration *v1alpha1.ResolutionRequest, message string) error {
+	key := fmt.Sprintf("%s/%s", latestGeneration.Namespace, latestGeneration.Name)
+	if latestGeneration.IsDone() {
+		return nil
+	}
+	if cond := latestGeneration.Status.GetCondition(apis.ConditionSucceeded); cond != nil && cond.Message == message {
+		return nil
+	}
+	latestGeneration = latestGeneration.DeepCopy()
+	latestGeneration.Status.MarkInProgress(message)
+	_, err := r.resolutionRequestClientSet.ResolutionV1alpha1().ResolutionRequests(latestGeneration.Namespace).UpdateStatus(ctx, latestGeneration, metav1.UpdateOptions{})
+	if err != nil {
+		logging.FromContext(ctx).Warnf("error marking resolutionrequest %q as in progress: %v", key, err)
+		return err
+	}
+	return nil
+}
+
 // statusDataPatch is the json structure that will be PATCHed into
 // a ResolutionRequest with its data and annotations once successfully
 // resolved.
@@ -198,10 +484,18 @@ type statusDataPatch struct {
 
 func (r *Reconciler) writeResolvedData(ctx context.Context, rr *v1alpha1.ResolutionRequest, resource ResolvedResource) error {
 	encodedData := base64.StdEncoding.Strict().EncodeToString(resource.Data())
+	annotations := resource.Annotations()
+	if shareable, ok := r.resolver.(ClusterShareable); ok && shareable.IsClusterShareable(ctx, rr.Spec.Parameters) {
+		annotations = map[string]string{}
+		for key, val := range resource.Annotations() {
+			annotations[key] = val
+		}
+		annotations[resolutioncommon.AnnotationKeyClusterShareable] = "true"
+	}
 	patchBytes, err := json.Marshal(map[string]statusDataPatch{
 		"status": {
 			Data:        encodedData,
-			Annotations: resource.Annotations(),
+			Annotations: annotations,
 		},
 	})
 	if err != nil {
@@ -32,9 +32,11 @@ import (
 	"github.com/tektoncd/resolution/test"
 	"github.com/tektoncd/resolution/test/diff"
 	"github.com/tektoncd/resolution/test/names"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
 	"knative.dev/pkg/apis"
 	duckv1 "knative.dev/pkg/apis/duck/v1"
@@ -115,6 +117,12 @@ func TestReconcile(t *testing.T) {
 			},
 			expectedStatus: &v1alpha1.ResolutionRequestStatus{
 				Status: duckv1.Status{
+					Conditions: duckv1.Conditions{{
+						Type:    apis.ConditionSucceeded,
+						Status:  corev1.ConditionUnknown,
+						Reason:  resolutioncommon.ReasonResolutionInProgress,
+						Message: `resolution in progress by resolver "Fake"`,
+					}},
 					Annotations: map[string]string{
 						"foo": "bar",
 					},
@@ -199,34 +207,477 @@ func TestReconcile(t *testing.T) {
 			defer cancel()
 
 			err := testAssets.Controller.Reconciler.Reconcile(testAssets.Ctx, getRequestName(tc.inputRequest))
-			if tc.expectedErr != nil {
-				if err == nil {
-					t.Fatalf("expected to get error %v, but got nothing", tc.expectedErr)
-				}
-				if tc.expectedErr.Error() != err.Error() {
-					t.Fatalf("expected to get error %v, but got %v", tc.expectedErr, err)
-				}
-			} else {
-				if err != nil {
-					if ok, _ := controller.IsRequeueKey(err); !ok {
-						t.Fatalf("did not expect an error, but got %v", err)
-					}
-				}
+			if err != nil {
+				t.Fatalf("did not expect an error, but got %v", err)
+			}
+			waitForResolution(t, testAssets.Controller.Reconciler.(*Reconciler), getRequestName(tc.inputRequest))
 
-				c := testAssets.Clients.ResolutionRequests.ResolutionV1alpha1()
-				reconciledRR, err := c.ResolutionRequests(tc.inputRequest.Namespace).Get(testAssets.Ctx, tc.inputRequest.Name, metav1.GetOptions{})
-				if err != nil {
-					t.Fatalf("getting updated ResolutionRequest: %v", err)
+			c := testAssets.Clients.ResolutionRequests.ResolutionV1alpha1()
+			reconciledRR, err := c.ResolutionRequests(tc.inputRequest.Namespace).Get(testAssets.Ctx, tc.inputRequest.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("getting updated ResolutionRequest: %v", err)
+			}
+			if tc.expectedErr != nil {
+				condition := reconciledRR.Status.GetCondition(apis.ConditionSucceeded)
+				if condition == nil || !condition.IsFalse() {
+					t.Fatalf("expected request to fail with error %v, but got condition %v", tc.expectedErr, condition)
 				}
-				if d := cmp.Diff(*tc.expectedStatus, reconciledRR.Status, ignoreLastTransitionTime); d != "" {
-					t.Errorf("ResolutionRequest status doesn't match %s", diff.PrintWantGot(d))
-
+				if tc.expectedErr.Error() != condition.Message {
+					t.Fatalf("expected to get error %v, but got %v", tc.expectedErr, condition.Message)
 				}
+			} else if d := cmp.Diff(*tc.expectedStatus, reconciledRR.Status, ignoreLastTransitionTime); d != "" {
+				t.Errorf("ResolutionRequest status doesn't match %s", diff.PrintWantGot(d))
 			}
 		})
 	}
 }
 
+func TestReconcileQueuedWhenThrottled(t *testing.T) {
+	inputRequest := &v1alpha1.ResolutionRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "rr",
+			Namespace:         "foo",
+			CreationTimestamp: metav1.Time{Time: time.Now()},
+			Labels: map[string]string{
+				resolutioncommon.LabelKeyResolverType: LabelValueFakeResolverType,
+			},
+		},
+		Spec: v1alpha1.ResolutionRequestSpec{
+			Parameters: map[string]string{
+				FakeParamName: "bar",
+			},
+		},
+	}
+	d := test.Data{
+		ResolutionRequests: []*v1alpha1.ResolutionRequest{inputRequest},
+	}
+	fakeResolver := &FakeResolver{ForParam: map[string]*FakeResolvedResource{
+		"bar": {Content: "some content"},
+	}}
+
+	ctx, _ := ttesting.SetupFakeContext(t)
+	testAssets, cancel := getResolverFrameworkController(ctx, t, d, fakeResolver, setClockOnReconciler, func(r *Reconciler) {
+		r.ConcurrencyLimits = ConcurrencyLimits{MaxConcurrentPerNamespace: 1}
+	})
+	defer cancel()
+
+	// Occupy the namespace's only slot.
+	r := testAssets.Controller.Reconciler.(*Reconciler)
+	release, _ := r.throttle.acquire(r.ConcurrencyLimits, now, "foo", "foo/other")
+	if release == nil {
+		t.Fatalf("expected to acquire slot")
+	}
+
+	err := testAssets.Controller.Reconciler.Reconcile(testAssets.Ctx, getRequestName(inputRequest))
+	if ok, delay := controller.IsRequeueKey(err); !ok || delay != queuedRequeueDelay {
+		t.Fatalf("expected request to be requeued after %s, got %v", queuedRequeueDelay, err)
+	}
+
+	c := testAssets.Clients.ResolutionRequests.ResolutionV1alpha1()
+	reconciledRR, err := c.ResolutionRequests(inputRequest.Namespace).Get(testAssets.Ctx, inputRequest.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting updated ResolutionRequest: %v", err)
+	}
+	expectedStatus := v1alpha1.ResolutionRequestStatus{
+		Status: duckv1.Status{
+			Conditions: duckv1.Conditions{{
+				Type:    apis.ConditionSucceeded,
+				Status:  corev1.ConditionUnknown,
+				Reason:  resolutioncommon.ReasonResolutionInProgress,
+				Message: `queued: namespace "foo" has reached its limit of 1 concurrent resolutions`,
+			}},
+		},
+	}
+	if d := cmp.Diff(expectedStatus, reconciledRR.Status, ignoreLastTransitionTime); d != "" {
+		t.Errorf("ResolutionRequest status doesn't match %s", diff.PrintWantGot(d))
+	}
+
+	// Retrying a request that is still queued for the same reason
+	// doesn't touch the API server.
+	testAssets.Clients.ResolutionRequests.ClearActions()
+	err = testAssets.Controller.Reconciler.Reconcile(testAssets.Ctx, getRequestName(inputRequest))
+	if ok, _ := controller.IsRequeueKey(err); !ok {
+		t.Fatalf("expected request to be requeued, got %v", err)
+	}
+	if actions := testAssets.Clients.ResolutionRequests.Actions(); len(actions) != 0 {
+		t.Errorf("expected no API calls for a request that is still queued, got %v", actions)
+	}
+
+	release()
+	err = testAssets.Controller.Reconciler.Reconcile(testAssets.Ctx, getRequestName(inputRequest))
+	if err != nil {
+		t.Fatalf("unexpected error once slot was released: %v", err)
+	}
+	waitForResolution(t, r, getRequestName(inputRequest))
+	reconciledRR, err = c.ResolutionRequests(inputRequest.Namespace).Get(testAssets.Ctx, inputRequest.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting updated ResolutionRequest: %v", err)
+	}
+	if reconciledRR.Status.Data == "" {
+		t.Errorf("expected request to be resolved once slot was released")
+	}
+}
+
+func TestReconcileCancelledWhenDeleted(t *testing.T) {
+	inputRequest := &v1alpha1.ResolutionRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "rr",
+			Namespace:         "foo",
+			CreationTimestamp: metav1.Time{Time: time.Now()},
+			Labels: map[string]string{
+				resolutioncommon.LabelKeyResolverType: LabelValueFakeResolverType,
+			},
+		},
+		Spec: v1alpha1.ResolutionRequestSpec{
+			Parameters: map[string]string{
+				FakeParamName: "bar",
+			},
+		},
+	}
+	d := test.Data{
+		ResolutionRequests: []*v1alpha1.ResolutionRequest{inputRequest},
+	}
+	resolver := &cleanupRecordingResolver{
+		FakeResolver: &FakeResolver{ForParam: map[string]*FakeResolvedResource{
+			"bar": {WaitFor: time.Minute},
+		}},
+		cleanedUp: make(chan map[string]string, 1),
+	}
+
+	ctx, _ := ttesting.SetupFakeContext(t)
+	testAssets, cancel := getResolverFrameworkController(ctx, t, d, resolver, setClockOnReconciler)
+	defer cancel()
+	r := testAssets.Controller.Reconciler.(*Reconciler)
+	key := getRequestName(inputRequest)
+
+	if err := r.Reconcile(testAssets.Ctx, key); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !r.inflight.has(key) {
+		t.Fatalf("expected resolution to be running")
+	}
+
+	c := testAssets.Clients.ResolutionRequests.ResolutionV1alpha1()
+	if err := c.ResolutionRequests(inputRequest.Namespace).Delete(testAssets.Ctx, inputRequest.Name, metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("deleting ResolutionRequest: %v", err)
+	}
+	r.cancelDeleted(testAssets.Ctx, cache.DeletedFinalStateUnknown{Key: key, Obj: inputRequest})
+	waitForResolution(t, r, key)
+
+	select {
+	case params := <-resolver.cleanedUp:
+		if d := cmp.Diff(inputRequest.Spec.Parameters, params); d != "" {
+			t.Errorf("unexpected params passed to Cleanup %s", diff.PrintWantGot(d))
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("timed out waiting for resolver to clean up")
+	}
+}
+
+func TestReconcileDoesNotWaitForResolution(t *testing.T) {
+	inputRequest := &v1alpha1.ResolutionRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "rr",
+			Namespace:         "foo",
+			CreationTimestamp: metav1.Time{Time: time.Now()},
+			Labels: map[string]string{
+				resolutioncommon.LabelKeyResolverType: LabelValueFakeResolverType,
+			},
+		},
+		Spec: v1alpha1.ResolutionRequestSpec{
+			Parameters: map[string]string{
+				FakeParamName: "bar",
+			},
+		},
+	}
+	d := test.Data{
+		ResolutionRequests: []*v1alpha1.ResolutionRequest{inputRequest},
+	}
+	fakeResolver := &FakeResolver{ForParam: map[string]*FakeResolvedResource{
+		"bar": {Content: "some content", WaitFor: 500 * time.Millisecond},
+	}}
+
+	ctx, _ := ttesting.SetupFakeContext(t)
+	testAssets, cancel := getResolverFrameworkController(ctx, t, d, fakeResolver, setClockOnReconciler, func(r *Reconciler) {
+		r.Workers = 1
+	})
+	defer cancel()
+	r := testAssets.Controller.Reconciler.(*Reconciler)
+	key := getRequestName(inputRequest)
+
+	if err := r.Reconcile(testAssets.Ctx, key); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !r.inflight.has(key) {
+		t.Fatalf("expected Reconcile to return while resolution is still running")
+	}
+	// Reconciling the same request again while it's running must not
+	// start a second resolution.
+	if err := r.Reconcile(testAssets.Ctx, key); err != nil {
+		t.Fatalf("unexpected error reconciling running request: %v", err)
+	}
+
+	waitForResolution(t, r, key)
+	c := testAssets.Clients.ResolutionRequests.ResolutionV1alpha1()
+	reconciledRR, err := c.ResolutionRequests(inputRequest.Namespace).Get(testAssets.Ctx, inputRequest.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting updated ResolutionRequest: %v", err)
+	}
+	if reconciledRR.Status.Data == "" {
+		t.Errorf("expected request to be resolved in the background")
+	}
+}
+
+func TestReconcileQueuedWhenWorkersBusy(t *testing.T) {
+	inputRequest := &v1alpha1.ResolutionRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "rr",
+			Namespace:         "foo",
+			CreationTimestamp: metav1.Time{Time: time.Now()},
+			Labels: map[string]string{
+				resolutioncommon.LabelKeyResolverType: LabelValueFakeResolverType,
+			},
+		},
+		Spec: v1alpha1.ResolutionRequestSpec{
+			Parameters: map[string]string{
+				FakeParamName: "bar",
+			},
+		},
+	}
+	d := test.Data{
+		ResolutionRequests: []*v1alpha1.ResolutionRequest{inputRequest},
+	}
+	fakeResolver := &FakeResolver{ForParam: map[string]*FakeResolvedResource{
+		"bar": {Content: "some content"},
+	}}
+
+	ctx, _ := ttesting.SetupFakeContext(t)
+	testAssets, cancel := getResolverFrameworkController(ctx, t, d, fakeResolver, setClockOnReconciler, func(r *Reconciler) {
+		r.Workers = 1
+	})
+	defer cancel()
+	r := testAssets.Controller.Reconciler.(*Reconciler)
+
+	// Occupy the only worker.
+	unblock := make(chan struct{})
+	for !r.pool.trySubmit(func() { <-unblock }) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	defer close(unblock)
+
+	err := r.Reconcile(testAssets.Ctx, getRequestName(inputRequest))
+	if ok, delay := controller.IsRequeueKey(err); !ok || delay != queuedRequeueDelay {
+		t.Fatalf("expected request to be requeued after %s, got %v", queuedRequeueDelay, err)
+	}
+	c := testAssets.Clients.ResolutionRequests.ResolutionV1alpha1()
+	reconciledRR, err := c.ResolutionRequests(inputRequest.Namespace).Get(testAssets.Ctx, inputRequest.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting updated ResolutionRequest: %v", err)
+	}
+	if msg := reconciledRR.Status.GetCondition(apis.ConditionSucceeded).GetMessage(); msg != "queued: waiting for a free resolution worker" {
+		t.Errorf("unexpected condition message %q", msg)
+	}
+}
+
+func TestReconcileResultDroppedWhenDemoted(t *testing.T) {
+	inputRequest := &v1alpha1.ResolutionRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "rr",
+			Namespace:         "foo",
+			CreationTimestamp: metav1.Time{Time: time.Now()},
+			Labels: map[string]string{
+				resolutioncommon.LabelKeyResolverType: LabelValueFakeResolverType,
+			},
+		},
+		Spec: v1alpha1.ResolutionRequestSpec{
+			Parameters: map[string]string{
+				FakeParamName: "bar",
+			},
+		},
+	}
+	d := test.Data{
+		ResolutionRequests: []*v1alpha1.ResolutionRequest{inputRequest},
+	}
+	fakeResolver := &FakeResolver{ForParam: map[string]*FakeResolvedResource{
+		"bar": {Content: "some content", WaitFor: time.Minute},
+	}}
+
+	ctx, _ := ttesting.SetupFakeContext(t)
+	testAssets, cancel := getResolverFrameworkController(ctx, t, d, fakeResolver, setClockOnReconciler)
+	defer cancel()
+	r := testAssets.Controller.Reconciler.(*Reconciler)
+	key := getRequestName(inputRequest)
+
+	if err := r.Reconcile(testAssets.Ctx, key); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	r.Demote(pkgreconciler.UniversalBucket())
+	waitForResolution(t, r, key)
+
+	c := testAssets.Clients.ResolutionRequests.ResolutionV1alpha1()
+	reconciledRR, err := c.ResolutionRequests(inputRequest.Namespace).Get(testAssets.Ctx, inputRequest.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting updated ResolutionRequest: %v", err)
+	}
+	if reconciledRR.IsDone() {
+		t.Errorf("expected a demoted replica not to complete the request, got status %v", reconciledRR.Status)
+	}
+}
+
+func TestReconcileMarksClusterShareableResults(t *testing.T) {
+	inputRequest := &v1alpha1.ResolutionRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "rr",
+			Namespace:         "foo",
+			CreationTimestamp: metav1.Time{Time: time.Now()},
+			Labels: map[string]string{
+				resolutioncommon.LabelKeyResolverType: LabelValueFakeResolverType,
+			},
+		},
+		Spec: v1alpha1.ResolutionRequestSpec{
+			Parameters: map[string]string{
+				FakeParamName: "bar",
+			},
+		},
+	}
+	d := test.Data{
+		ResolutionRequests: []*v1alpha1.ResolutionRequest{inputRequest},
+	}
+	resolver := &shareableResolver{FakeResolver: &FakeResolver{ForParam: map[string]*FakeResolvedResource{
+		"bar": {Content: "some content", AnnotationMap: map[string]string{"foo": "bar"}},
+	}}}
+
+	ctx, _ := ttesting.SetupFakeContext(t)
+	testAssets, cancel := getResolverFrameworkController(ctx, t, d, resolver, setClockOnReconciler)
+	defer cancel()
+	r := testAssets.Controller.Reconciler.(*Reconciler)
+	key := getRequestName(inputRequest)
+
+	if err := r.Reconcile(testAssets.Ctx, key); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	waitForResolution(t, r, key)
+
+	c := testAssets.Clients.ResolutionRequests.ResolutionV1alpha1()
+	reconciledRR, err := c.ResolutionRequests(inputRequest.Namespace).Get(testAssets.Ctx, inputRequest.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting updated ResolutionRequest: %v", err)
+	}
+	expected := map[string]string{
+		"foo": "bar",
+		resolutioncommon.AnnotationKeyClusterShareable: "true",
+	}
+	if d := cmp.Diff(expected, reconciledRR.Status.Annotations); d != "" {
+		t.Errorf("unexpected annotations %s", diff.PrintWantGot(d))
+	}
+}
+
+func TestReconcileRetriesRetryableErrors(t *testing.T) {
+	inputRequest := &v1alpha1.ResolutionRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "rr",
+			Namespace:         "foo",
+			CreationTimestamp: metav1.Time{Time: time.Now()},
+			Labels: map[string]string{
+				resolutioncommon.LabelKeyResolverType: LabelValueFakeResolverType,
+			},
+		},
+		Spec: v1alpha1.ResolutionRequestSpec{
+			Parameters: map[string]string{
+				FakeParamName: "bar",
+			},
+		},
+	}
+	d := test.Data{
+		ResolutionRequests: []*v1alpha1.ResolutionRequest{inputRequest},
+	}
+	resolver := &unreachableResolver{FakeResolver: &FakeResolver{}}
+
+	ctx, _ := ttesting.SetupFakeContext(t)
+	testAssets, cancel := getResolverFrameworkController(ctx, t, d, resolver, setClockOnReconciler)
+	defer cancel()
+	r := testAssets.Controller.Reconciler.(*Reconciler)
+	enqueued := make(chan time.Duration, 1)
+	r.enqueueAfter = func(_ types.NamespacedName, delay time.Duration) {
+		enqueued <- delay
+	}
+	key := getRequestName(inputRequest)
+
+	if err := r.Reconcile(testAssets.Ctx, key); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	waitForResolution(t, r, key)
+
+	select {
+	case delay := <-enqueued:
+		if delay != retryDelay {
+			t.Errorf("expected request to be retried after %s, got %s", retryDelay, delay)
+		}
+	default:
+		t.Fatalf("expected request to be enqueued to be retried")
+	}
+	c := testAssets.Clients.ResolutionRequests.ResolutionV1alpha1()
+	reconciledRR, err := c.ResolutionRequests(inputRequest.Namespace).Get(testAssets.Ctx, inputRequest.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting updated ResolutionRequest: %v", err)
+	}
+	if reconciledRR.IsDone() {
+		t.Fatalf("expected request to stay in progress, got status %v", reconciledRR.Status)
+	}
+	if msg := reconciledRR.Status.GetCondition(apis.ConditionSucceeded).GetMessage(); msg != "retrying: backend unreachable" {
+		t.Errorf("unexpected condition message %q", msg)
+	}
+}
+
+// unreachableResolver is a FakeResolver that can never reach whatever
+// it validates params against.
+type unreachableResolver struct {
+	*FakeResolver
+}
+
+func (r *unreachableResolver) ValidateParams(context.Context, map[string]string) error {
+	return NewErrorRetryable(errors.New("backend unreachable"))
+}
+
+// shareableResolver is a FakeResolver whose results are all
+// cluster-shareable.
+type shareableResolver struct {
+	*FakeResolver
+}
+
+var _ ClusterShareable = &shareableResolver{}
+
+func (r *shareableResolver) IsClusterShareable(context.Context, map[string]string) bool {
+	return true
+}
+
+// cleanupRecordingResolver is a FakeResolver that records the params
+// of requests it is asked to clean up after.
+type cleanupRecordingResolver struct {
+	*FakeResolver
+	cleanedUp chan map[string]string
+}
+
+var _ ResolutionCleaner = &cleanupRecordingResolver{}
+
+func (r *cleanupRecordingResolver) Cleanup(_ context.Context, params map[string]string) error {
+	r.cleanedUp <- params
+	return nil
+}
+
+// waitForResolution blocks until the background resolution of key, if
+// any, has finished.
+func waitForResolution(t *testing.T, r *Reconciler, key string) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for r.inflight.has(key) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for resolution of %q", key)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
 func getResolverFrameworkController(ctx context.Context, t *testing.T, d test.Data, resolver Resolver, modifiers ...ReconcilerModifier) (test.Assets, func()) {
 	t.Helper()
 	names.TestingSeed()
@@ -0,0 +1,70 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"context"
+)
+
+// ResourceChecks are the checks the framework makes on the data a
+// resolver returns before it is written to a ResolutionRequest: its
+// size limit, resource validation and content policy. They let
+// resolvers that are run in-process, without a controller, be held to
+// the same rules as a Reconciler configured with the same modifiers.
+type ResourceChecks struct {
+	r *Reconciler
+}
+
+// NewResourceChecks returns the checks that a Reconciler configured
+// with modifiers would make. Modifiers that don't affect the checks
+// are ignored.
+func NewResourceChecks(modifiers ...ReconcilerModifier) *ResourceChecks {
+	r := &Reconciler{}
+	for _, mod := range modifiers {
+		mod(r)
+	}
+	if r.MaxResolvedDataSize == 0 {
+		r.MaxResolvedDataSize = DefaultMaxResolvedDataSize
+	}
+	return &ResourceChecks{r: r}
+}
+
+// InjectMaxResolvedDataSize returns a context carrying the limit on
+// the size of resolved data, with any override from the resolver
+// config in ctx applied, for a resolver to read while it resolves.
+func (c *ResourceChecks) InjectMaxResolvedDataSize(ctx context.Context) context.Context {
+	return InjectMaxResolvedDataSize(ctx, c.r.maxResolvedDataSize(ctx))
+}
+
+// Check returns an error with the same reason a Reconciler would fail
+// a request with if the resource resolved for params breaks any of
+// the checks. ctx must have come from InjectMaxResolvedDataSize.
+func (c *ResourceChecks) Check(ctx context.Context, params map[string]string, resource ResolvedResource) error {
+	return c.r.checkResolvedResource(ctx, params, resource)
+}
+
+// checkResolvedResource runs every check on resolved data that the
+// reconciler is configured with.
+func (r *Reconciler) checkResolvedResource(ctx context.Context, params map[string]string, resource ResolvedResource) error {
+	if err := checkResolvedDataSize(ctx, resource); err != nil {
+		return err
+	}
+	if err := r.validateResource(ctx, params, resource); err != nil {
+		return err
+	}
+	return r.checkContentPolicy(ctx, resource)
+}
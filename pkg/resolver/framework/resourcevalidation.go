@@ -0,0 +1,192 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	resolutioncommon "github.com/tektoncd/resolution/pkg/common"
+	"gopkg.in/yaml.v3"
+	"knative.dev/pkg/logging"
+)
+
+const (
+	// ExpectedKindParam is the param a request can set to the kind of
+	// resource it expects, e.g. "Task". Kinds are compared ignoring
+	// case.
+	ExpectedKindParam = "expectedKind"
+
+	// ExpectedAPIVersionParam is the param a request can set to the
+	// apiVersion it expects, e.g. "tekton.dev/v1beta1".
+	ExpectedAPIVersionParam = "expectedAPIVersion"
+
+	// MultipleDocumentsParam is the param a request sets to "true" to
+	// accept resolved data holding more than one YAML document.
+	MultipleDocumentsParam = "allowMultipleDocuments"
+
+	// ConfigValidateResources is the resolver config field that turns
+	// resource validation on, when set to "true", or off, when set to
+	// "false", whether or not WithResourceValidation was passed.
+	ConfigValidateResources = "validate-resources"
+)
+
+// WithResourceValidation returns a ReconcilerModifier that checks the
+// data returned by the resolver is YAML describing a Tekton resource
+// before it's written to the request. Requests can narrow down what
+// they accept with the ExpectedKindParam, ExpectedAPIVersionParam and
+// MultipleDocumentsParam params. Data that doesn't pass fails the
+// request with the InvalidResource reason and an error giving the line
+// of the problem. The resolver's config can override it with
+// ConfigValidateResources.
+func WithResourceValidation() ReconcilerModifier {
+	return func(r *Reconciler) {
+		r.validateResources = true
+	}
+}
+
+// validateResources returns whether resolved data is validated, which
+// is defaultValue unless the resolver's config overrides it. Values
+// that cannot be parsed are returned as an error and otherwise
+// ignored.
+func validateResources(defaultValue bool, conf map[string]string) (bool, error) {
+	val, ok := conf[ConfigValidateResources]
+	if !ok {
+		return defaultValue, nil
+	}
+	validate, err := strconv.ParseBool(val)
+	if err != nil {
+		return defaultValue, fmt.Errorf("invalid value %q for %s", val, ConfigValidateResources)
+	}
+	return validate, nil
+}
+
+// validateResource returns an error if the reconciler validates
+// resources and the resolved data isn't what the request expects.
+func (r *Reconciler) validateResource(ctx context.Context, params map[string]string, resource ResolvedResource) error {
+	validate, err := validateResources(r.validateResources, GetResolverConfigFromContext(ctx))
+	if err != nil {
+		logging.FromContext(ctx).Warnf("ignoring invalid resolver config: %v", err)
+	}
+	if !validate {
+		return nil
+	}
+	expected := expectedResource{
+		kind:              params[ExpectedKindParam],
+		apiVersion:        params[ExpectedAPIVersionParam],
+		multipleDocuments: params[MultipleDocumentsParam] == "true",
+	}
+	if err := expected.validate(resource.Data()); err != nil {
+		return resolutioncommon.NewError(resolutioncommon.ReasonInvalidResource, fmt.Errorf("resolved data is not a valid Tekton resource: %w", err))
+	}
+	return nil
+}
+
+// expectedResource describes the resolved data a request accepts.
+type expectedResource struct {
+	kind              string
+	apiVersion        string
+	multipleDocuments bool
+}
+
+// validate checks every document in data.
+func (e expectedResource) validate(data []byte) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	documents := 0
+	for {
+		var document yaml.Node
+		err := decoder.Decode(&document)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if len(document.Content) == 0 {
+			continue
+		}
+		root := document.Content[0]
+		if root.Kind == yaml.ScalarNode && root.Tag == "!!null" {
+			// An empty document, e.g. after a trailing "---".
+			continue
+		}
+		documents++
+		if documents > 1 && !e.multipleDocuments {
+			return fmt.Errorf("line %d: found a second document but the %s param isn't \"true\"", root.Line, MultipleDocumentsParam)
+		}
+		if err := e.validateDocument(root); err != nil {
+			return err
+		}
+	}
+	if documents == 0 {
+		return errors.New("no resource found")
+	}
+	return nil
+}
+
+// validateDocument checks that root describes a Tekton resource of the
+// expected kind and apiVersion.
+func (e expectedResource) validateDocument(root *yaml.Node) error {
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: expected a mapping describing a resource", root.Line)
+	}
+
+	apiVersion, err := scalarField(root, "apiVersion")
+	if err != nil {
+		return err
+	}
+	group := ""
+	if i := strings.Index(apiVersion.Value, "/"); i >= 0 {
+		group = apiVersion.Value[:i]
+	}
+	if group != "tekton.dev" && !strings.HasSuffix(group, ".tekton.dev") {
+		return fmt.Errorf("line %d: apiVersion %q is not a Tekton API", apiVersion.Line, apiVersion.Value)
+	}
+	if e.apiVersion != "" && apiVersion.Value != e.apiVersion {
+		return fmt.Errorf("line %d: expected apiVersion %q but found %q", apiVersion.Line, e.apiVersion, apiVersion.Value)
+	}
+
+	kind, err := scalarField(root, "kind")
+	if err != nil {
+		return err
+	}
+	if e.kind != "" && !strings.EqualFold(kind.Value, e.kind) {
+		return fmt.Errorf("line %d: expected kind %q but found %q", kind.Line, e.kind, kind.Value)
+	}
+	return nil
+}
+
+// scalarField returns the value of a non-empty string field of a
+// mapping.
+func scalarField(mapping *yaml.Node, name string) (*yaml.Node, error) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value != name {
+			continue
+		}
+		value := mapping.Content[i+1]
+		if value.Kind != yaml.ScalarNode || value.Value == "" {
+			return nil, fmt.Errorf("line %d: %s must be a non-empty string", value.Line, name)
+		}
+		return value, nil
+	}
+	return nil, fmt.Errorf("line %d: missing %s", mapping.Line, name)
+}
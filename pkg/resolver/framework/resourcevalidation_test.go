@@ -0,0 +1,209 @@
+/*
+ Copyright 2022 The Tekton Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package framework
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tektoncd/resolution/pkg/apis/resolution/v1alpha1"
+	resolutioncommon "github.com/tektoncd/resolution/pkg/common"
+	ttesting "github.com/tektoncd/resolution/pkg/reconciler/testing"
+	"github.com/tektoncd/resolution/test"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
+)
+
+const validTask = `apiVersion: tekton.dev/v1beta1
+kind: Task
+metadata:
+  name: foo
+`
+
+func TestValidateResource(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		expected    expectedResource
+		data        string
+		expectedErr string
+	}{{
+		name: "task",
+		data: validTask,
+	}, {
+		name:     "expected kind ignoring case",
+		expected: expectedResource{kind: "task", apiVersion: "tekton.dev/v1beta1"},
+		data:     validTask,
+	}, {
+		name: "other tekton api group",
+		data: "apiVersion: triggers.tekton.dev/v1beta1\nkind: TriggerTemplate\n",
+	}, {
+		name: "leading and trailing separators",
+		data: "---\n" + validTask + "---\n",
+	}, {
+		name:     "multiple documents allowed",
+		expected: expectedResource{kind: "Task", multipleDocuments: true},
+		data:     validTask + "---\n" + validTask,
+	}, {
+		name:        "multiple documents",
+		data:        validTask + "---\n" + validTask,
+		expectedErr: `line 6: found a second document but the allowMultipleDocuments param isn't "true"`,
+	}, {
+		name:        "wrong kind in later document",
+		expected:    expectedResource{kind: "Task", multipleDocuments: true},
+		data:        validTask + "---\napiVersion: tekton.dev/v1beta1\nkind: Pipeline\n",
+		expectedErr: `line 7: expected kind "Task" but found "Pipeline"`,
+	}, {
+		name:        "wrong api version",
+		expected:    expectedResource{apiVersion: "tekton.dev/v1"},
+		data:        validTask,
+		expectedErr: `line 1: expected apiVersion "tekton.dev/v1" but found "tekton.dev/v1beta1"`,
+	}, {
+		name:        "not tekton",
+		data:        "apiVersion: v1\nkind: ConfigMap\n",
+		expectedErr: `line 1: apiVersion "v1" is not a Tekton API`,
+	}, {
+		name:        "missing kind",
+		data:        "apiVersion: tekton.dev/v1beta1\nmetadata:\n  name: foo\n",
+		expectedErr: "line 1: missing kind",
+	}, {
+		name:        "kind not a string",
+		data:        "apiVersion: tekton.dev/v1beta1\nkind:\n  name: Task\n",
+		expectedErr: "line 3: kind must be a non-empty string",
+	}, {
+		name:        "readme",
+		data:        "# My Task\n\nSome docs.\n",
+		expectedErr: "line 3: expected a mapping describing a resource",
+	}, {
+		name:        "broken yaml",
+		data:        "apiVersion: tekton.dev/v1beta1\nkind: Task\nspec:\n  steps:\n  - image: foo\n   script: bar\n",
+		expectedErr: "yaml: line 3: did not find expected key",
+	}, {
+		name:        "tab indentation",
+		data:        "apiVersion: tekton.dev/v1beta1\nkind: Task\nspec:\n\tsteps: []\n",
+		expectedErr: "yaml: line 4: found character that cannot start any token",
+	}, {
+		name:        "empty",
+		data:        "",
+		expectedErr: "no resource found",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.expected.validate([]byte(tc.data))
+			if tc.expectedErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tc.expectedErr {
+				t.Fatalf("expected error %q, got %v", tc.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateResourcesFromConfig(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		defaultValue bool
+		conf         map[string]string
+		expected     bool
+		expectedErr  bool
+	}{{
+		name: "default",
+	}, {
+		name:         "enabled by modifier",
+		defaultValue: true,
+		expected:     true,
+	}, {
+		name:     "enabled by config",
+		conf:     map[string]string{ConfigValidateResources: "true"},
+		expected: true,
+	}, {
+		name:         "disabled by config",
+		defaultValue: true,
+		conf:         map[string]string{ConfigValidateResources: "false"},
+	}, {
+		name:         "invalid",
+		defaultValue: true,
+		conf:         map[string]string{ConfigValidateResources: "yes please"},
+		expected:     true,
+		expectedErr:  true,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			validate, err := validateResources(tc.defaultValue, tc.conf)
+			if (err != nil) != tc.expectedErr {
+				t.Fatalf("expected error %t, got %v", tc.expectedErr, err)
+			}
+			if validate != tc.expected {
+				t.Errorf("expected %t, got %t", tc.expected, validate)
+			}
+		})
+	}
+}
+
+func TestReconcileValidatesResource(t *testing.T) {
+	inputRequest := &v1alpha1.ResolutionRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "rr",
+			Namespace:         "foo",
+			CreationTimestamp: metav1.Time{Time: time.Now()},
+			Labels: map[string]string{
+				resolutioncommon.LabelKeyResolverType: LabelValueFakeResolverType,
+			},
+		},
+		Spec: v1alpha1.ResolutionRequestSpec{
+			Parameters: map[string]string{
+				FakeParamName:     "bar",
+				ExpectedKindParam: "Pipeline",
+			},
+		},
+	}
+	d := test.Data{
+		ResolutionRequests: []*v1alpha1.ResolutionRequest{inputRequest},
+	}
+	resolver := &FakeResolver{ForParam: map[string]*FakeResolvedResource{
+		"bar": {Content: validTask},
+	}}
+
+	ctx, _ := ttesting.SetupFakeContext(t)
+	testAssets, cancel := getResolverFrameworkController(ctx, t, d, resolver, setClockOnReconciler, WithResourceValidation())
+	defer cancel()
+	r := testAssets.Controller.Reconciler.(*Reconciler)
+	key := getRequestName(inputRequest)
+
+	if err := r.Reconcile(testAssets.Ctx, key); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	waitForResolution(t, r, key)
+
+	c := testAssets.Clients.ResolutionRequests.ResolutionV1alpha1()
+	reconciledRR, err := c.ResolutionRequests(inputRequest.Namespace).Get(testAssets.Ctx, inputRequest.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting updated ResolutionRequest: %v", err)
+	}
+	condition := reconciledRR.Status.GetCondition(apis.ConditionSucceeded)
+	if condition == nil || !condition.IsFalse() {
+		t.Fatalf("expected request to fail, got condition %v", condition)
+	}
+	if condition.Reason != resolutioncommon.ReasonInvalidResource {
+		t.Errorf("expected reason %q, got %q", resolutioncommon.ReasonInvalidResource, condition.Reason)
+	}
+	expectedMsg := `resolved data is not a valid Tekton resource: line 2: expected kind "Pipeline" but found "Task"`
+	if condition.Message != expectedMsg {
+		t.Errorf("expected message %q, got %q", expectedMsg, condition.Message)
+	}
+}
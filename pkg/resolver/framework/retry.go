@@ -0,0 +1,73 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tektoncd/resolution/pkg/apis/resolution/v1alpha1"
+	"k8s.io/apimachinery/pkg/types"
+	"knative.dev/pkg/logging"
+)
+
+// retryDelay is how long the framework waits before trying a request
+// again after a resolver reports a retryable error.
+const retryDelay = 5 * time.Second
+
+// retryableError marks an error as one that may go away if the
+// request is tried again.
+type retryableError struct {
+	err error
+}
+
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryableError) Unwrap() error {
+	return e.err
+}
+
+// NewErrorRetryable marks err, returned from ValidateParams or
+// Resolve, as temporary, e.g. because something the resolver depends
+// on couldn't be reached. Rather than failing the request the
+// framework leaves it in progress and tries it again shortly, until
+// it succeeds, fails with another error or times out.
+func NewErrorRetryable(err error) error {
+	return &retryableError{err: err}
+}
+
+// IsErrorRetryable returns true if err was marked by NewErrorRetryable.
+func IsErrorRetryable(err error) bool {
+	var retryable *retryableError
+	return errors.As(err, &retryable)
+}
+
+// retryLater reports that a request hit a retryable error and
+// enqueues it to be resolved again after retryDelay.
+func (r *Reconciler) retryLater(ctx context.Context, rr *v1alpha1.ResolutionRequest, err error) error {
+	if err := r.MarkInProgress(ctx, rr, fmt.Sprintf("retrying: %v", err)); err != nil {
+		logging.FromContext(ctx).Warnf("error reporting retry of %s/%s: %v", rr.Namespace, rr.Name, err)
+	}
+	if r.enqueueAfter != nil {
+		r.enqueueAfter(types.NamespacedName{Namespace: rr.Namespace, Name: rr.Name}, retryDelay)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+
+	resolutioncommon "github.com/tektoncd/resolution/pkg/common"
+)
+
+// ConfigMaxResolvedDataSize is the resolver config field that limits
+// the size in bytes of the data a resolver may return. A value of 0
+// removes the limit.
+const ConfigMaxResolvedDataSize = "max-resolved-data-size"
+
+// DefaultMaxResolvedDataSize is the limit on the size of resolved data
+// used when neither the reconciler nor the resolver's config set one.
+// Resolved data is stored base64 encoded in the ResolutionRequest, so
+// this keeps requests well within etcd's limit on the size of objects.
+const DefaultMaxResolvedDataSize int64 = 1024 * 1024
+
+type maxResolvedDataSizeKey struct{}
+
+// maxResolvedDataSize returns the reconciler's default limit on the
+// size of resolved data with any override from the resolver's config
+// applied. Values that cannot be parsed are returned as an error and
+// otherwise ignored.
+func maxResolvedDataSize(defaultSize int64, conf map[string]string) (int64, error) {
+	val, ok := conf[ConfigMaxResolvedDataSize]
+	if !ok {
+		return defaultSize, nil
+	}
+	size, err := strconv.ParseInt(val, 10, 64)
+	if err != nil || size < 0 {
+		return defaultSize, fmt.Errorf("invalid value %q for %s", val, ConfigMaxResolvedDataSize)
+	}
+	return size, nil
+}
+
+// InjectMaxResolvedDataSize returns a new context with the limit on the
+// size of resolved data stored in it.
+func InjectMaxResolvedDataSize(ctx context.Context, size int64) context.Context {
+	return context.WithValue(ctx, maxResolvedDataSizeKey{}, size)
+}
+
+// MaxResolvedDataSize returns the limit on the size in bytes of the
+// data a resolver may return for the request being resolved with ctx.
+// Resolvers can use it to stop reading data that will be rejected
+// anyway. A value of 0 means there is no limit.
+func MaxResolvedDataSize(ctx context.Context) int64 {
+	if size, ok := ctx.Value(maxResolvedDataSizeKey{}).(int64); ok {
+		return size
+	}
+	return 0
+}
+
+// ReadAllWithLimit reads from reader until EOF, returning an error with
+// the ResolvedDataTooLarge reason as soon as more than limit bytes have
+// been read. A limit of 0 reads everything.
+func ReadAllWithLimit(reader io.Reader, limit int64) ([]byte, error) {
+	if limit <= 0 {
+		return io.ReadAll(reader)
+	}
+	data, err := io.ReadAll(io.LimitReader(reader, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, NewErrorResolvedDataTooLarge(limit)
+	}
+	return data, nil
+}
+
+// NewErrorResolvedDataTooLarge returns an error with the
+// ResolvedDataTooLarge reason for data larger than limit.
+func NewErrorResolvedDataTooLarge(limit int64) error {
+	return resolutioncommon.NewError(resolutioncommon.ReasonResolvedDataTooLarge, fmt.Errorf("resolved data is larger than the limit of %d bytes", limit))
+}
+
+// checkResolvedDataSize returns an error if resource is larger than
+// the limit stored in ctx.
+func checkResolvedDataSize(ctx context.Context, resource ResolvedResource) error {
+	limit := MaxResolvedDataSize(ctx)
+	if size := int64(len(resource.Data())); limit > 0 && size > limit {
+		return resolutioncommon.NewError(resolutioncommon.ReasonResolvedDataTooLarge, fmt.Errorf("resolved data is %d bytes, larger than the limit of %d bytes", size, limit))
+	}
+	return nil
+}
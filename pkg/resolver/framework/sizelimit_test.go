@@ -0,0 +1,153 @@
+/*
+ Copyright 2022 The Tekton Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package framework
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tektoncd/resolution/pkg/apis/resolution/v1alpha1"
+	resolutioncommon "github.com/tektoncd/resolution/pkg/common"
+	ttesting "github.com/tektoncd/resolution/pkg/reconciler/testing"
+	"github.com/tektoncd/resolution/test"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
+)
+
+func TestMaxResolvedDataSizeFromConfig(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		conf        map[string]string
+		expected    int64
+		expectedErr bool
+	}{{
+		name:     "default",
+		expected: 100,
+	}, {
+		name:     "override",
+		conf:     map[string]string{ConfigMaxResolvedDataSize: "2048"},
+		expected: 2048,
+	}, {
+		name:     "unlimited",
+		conf:     map[string]string{ConfigMaxResolvedDataSize: "0"},
+		expected: 0,
+	}, {
+		name:        "negative",
+		conf:        map[string]string{ConfigMaxResolvedDataSize: "-1"},
+		expected:    100,
+		expectedErr: true,
+	}, {
+		name:        "not a number",
+		conf:        map[string]string{ConfigMaxResolvedDataSize: "1Mi"},
+		expected:    100,
+		expectedErr: true,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			size, err := maxResolvedDataSize(100, tc.conf)
+			if (err != nil) != tc.expectedErr {
+				t.Fatalf("expected error %t, got %v", tc.expectedErr, err)
+			}
+			if size != tc.expected {
+				t.Errorf("expected size %d, got %d", tc.expected, size)
+			}
+		})
+	}
+}
+
+func TestReadAllWithLimit(t *testing.T) {
+	for _, tc := range []struct {
+		data     string
+		limit    int64
+		tooLarge bool
+	}{
+		{data: "12345", limit: 5},
+		{data: "123456", limit: 5, tooLarge: true},
+		{data: "123456", limit: 0},
+	} {
+		data, err := ReadAllWithLimit(strings.NewReader(tc.data), tc.limit)
+		if tc.tooLarge {
+			if reason, _ := resolutioncommon.ReasonError(err); reason != resolutioncommon.ReasonResolvedDataTooLarge {
+				t.Errorf("expected %q with limit %d to be too large, got %v", tc.data, tc.limit, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error reading %q with limit %d: %v", tc.data, tc.limit, err)
+		}
+		if string(data) != tc.data {
+			t.Errorf("expected %q, got %q", tc.data, data)
+		}
+	}
+}
+
+func TestReconcileRejectsLargeResolvedData(t *testing.T) {
+	inputRequest := &v1alpha1.ResolutionRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "rr",
+			Namespace:         "foo",
+			CreationTimestamp: metav1.Time{Time: time.Now()},
+			Labels: map[string]string{
+				resolutioncommon.LabelKeyResolverType: LabelValueFakeResolverType,
+			},
+		},
+		Spec: v1alpha1.ResolutionRequestSpec{
+			Parameters: map[string]string{
+				FakeParamName: "bar",
+			},
+		},
+	}
+	d := test.Data{
+		ResolutionRequests: []*v1alpha1.ResolutionRequest{inputRequest},
+	}
+	resolver := &FakeResolver{ForParam: map[string]*FakeResolvedResource{
+		"bar": {Content: "some content"},
+	}}
+
+	ctx, _ := ttesting.SetupFakeContext(t)
+	testAssets, cancel := getResolverFrameworkController(ctx, t, d, resolver, setClockOnReconciler, func(r *Reconciler) {
+		r.MaxResolvedDataSize = 4
+	})
+	defer cancel()
+	r := testAssets.Controller.Reconciler.(*Reconciler)
+	key := getRequestName(inputRequest)
+
+	if err := r.Reconcile(testAssets.Ctx, key); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	waitForResolution(t, r, key)
+
+	c := testAssets.Clients.ResolutionRequests.ResolutionV1alpha1()
+	reconciledRR, err := c.ResolutionRequests(inputRequest.Namespace).Get(testAssets.Ctx, inputRequest.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting updated ResolutionRequest: %v", err)
+	}
+	if reconciledRR.Status.Data != "" {
+		t.Errorf("expected no data to be written, got %q", reconciledRR.Status.Data)
+	}
+	condition := reconciledRR.Status.GetCondition(apis.ConditionSucceeded)
+	if condition == nil || !condition.IsFalse() {
+		t.Fatalf("expected request to fail, got condition %v", condition)
+	}
+	if condition.Reason != resolutioncommon.ReasonResolvedDataTooLarge {
+		t.Errorf("expected reason %q, got %q", resolutioncommon.ReasonResolvedDataTooLarge, condition.Reason)
+	}
+	expectedMsg := "resolved data is 12 bytes, larger than the limit of 4 bytes"
+	if condition.Message != expectedMsg {
+		t.Errorf("expected message %q, got %q", expectedMsg, condition.Message)
+	}
+}
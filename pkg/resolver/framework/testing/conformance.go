@@ -0,0 +1,238 @@
+/*
+ Copyright 2022 The Tekton Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package testing
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	resolutioncommon "github.com/tektoncd/resolution/pkg/common"
+	"github.com/tektoncd/resolution/pkg/resolver/framework"
+	"github.com/tektoncd/resolution/test/diff"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// conformanceCancelGrace is how long a resolver is given to return
+// once its context is done.
+const conformanceCancelGrace = 5 * time.Second
+
+// conformanceNamespace is the namespace that requests made by the
+// conformance tests appear to come from if the spec doesn't give one.
+const conformanceNamespace = "default"
+
+// ConformanceSpec describes a resolver to run through the conformance
+// tests and the params to exercise it with.
+type ConformanceSpec struct {
+	// Resolver is the resolver under test. Its Initialize method is
+	// called with the context given to RunConformanceTests.
+	Resolver framework.Resolver
+
+	// Config is passed to the resolver as though it came from its
+	// ConfigMap.
+	Config map[string]string
+
+	// Namespace is the namespace requests appear to come from.
+	// Defaults to "default".
+	Namespace string
+
+	// ValidParams are params that the resolver resolves
+	// successfully. The tests that need a successful resolution are
+	// skipped if they aren't given.
+	ValidParams map[string]string
+
+	// RequiredParams are the names of params that the resolver must
+	// reject requests without. Each is removed in turn from
+	// ValidParams, or BlockingParams if there are no ValidParams.
+	RequiredParams []string
+
+	// BlockingParams are valid params whose resolution doesn't
+	// finish until its context is done, e.g. because they point at
+	// a server that never responds. The tests of timeouts and
+	// cancellation are skipped if they aren't given.
+	BlockingParams map[string]string
+}
+
+// RunConformanceTests checks that a resolver behaves the way the
+// framework expects every resolver to:
+//
+//   - it has a name and its selector includes a type label,
+//   - it accepts valid params and rejects ones that are missing
+//     required params,
+//   - it resolves valid params to the same data and annotations every
+//     time without modifying the params it is given,
+//   - its annotations have valid keys, and
+//   - it gives up promptly once its context times out or is cancelled.
+func RunConformanceTests(ctx context.Context, t *testing.T, spec ConformanceSpec) {
+	t.Helper()
+	resolver := spec.Resolver
+	if err := resolver.Initialize(ctx); err != nil {
+		t.Fatalf("error initializing resolver: %v", err)
+	}
+	namespace := spec.Namespace
+	if namespace == "" {
+		namespace = conformanceNamespace
+	}
+	ctx = resolutioncommon.InjectRequestNamespace(ctx, namespace)
+	ctx = framework.InjectResolverConfigToContext(ctx, spec.Config)
+
+	t.Run("has a name", func(t *testing.T) {
+		if resolver.GetName(ctx) == "" {
+			t.Errorf("expected resolver to have a name")
+		}
+	})
+
+	t.Run("selector includes type label", func(t *testing.T) {
+		if resolver.GetSelector(ctx)[resolutioncommon.LabelKeyResolverType] == "" {
+			t.Errorf("expected selector to include %q, got %v", resolutioncommon.LabelKeyResolverType, resolver.GetSelector(ctx))
+		}
+	})
+
+	if watcher, ok := resolver.(framework.ConfigWatcher); ok {
+		t.Run("has a config name", func(t *testing.T) {
+			if watcher.GetConfigName(ctx) == "" {
+				t.Errorf("expected resolver that watches config to name its ConfigMap")
+			}
+		})
+	}
+
+	if timed, ok := resolver.(framework.TimedResolution); ok {
+		t.Run("resolution timeout is positive", func(t *testing.T) {
+			if timeout := timed.GetResolutionTimeout(ctx, time.Minute); timeout <= 0 {
+				t.Errorf("expected a positive resolution timeout, got %s", timeout)
+			}
+		})
+	}
+
+	t.Run("accepts valid params", func(t *testing.T) {
+		for _, params := range []map[string]string{spec.ValidParams, spec.BlockingParams} {
+			if params == nil {
+				continue
+			}
+			if err := resolver.ValidateParams(ctx, copyParams(params)); err != nil {
+				t.Errorf("unexpected error validating params %v: %v", params, err)
+			}
+		}
+	})
+
+	t.Run("rejects missing required params", func(t *testing.T) {
+		valid := spec.ValidParams
+		if valid == nil {
+			valid = spec.BlockingParams
+		}
+		for _, name := range spec.RequiredParams {
+			params := copyParams(valid)
+			delete(params, name)
+			if err := resolver.ValidateParams(ctx, params); err == nil {
+				t.Errorf("expected error validating params without %q", name)
+			}
+		}
+	})
+
+	t.Run("resolves deterministically", func(t *testing.T) {
+		if spec.ValidParams == nil {
+			t.Skip("no valid params given")
+		}
+		first := resolveForConformance(ctx, t, resolver, spec.ValidParams)
+		second := resolveForConformance(ctx, t, resolver, spec.ValidParams)
+		if !bytes.Equal(first.Data(), second.Data()) {
+			t.Errorf("expected the same data for the same params, got %q then %q", first.Data(), second.Data())
+		}
+		if d := cmp.Diff(first.Annotations(), second.Annotations()); d != "" {
+			t.Errorf("expected the same annotations for the same params %s", diff.PrintWantGot(d))
+		}
+	})
+
+	t.Run("annotations are well formed", func(t *testing.T) {
+		if spec.ValidParams == nil {
+			t.Skip("no valid params given")
+		}
+		resolved := resolveForConformance(ctx, t, resolver, spec.ValidParams)
+		for key := range resolved.Annotations() {
+			if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+				t.Errorf("invalid annotation key %q: %v", key, errs)
+			}
+		}
+	})
+
+	t.Run("respects timeout", func(t *testing.T) {
+		if spec.BlockingParams == nil {
+			t.Skip("no blocking params given")
+		}
+		ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer cancel()
+		checkGivesUp(ctx, t, resolver, spec.BlockingParams)
+	})
+
+	t.Run("honours cancellation", func(t *testing.T) {
+		if spec.BlockingParams == nil {
+			t.Skip("no blocking params given")
+		}
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		time.AfterFunc(100*time.Millisecond, cancel)
+		checkGivesUp(ctx, t, resolver, spec.BlockingParams)
+	})
+}
+
+// resolveForConformance resolves a copy of params, failing the test if
+// the resolver returns an error or modifies the params.
+func resolveForConformance(ctx context.Context, t *testing.T, resolver framework.Resolver, params map[string]string) framework.ResolvedResource {
+	t.Helper()
+	given := copyParams(params)
+	resolved, err := resolver.Resolve(ctx, given)
+	if err != nil {
+		t.Fatalf("unexpected error resolving params %v: %v", params, err)
+	}
+	if d := cmp.Diff(params, given); d != "" {
+		t.Errorf("expected resolver not to modify params %s", diff.PrintWantGot(d))
+	}
+	return resolved
+}
+
+// checkGivesUp resolves params, which block until ctx is done, and
+// checks that the resolver returns an error soon after.
+func checkGivesUp(ctx context.Context, t *testing.T, resolver framework.Resolver, params map[string]string) {
+	t.Helper()
+	errs := make(chan error, 1)
+	go func() {
+		_, err := resolver.Resolve(ctx, copyParams(params))
+		errs <- err
+	}()
+	<-ctx.Done()
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Errorf("expected an error once the context was done")
+		}
+	case <-time.After(conformanceCancelGrace):
+		t.Errorf("resolver still running %s after its context was done", conformanceCancelGrace)
+	}
+}
+
+func copyParams(params map[string]string) map[string]string {
+	if params == nil {
+		return nil
+	}
+	copied := make(map[string]string, len(params))
+	for key, val := range params {
+		copied[key] = val
+	}
+	return copied
+}
@@ -0,0 +1,44 @@
+/*
+ Copyright 2022 The Tekton Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package testing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	resolutioncommon "github.com/tektoncd/resolution/pkg/common"
+	"github.com/tektoncd/resolution/pkg/resolver/framework"
+)
+
+func TestConformanceOfFakeResolver(t *testing.T) {
+	RunConformanceTests(context.Background(), t, ConformanceSpec{
+		Resolver: &framework.FakeResolver{ForParam: map[string]*framework.FakeResolvedResource{
+			"bar": {
+				Content:       "some content",
+				AnnotationMap: map[string]string{resolutioncommon.AnnotationKeyContentType: "application/x-yaml"},
+			},
+			"slow": {
+				Content: "too late",
+				WaitFor: time.Minute,
+			},
+		}},
+		ValidParams:    map[string]string{framework.FakeParamName: "bar"},
+		RequiredParams: []string{framework.FakeParamName},
+		BlockingParams: map[string]string{framework.FakeParamName: "slow"},
+	})
+}
@@ -32,6 +32,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/tools/record"
 	"knative.dev/pkg/apis"
 	cminformer "knative.dev/pkg/configmap/informer"
@@ -47,9 +48,14 @@ var (
 	ignoreLastTransitionTime = cmpopts.IgnoreFields(apis.Condition{}, "LastTransitionTime.Inner.Time")
 )
 
+// resolutionWaitTimeout is how long RunResolverReconcileTest waits for
+// a request to be resolved.
+const resolutionWaitTimeout = 30 * time.Second
+
 // RunResolverReconcileTest takes data to seed clients and informers, a Resolver, a ResolutionRequest, and the expected
 // ResolutionRequestStatus and error, both of which can be nil. It instantiates a controller for that resolver and
-// reconciles the given request. It then checks for the expected error, if any, and compares the resulting status with
+// reconciles the given request. Since resolution happens in the background it then waits for the request to either
+// receive data or fail, checks that any failure matches the expected error, and compares the resulting status with
 // the expected status.
 func RunResolverReconcileTest(ctx context.Context, t *testing.T, d test.Data, resolver framework.Resolver, request *v1alpha1.ResolutionRequest,
 	expectedStatus *v1alpha1.ResolutionRequestStatus, expectedErr error) {
@@ -59,24 +65,25 @@ func RunResolverReconcileTest(ctx context.Context, t *testing.T, d test.Data, re
 	defer cancel()
 
 	err := testAssets.Controller.Reconciler.Reconcile(testAssets.Ctx, getRequestName(request))
-	if expectedErr != nil {
-		if err == nil {
-			t.Fatalf("expected to get error %v, but got nothing", expectedErr)
-		}
-		if expectedErr.Error() != err.Error() {
-			t.Fatalf("expected to get error %v, but got %v", expectedErr, err)
-		}
-	} else if err != nil {
+	if err != nil {
 		if ok, _ := controller.IsRequeueKey(err); !ok {
 			t.Fatalf("did not expect an error, but got %v", err)
 		}
 	}
 
-	c := testAssets.Clients.ResolutionRequests.ResolutionV1alpha1()
-	reconciledRR, err := c.ResolutionRequests(request.Namespace).Get(testAssets.Ctx, request.Name, metav1.GetOptions{})
-	if err != nil {
-		t.Fatalf("getting updated ResolutionRequest: %v", err)
+	reconciledRR := waitForResolution(testAssets.Ctx, t, testAssets, request)
+	condition := reconciledRR.Status.GetCondition(apis.ConditionSucceeded)
+	if expectedErr != nil {
+		if condition == nil || !condition.IsFalse() {
+			t.Fatalf("expected to get error %v, but got nothing", expectedErr)
+		}
+		if expectedErr.Error() != condition.Message {
+			t.Fatalf("expected to get error %v, but got %v", expectedErr, condition.Message)
+		}
+	} else if condition != nil && condition.IsFalse() {
+		t.Fatalf("did not expect an error, but got %v", condition.Message)
 	}
+
 	if expectedStatus != nil {
 		if d := cmp.Diff(*expectedStatus, reconciledRR.Status, ignoreLastTransitionTime); d != "" {
 			t.Errorf("ResolutionRequest status doesn't match %s", diff.PrintWantGot(d))
@@ -84,6 +91,30 @@ func RunResolverReconcileTest(ctx context.Context, t *testing.T, d test.Data, re
 	}
 }
 
+// waitForResolution polls the given request until the resolver has
+// either written data to it or marked it as failed.
+func waitForResolution(ctx context.Context, t *testing.T, testAssets test.Assets, request *v1alpha1.ResolutionRequest) *v1alpha1.ResolutionRequest {
+	t.Helper()
+	c := testAssets.Clients.ResolutionRequests.ResolutionV1alpha1()
+	var reconciledRR *v1alpha1.ResolutionRequest
+	err := wait.PollImmediate(10*time.Millisecond, resolutionWaitTimeout, func() (bool, error) {
+		var err error
+		reconciledRR, err = c.ResolutionRequests(request.Namespace).Get(ctx, request.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		if reconciledRR.Status.Data != "" {
+			return true, nil
+		}
+		condition := reconciledRR.Status.GetCondition(apis.ConditionSucceeded)
+		return condition != nil && condition.IsFalse(), nil
+	})
+	if err != nil {
+		t.Fatalf("waiting for ResolutionRequest to be resolved: %v", err)
+	}
+	return reconciledRR
+}
+
 // GetResolverFrameworkController returns an instance of the resolver framework controller/reconciler using the given resolver,
 // seeded with d, where d represents the state of the system (existing resources) needed for the test.
 func GetResolverFrameworkController(ctx context.Context, t *testing.T, d test.Data, resolver framework.Resolver, modifiers ...framework.ReconcilerModifier) (test.Assets, func()) {
@@ -0,0 +1,277 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// ConfigMaxConcurrentResolutions is the resolver config field
+	// that limits the number of requests a resolver will work on at
+	// the same time.
+	ConfigMaxConcurrentResolutions = "max-concurrent-resolutions"
+
+	// ConfigMaxConcurrentResolutionsPerNamespace is the resolver
+	// config field that limits the number of requests from a single
+	// namespace that a resolver will work on at the same time.
+	ConfigMaxConcurrentResolutionsPerNamespace = "max-concurrent-resolutions-per-namespace"
+
+	// ConfigNamespaceResolutionRate is the resolver config field
+	// that limits the number of requests per second that a resolver
+	// will start working on for a single namespace.
+	ConfigNamespaceResolutionRate = "namespace-resolution-rate"
+
+	// ConfigNamespaceResolutionBurst is the resolver config field
+	// that sets how many requests from a single namespace may be
+	// started in a burst above ConfigNamespaceResolutionRate.
+	ConfigNamespaceResolutionBurst = "namespace-resolution-burst"
+)
+
+// queuedRequeueDelay is how long a throttled request waits before
+// the framework tries to start it again.
+const queuedRequeueDelay = 2 * time.Second
+
+// waitingExpiry is how long a throttled request is considered to still
+// be waiting for a slot after it was last seen. Requests that are
+// deleted or picked up elsewhere stop being seen and so stop holding
+// back other namespaces once this expires.
+const waitingExpiry = 3 * queuedRequeueDelay
+
+// ConcurrencyLimits controls how many requests a resolver works on at
+// once and how that work is shared between namespaces. A zero value
+// for any field means that dimension is unlimited.
+type ConcurrencyLimits struct {
+	// MaxConcurrent is the maximum number of requests the resolver
+	// will work on at the same time.
+	MaxConcurrent int
+
+	// MaxConcurrentPerNamespace is the maximum number of requests
+	// from a single namespace that the resolver will work on at the
+	// same time.
+	MaxConcurrentPerNamespace int
+
+	// NamespaceRate is the number of requests per second from a
+	// single namespace that the resolver will start working on.
+	NamespaceRate float64
+
+	// NamespaceBurst is the number of requests from a single
+	// namespace that may be started at once above NamespaceRate.
+	// Defaults to 1 when NamespaceRate is set.
+	NamespaceBurst int
+}
+
+// ConcurrencyLimitsFromConfig returns a copy of defaults with any
+// limits set in a resolver's config applied over the top of it.
+// Values that cannot be parsed are returned as an error and
+// otherwise ignored.
+func ConcurrencyLimitsFromConfig(defaults ConcurrencyLimits, conf map[string]string) (ConcurrencyLimits, error) {
+	limits := defaults
+	var errs []error
+	parseInt := func(field string, into *int) {
+		if val, ok := conf[field]; ok {
+			i, err := strconv.Atoi(val)
+			if err != nil || i < 0 {
+				errs = append(errs, fmt.Errorf("invalid value %q for %s", val, field))
+				return
+			}
+			*into = i
+		}
+	}
+	parseInt(ConfigMaxConcurrentResolutions, &limits.MaxConcurrent)
+	parseInt(ConfigMaxConcurrentResolutionsPerNamespace, &limits.MaxConcurrentPerNamespace)
+	parseInt(ConfigNamespaceResolutionBurst, &limits.NamespaceBurst)
+	if val, ok := conf[ConfigNamespaceResolutionRate]; ok {
+		f, err := strconv.ParseFloat(val, 64)
+		if err != nil || f < 0 {
+			errs = append(errs, fmt.Errorf("invalid value %q for %s", val, ConfigNamespaceResolutionRate))
+		} else {
+			limits.NamespaceRate = f
+		}
+	}
+	if len(errs) > 0 {
+		return limits, fmt.Errorf("error parsing concurrency limits: %v", errs)
+	}
+	return limits, nil
+}
+
+// resolutionThrottle admits requests for resolution according to a
+// resolver's ConcurrencyLimits. When the resolver is at capacity the
+// free slots are handed out fairly: a namespace with fewer requests in
+// progress is admitted ahead of a namespace with more.
+type resolutionThrottle struct {
+	mu sync.Mutex
+
+	// active counts requests currently being resolved, per namespace.
+	active map[string]int
+	total  int
+
+	// waiting records when a throttled request was last seen, along
+	// with its namespace.
+	waiting map[string]waitingRequest
+
+	// limiters rate limit each namespace with requests that are
+	// active, waiting or were started too recently for its limiter
+	// to have refilled.
+	limiters map[string]*rate.Limiter
+}
+
+type waitingRequest struct {
+	namespace string
+	lastSeen  time.Time
+}
+
+func newResolutionThrottle() *resolutionThrottle {
+	return &resolutionThrottle{
+		active:   map[string]int{},
+		waiting:  map[string]waitingRequest{},
+		limiters: map[string]*rate.Limiter{},
+	}
+}
+
+// acquire attempts to admit the request with the given key for
+// resolution. If the request is admitted then the returned release
+// func must be called once resolution is complete. Otherwise release
+// is nil and the returned message describes why the request is queued.
+func (t *resolutionThrottle) acquire(limits ConcurrencyLimits, now time.Time, namespace, key string) (release func(), queuedMessage string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.pruneWaiting(now)
+	t.pruneLimiters(limits, now)
+
+	queue := func(message string) (func(), string) {
+		t.waiting[key] = waitingRequest{namespace: namespace, lastSeen: now}
+		return nil, message
+	}
+
+	if limits.MaxConcurrentPerNamespace > 0 && t.active[namespace] >= limits.MaxConcurrentPerNamespace {
+		return queue(fmt.Sprintf("namespace %q has reached its limit of %d concurrent resolutions", namespace, limits.MaxConcurrentPerNamespace))
+	}
+
+	if limits.MaxConcurrent > 0 {
+		if t.total >= limits.MaxConcurrent {
+			return queue(fmt.Sprintf("resolver has reached its limit of %d concurrent resolutions", limits.MaxConcurrent))
+		}
+		if free := limits.MaxConcurrent - t.total; free <= t.namespacesAheadOf(limits, namespace) {
+			return queue("waiting for namespaces with fewer resolutions in progress")
+		}
+	}
+
+	if limits.NamespaceRate > 0 && !t.limiterFor(namespace, limits).AllowN(now, 1) {
+		return queue(fmt.Sprintf("namespace %q has exceeded its limit of %v resolutions per second", namespace, limits.NamespaceRate))
+	}
+
+	delete(t.waiting, key)
+	t.active[namespace]++
+	t.total++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.active[namespace]--
+			if t.active[namespace] <= 0 {
+				delete(t.active, namespace)
+			}
+			t.total--
+		})
+	}, ""
+}
+
+// namespacesAheadOf returns the number of other namespaces with
+// waiting requests that have fewer resolutions in progress than the
+// given namespace, and so should be admitted first. Namespaces that are
+// at their own limit can't use a free slot and so aren't counted.
+func (t *resolutionThrottle) namespacesAheadOf(limits ConcurrencyLimits, namespace string) int {
+	ahead := map[string]struct{}{}
+	for _, w := range t.waiting {
+		if w.namespace == namespace {
+			continue
+		}
+		if limits.MaxConcurrentPerNamespace > 0 && t.active[w.namespace] >= limits.MaxConcurrentPerNamespace {
+			continue
+		}
+		if t.active[w.namespace] < t.active[namespace] {
+			ahead[w.namespace] = struct{}{}
+		}
+	}
+	return len(ahead)
+}
+
+// pruneWaiting forgets about waiting requests that haven't been seen
+// recently.
+func (t *resolutionThrottle) pruneWaiting(now time.Time) {
+	for key, w := range t.waiting {
+		if now.Sub(w.lastSeen) > waitingExpiry {
+			delete(t.waiting, key)
+		}
+	}
+}
+
+// pruneLimiters forgets the rate limiters of namespaces that have no
+// requests active or waiting and whose limiters have refilled, since a
+// new limiter would behave exactly the same. Without this a limiter
+// would be kept for every namespace ever seen.
+func (t *resolutionThrottle) pruneLimiters(limits ConcurrencyLimits, now time.Time) {
+	if limits.NamespaceRate <= 0 {
+		t.limiters = map[string]*rate.Limiter{}
+		return
+	}
+	waiting := map[string]bool{}
+	for _, w := range t.waiting {
+		waiting[w.namespace] = true
+	}
+	for namespace, limiter := range t.limiters {
+		if t.active[namespace] > 0 || waiting[namespace] {
+			continue
+		}
+		// A full limiter allows its whole burst at once. Using it
+		// up here doesn't matter since the limiter is dropped.
+		if limiter.AllowN(now, limiter.Burst()) {
+			delete(t.limiters, namespace)
+		}
+	}
+}
+
+// limiterFor returns the rate limiter for a namespace, updating it to
+// match the current limits.
+func (t *resolutionThrottle) limiterFor(namespace string, limits ConcurrencyLimits) *rate.Limiter {
+	burst := limits.NamespaceBurst
+	if burst <= 0 {
+		burst = 1
+	}
+	limiter, ok := t.limiters[namespace]
+	if !ok {
+		limiter = rate.NewLimiter(rate.Limit(limits.NamespaceRate), burst)
+		t.limiters[namespace] = limiter
+		return limiter
+	}
+	if limiter.Limit() != rate.Limit(limits.NamespaceRate) {
+		limiter.SetLimit(rate.Limit(limits.NamespaceRate))
+	}
+	if limiter.Burst() != burst {
+		limiter.SetBurst(burst)
+	}
+	return limiter
+}
@@ -0,0 +1,234 @@
+/*
+ Copyright 2022 The Tekton Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package framework
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tektoncd/resolution/test/diff"
+)
+
+func TestConcurrencyLimitsFromConfig(t *testing.T) {
+	defaults := ConcurrencyLimits{MaxConcurrent: 10}
+	for _, tc := range []struct {
+		name      string
+		conf      map[string]string
+		expected  ConcurrencyLimits
+		expectErr bool
+	}{{
+		name:     "no config",
+		expected: defaults,
+	}, {
+		name: "all fields",
+		conf: map[string]string{
+			ConfigMaxConcurrentResolutions:             "4",
+			ConfigMaxConcurrentResolutionsPerNamespace: "2",
+			ConfigNamespaceResolutionRate:              "0.5",
+			ConfigNamespaceResolutionBurst:             "3",
+		},
+		expected: ConcurrencyLimits{
+			MaxConcurrent:             4,
+			MaxConcurrentPerNamespace: 2,
+			NamespaceRate:             0.5,
+			NamespaceBurst:            3,
+		},
+	}, {
+		name: "invalid values are ignored",
+		conf: map[string]string{
+			ConfigMaxConcurrentResolutions:             "lots",
+			ConfigMaxConcurrentResolutionsPerNamespace: "2",
+			ConfigNamespaceResolutionRate:              "-1",
+		},
+		expected: ConcurrencyLimits{
+			MaxConcurrent:             10,
+			MaxConcurrentPerNamespace: 2,
+		},
+		expectErr: true,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			limits, err := ConcurrencyLimitsFromConfig(defaults, tc.conf)
+			if tc.expectErr && err == nil {
+				t.Errorf("expected error but got none")
+			} else if !tc.expectErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if d := cmp.Diff(tc.expected, limits); d != "" {
+				t.Errorf("unexpected limits %s", diff.PrintWantGot(d))
+			}
+		})
+	}
+}
+
+func TestThrottleMaxConcurrent(t *testing.T) {
+	throttle := newResolutionThrottle()
+	limits := ConcurrencyLimits{MaxConcurrent: 2}
+
+	releaseA, _ := throttle.acquire(limits, now, "ns-a", "ns-a/1")
+	releaseB, _ := throttle.acquire(limits, now, "ns-b", "ns-b/1")
+	if releaseA == nil || releaseB == nil {
+		t.Fatalf("expected first two requests to be admitted")
+	}
+
+	release, msg := throttle.acquire(limits, now, "ns-c", "ns-c/1")
+	if release != nil {
+		t.Fatalf("expected third request to be queued")
+	}
+	if msg != "resolver has reached its limit of 2 concurrent resolutions" {
+		t.Errorf("unexpected queued message %q", msg)
+	}
+
+	releaseA()
+	releaseA()
+	if release, _ := throttle.acquire(limits, now, "ns-c", "ns-c/1"); release == nil {
+		t.Fatalf("expected queued request to be admitted after a slot was released")
+	}
+}
+
+func TestThrottleMaxConcurrentPerNamespace(t *testing.T) {
+	throttle := newResolutionThrottle()
+	limits := ConcurrencyLimits{MaxConcurrentPerNamespace: 1}
+
+	if release, _ := throttle.acquire(limits, now, "ns-a", "ns-a/1"); release == nil {
+		t.Fatalf("expected first request to be admitted")
+	}
+	release, msg := throttle.acquire(limits, now, "ns-a", "ns-a/2")
+	if release != nil {
+		t.Fatalf("expected second request from same namespace to be queued")
+	}
+	if msg != `namespace "ns-a" has reached its limit of 1 concurrent resolutions` {
+		t.Errorf("unexpected queued message %q", msg)
+	}
+	if release, _ := throttle.acquire(limits, now, "ns-b", "ns-b/1"); release == nil {
+		t.Fatalf("expected request from another namespace to be admitted")
+	}
+}
+
+func TestThrottleFairness(t *testing.T) {
+	throttle := newResolutionThrottle()
+	limits := ConcurrencyLimits{MaxConcurrent: 3}
+
+	releases := []func(){}
+	for _, key := range []string{"busy/1", "busy/2", "busy/3"} {
+		release, _ := throttle.acquire(limits, now, "busy", key)
+		if release == nil {
+			t.Fatalf("expected %s to be admitted", key)
+		}
+		releases = append(releases, release)
+	}
+
+	// The quiet namespace is queued behind the busy one but, once a
+	// slot frees up, it should be given that slot even if the busy
+	// namespace asks for it first.
+	if release, _ := throttle.acquire(limits, now, "quiet", "quiet/1"); release != nil {
+		t.Fatalf("expected quiet/1 to be queued while resolver is full")
+	}
+	releases[0]()
+
+	if release, msg := throttle.acquire(limits, now, "busy", "busy/4"); release != nil {
+		t.Fatalf("expected busy/4 to yield to the quiet namespace")
+	} else if msg != "waiting for namespaces with fewer resolutions in progress" {
+		t.Errorf("unexpected queued message %q", msg)
+	}
+	if release, _ := throttle.acquire(limits, now, "quiet", "quiet/1"); release == nil {
+		t.Fatalf("expected quiet/1 to be admitted")
+	}
+
+	// Waiting requests that are never seen again stop holding back
+	// other namespaces.
+	if release, _ := throttle.acquire(limits, now, "other", "other/1"); release != nil {
+		t.Fatalf("expected other/1 to be queued while resolver is full")
+	}
+	releases[1]()
+	if release, _ := throttle.acquire(limits, now, "busy", "busy/4"); release != nil {
+		t.Fatalf("expected busy/4 to yield to the other namespace")
+	}
+	later := now.Add(waitingExpiry + time.Second)
+	if release, _ := throttle.acquire(limits, later, "busy", "busy/4"); release == nil {
+		t.Fatalf("expected busy/4 to be admitted once other/1 expired")
+	}
+}
+
+func TestThrottleNamespaceRate(t *testing.T) {
+	throttle := newResolutionThrottle()
+	limits := ConcurrencyLimits{NamespaceRate: 1, NamespaceBurst: 2}
+
+	for _, key := range []string{"ns-a/1", "ns-a/2"} {
+		if release, _ := throttle.acquire(limits, now, "ns-a", key); release == nil {
+			t.Fatalf("expected %s to be admitted within burst", key)
+		}
+	}
+	release, msg := throttle.acquire(limits, now, "ns-a", "ns-a/3")
+	if release != nil {
+		t.Fatalf("expected ns-a/3 to be rate limited")
+	}
+	if msg != `namespace "ns-a" has exceeded its limit of 1 resolutions per second` {
+		t.Errorf("unexpected queued message %q", msg)
+	}
+	if release, _ := throttle.acquire(limits, now, "ns-b", "ns-b/1"); release == nil {
+		t.Fatalf("expected other namespaces not to be rate limited")
+	}
+	if release, _ := throttle.acquire(limits, now.Add(time.Second), "ns-a", "ns-a/3"); release == nil {
+		t.Fatalf("expected ns-a/3 to be admitted after waiting")
+	}
+}
+
+func TestThrottlePrunesNamespaceLimiters(t *testing.T) {
+	throttle := newResolutionThrottle()
+	limits := ConcurrencyLimits{NamespaceRate: 1, NamespaceBurst: 2}
+
+	releaseA, _ := throttle.acquire(limits, now, "ns-a", "ns-a/1")
+	releaseB, _ := throttle.acquire(limits, now, "ns-b", "ns-b/1")
+	if releaseA == nil || releaseB == nil {
+		t.Fatalf("expected requests to be admitted")
+	}
+	releaseA()
+	if len(throttle.limiters) != 2 {
+		t.Fatalf("expected limiters to be kept until they refill, got %d", len(throttle.limiters))
+	}
+
+	// ns-a has refilled and has nothing active so its limiter is
+	// dropped, while ns-b's is kept for its active request.
+	later := now.Add(2 * time.Second)
+	if release, _ := throttle.acquire(limits, later, "ns-c", "ns-c/1"); release == nil {
+		t.Fatalf("expected ns-c/1 to be admitted")
+	}
+	if _, ok := throttle.limiters["ns-a"]; ok {
+		t.Errorf("expected limiter for ns-a to be pruned")
+	}
+	if _, ok := throttle.limiters["ns-b"]; !ok {
+		t.Errorf("expected limiter for ns-b to be kept while it has an active request")
+	}
+
+	// A namespace whose limiter hasn't refilled keeps it, so waiting
+	// out the limit can't be skipped.
+	releaseB()
+	if release, _ := throttle.acquire(limits, later, "ns-c", "ns-c/2"); release == nil {
+		t.Fatalf("expected ns-c/2 to be admitted")
+	}
+	if _, ok := throttle.limiters["ns-c"]; !ok {
+		t.Errorf("expected limiter for ns-c to be kept until it refills")
+	}
+
+	if release, _ := throttle.acquire(ConcurrencyLimits{}, later, "ns-d", "ns-d/1"); release == nil {
+		t.Fatalf("expected ns-d/1 to be admitted")
+	}
+	if len(throttle.limiters) != 0 {
+		t.Errorf("expected limiters to be dropped once rate limiting is switched off, got %d", len(throttle.limiters))
+	}
+}
@@ -0,0 +1,335 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugin
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tektoncd/resolution/pkg/apis/resolution/v1alpha1"
+	resolutioncommon "github.com/tektoncd/resolution/pkg/common"
+	ttesting "github.com/tektoncd/resolution/pkg/reconciler/testing"
+	"github.com/tektoncd/resolution/pkg/resolver/framework"
+	frtesting "github.com/tektoncd/resolution/pkg/resolver/framework/testing"
+	"github.com/tektoncd/resolution/test"
+	"github.com/tektoncd/resolution/test/diff"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	"knative.dev/pkg/system"
+
+	_ "knative.dev/pkg/system/testing" // Setup system.Namespace()
+)
+
+func TestValidateParams(t *testing.T) {
+	resolver := newTestPlugin(t, &framework.FakeResolver{})
+
+	if err := resolver.ValidateParams(context.Background(), map[string]string{framework.FakeParamName: "bar"}); err != nil {
+		t.Errorf("unexpected error for valid params: %v", err)
+	}
+	err := resolver.ValidateParams(context.Background(), map[string]string{})
+	if err == nil || err.Error() != "missing "+framework.FakeParamName {
+		t.Errorf("expected plugin's validation error, got %v", err)
+	}
+}
+
+func TestValidateParamsRetryableRoundTrip(t *testing.T) {
+	resolver := newTestPlugin(t, &unavailableResolver{})
+
+	err := resolver.ValidateParams(context.Background(), map[string]string{framework.FakeParamName: "bar"})
+	if err == nil || err.Error() != "backend unavailable" {
+		t.Fatalf("expected plugin's validation error, got %v", err)
+	}
+	if !framework.IsErrorRetryable(err) {
+		t.Errorf("expected retryable error, got %v", err)
+	}
+}
+
+func TestValidateParamsPluginErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name              string
+		status            int
+		expectedRetryable bool
+	}{{
+		name:   "bad request",
+		status: http.StatusBadRequest,
+	}, {
+		name:   "unprocessable entity",
+		status: http.StatusUnprocessableEntity,
+	}, {
+		name:              "too many requests",
+		status:            http.StatusTooManyRequests,
+		expectedRetryable: true,
+	}, {
+		name:              "server error",
+		status:            http.StatusInternalServerError,
+		expectedRetryable: true,
+	}, {
+		name:              "bad gateway",
+		status:            http.StatusBadGateway,
+		expectedRetryable: true,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				writeJSON(w, tc.status, ErrorResponse{Message: "something went wrong"})
+			}))
+			defer svr.Close()
+			resolver := &Resolver{Name: "Broken", Type: "broken", URL: svr.URL}
+
+			err := resolver.ValidateParams(context.Background(), nil)
+			if err == nil || err.Error() != "something went wrong" {
+				t.Fatalf("expected plugin's error, got %v", err)
+			}
+			if retryable := framework.IsErrorRetryable(err); retryable != tc.expectedRetryable {
+				t.Errorf("expected retryable to be %t, got %t", tc.expectedRetryable, retryable)
+			}
+		})
+	}
+
+	t.Run("unreachable", func(t *testing.T) {
+		svr := httptest.NewServer(http.NotFoundHandler())
+		svr.Close()
+		resolver := &Resolver{Name: "Broken", Type: "broken", URL: svr.URL}
+
+		err := resolver.ValidateParams(context.Background(), nil)
+		if !framework.IsErrorRetryable(err) {
+			t.Errorf("expected retryable error, got %v", err)
+		}
+	})
+}
+
+func TestResolveResponseTooLarge(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, ResolveResponse{Data: make([]byte, maxResponseBytes)})
+	}))
+	defer svr.Close()
+	resolver := &Resolver{Name: "Large", Type: "large", URL: svr.URL}
+
+	_, err := resolver.Resolve(context.Background(), nil)
+	if reason, _ := resolutioncommon.ReasonError(err); reason != resolutioncommon.ReasonResolvedDataTooLarge {
+		t.Errorf("expected error with reason %q, got %q: %v", resolutioncommon.ReasonResolvedDataTooLarge, reason, err)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	resolver := newTestPlugin(t, &framework.FakeResolver{ForParam: map[string]*framework.FakeResolvedResource{
+		"bar": {
+			Content:       "some content",
+			AnnotationMap: map[string]string{"foo": "bar"},
+		},
+		"broken": {
+			ErrorWith: "something went wrong",
+		},
+	}})
+
+	resolved, err := resolver.Resolve(context.Background(), map[string]string{framework.FakeParamName: "bar"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(resolved.Data()) != "some content" {
+		t.Errorf("expected data %q, got %q", "some content", string(resolved.Data()))
+	}
+	if d := cmp.Diff(map[string]string{"foo": "bar"}, resolved.Annotations()); d != "" {
+		t.Errorf("unexpected annotations %s", diff.PrintWantGot(d))
+	}
+
+	_, err = resolver.Resolve(context.Background(), map[string]string{framework.FakeParamName: "broken"})
+	if err == nil || err.Error() != "something went wrong" {
+		t.Errorf("expected plugin's resolution error, got %v", err)
+	}
+}
+
+func TestRequestScopedDataIsForwarded(t *testing.T) {
+	recorder := &recordingResolver{}
+	resolver := newTestPlugin(t, recorder)
+
+	ctx := resolutioncommon.InjectRequestNamespace(context.Background(), "foo")
+	ctx = framework.InjectResolverConfigToContext(ctx, map[string]string{"default-kind": "task"})
+	if _, err := resolver.Resolve(ctx, map[string]string{"name": "bar"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if recorder.namespace != "foo" {
+		t.Errorf("expected plugin to receive namespace %q, got %q", "foo", recorder.namespace)
+	}
+	if d := cmp.Diff(map[string]string{"default-kind": "task"}, recorder.config); d != "" {
+		t.Errorf("unexpected config received by plugin %s", diff.PrintWantGot(d))
+	}
+	if d := cmp.Diff(map[string]string{"name": "bar"}, recorder.params); d != "" {
+		t.Errorf("unexpected params received by plugin %s", diff.PrintWantGot(d))
+	}
+}
+
+func TestUnexpectedResponse(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "upstream unavailable", http.StatusBadGateway)
+	}))
+	defer svr.Close()
+	resolver := &Resolver{Name: "Broken", Type: "broken", URL: svr.URL}
+
+	_, err := resolver.Resolve(context.Background(), nil)
+	if err == nil || err.Error() != "plugin responded 502 Bad Gateway" {
+		t.Errorf("expected error describing the response, got %v", err)
+	}
+}
+
+func TestResolveRespectsContext(t *testing.T) {
+	resolver := newTestPlugin(t, &framework.FakeResolver{ForParam: map[string]*framework.FakeResolvedResource{
+		"slow": {Content: "too late", WaitFor: 10 * time.Second},
+	}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := resolver.Resolve(ctx, map[string]string{framework.FakeParamName: "slow"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded error, got %v", err)
+	}
+}
+
+func TestInitialize(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		resolver *Resolver
+		expected string
+	}{{
+		name:     "missing type",
+		resolver: &Resolver{URL: "http://localhost:8080"},
+		expected: "plugin resolver needs a type",
+	}, {
+		name:     "missing url",
+		resolver: &Resolver{Type: "demo"},
+		expected: `plugin resolver "demo" needs a url`,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.resolver.Initialize(context.Background())
+			if err == nil || err.Error() != tc.expected {
+				t.Errorf("expected error %q, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+// TestReconcile runs a plugin resolver through the framework, with
+// the plugin served in-process by the reference handler.
+func TestReconcile(t *testing.T) {
+	ctx, _ := ttesting.SetupFakeContext(t)
+	svr := httptest.NewServer(NewHandler(&framework.FakeResolver{ForParam: map[string]*framework.FakeResolvedResource{
+		"bar": {Content: "some content", AnnotationMap: map[string]string{"foo": "bar"}},
+	}}))
+	defer svr.Close()
+	resolver := &ConfigurableResolver{
+		Resolver: Resolver{
+			Name: framework.FakeResolverName,
+			Type: framework.LabelValueFakeResolverType,
+			URL:  svr.URL,
+		},
+		ConfigName: "fake-resolver-config",
+	}
+
+	request := &v1alpha1.ResolutionRequest{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "resolution.tekton.dev/v1alpha1",
+			Kind:       "ResolutionRequest",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "rr",
+			Namespace:         "foo",
+			CreationTimestamp: metav1.Time{Time: time.Now()},
+			Labels: map[string]string{
+				resolutioncommon.LabelKeyResolverType: framework.LabelValueFakeResolverType,
+			},
+		},
+		Spec: v1alpha1.ResolutionRequestSpec{
+			Parameters: map[string]string{
+				framework.FakeParamName: "bar",
+			},
+		},
+	}
+	d := test.Data{
+		ConfigMaps: []*corev1.ConfigMap{{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      resolver.ConfigName,
+				Namespace: system.Namespace(),
+			},
+		}},
+		ResolutionRequests: []*v1alpha1.ResolutionRequest{request},
+	}
+	expectedStatus := &v1alpha1.ResolutionRequestStatus{
+		Status: duckv1.Status{
+			Annotations: map[string]string{"foo": "bar"},
+			Conditions: duckv1.Conditions{{
+				Type:    apis.ConditionSucceeded,
+				Status:  corev1.ConditionUnknown,
+				Reason:  resolutioncommon.ReasonResolutionInProgress,
+				Message: `resolution in progress by resolver "Fake"`,
+			}},
+		},
+		ResolutionRequestStatusFields: v1alpha1.ResolutionRequestStatusFields{
+			Data: base64.StdEncoding.Strict().EncodeToString([]byte("some content")),
+		},
+	}
+
+	frtesting.RunResolverReconcileTest(ctx, t, d, resolver, request, expectedStatus, nil)
+}
+
+// newTestPlugin serves resolver with the reference handler and returns
+// a Resolver that talks to it.
+func newTestPlugin(t *testing.T, resolver framework.Resolver) *Resolver {
+	t.Helper()
+	if err := resolver.Initialize(context.Background()); err != nil {
+		t.Fatalf("error initializing resolver: %v", err)
+	}
+	svr := httptest.NewServer(NewHandler(resolver))
+	t.Cleanup(svr.Close)
+	return &Resolver{
+		Name: resolver.GetName(context.Background()),
+		Type: resolver.GetSelector(context.Background())[resolutioncommon.LabelKeyResolverType],
+		URL:  svr.URL + "/",
+	}
+}
+
+// recordingResolver records the request-scoped data it is called with.
+type recordingResolver struct {
+	framework.FakeResolver
+	namespace string
+	config    map[string]string
+	params    map[string]string
+}
+
+func (r *recordingResolver) Resolve(ctx context.Context, params map[string]string) (framework.ResolvedResource, error) {
+	r.namespace = resolutioncommon.RequestNamespace(ctx)
+	r.config = framework.GetResolverConfigFromContext(ctx)
+	r.params = params
+	return &framework.FakeResolvedResource{Content: strings.Join([]string{"resolved", params["name"]}, " ")}, nil
+}
+
+// unavailableResolver can never check params because something it
+// depends on is down.
+type unavailableResolver struct {
+	framework.FakeResolver
+}
+
+func (r *unavailableResolver) ValidateParams(context.Context, map[string]string) error {
+	return framework.NewErrorRetryable(errors.New("backend unavailable"))
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package plugin lets a resolver run out of process, e.g. as a sidecar
+// written in any language, by speaking a small HTTP+JSON protocol to
+// it.
+//
+// The framework side of the protocol is Resolver, which implements
+// framework.Resolver by forwarding ValidateParams and Resolve calls to
+// the plugin. NewHandler is a reference implementation of the plugin
+// side that serves any framework.Resolver.
+//
+// A plugin serves two endpoints, both of which accept a POSTed Request:
+//
+//   - ValidatePath responds 200 OK if the params are valid, 400 Bad
+//     Request with an ErrorResponse if they aren't, or 503 Service
+//     Unavailable if they can't be checked right now.
+//   - ResolvePath responds 200 OK with a ResolveResponse if the resource
+//     was resolved, or any other status with an ErrorResponse if not.
+//
+// See docs/plugin-protocol.md for the full description.
+package plugin
+
+// The paths a plugin serves, relative to its base URL.
+const (
+	ValidatePath = "/validate"
+	ResolvePath  = "/resolve"
+)
+
+// ContentType is the content type of every request and response body
+// in the protocol.
+const ContentType = "application/json"
+
+// Request is the body POSTed to both of a plugin's endpoints.
+type Request struct {
+	// Params are the parameters of the ResolutionRequest.
+	Params map[string]string `json:"params"`
+
+	// Namespace is the namespace the ResolutionRequest was made
+	// from.
+	Namespace string `json:"namespace,omitempty"`
+
+	// Config is the content of the resolver's ConfigMap, if it has
+	// one.
+	Config map[string]string `json:"config,omitempty"`
+}
+
+// ResolveResponse is the body a plugin responds with when it resolves
+// a resource successfully.
+type ResolveResponse struct {
+	// Data is the content of the resolved resource. Being bytes it
+	// is base64 encoded in JSON.
+	Data []byte `json:"data"`
+
+	// Annotations are any annotations to add to the
+	// ResolutionRequest's status along with the data.
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// ErrorResponse is the body a plugin responds with when params are
+// invalid or a resource couldn't be resolved.
+type ErrorResponse struct {
+	// Message describes what went wrong. It ends up in the message
+	// of the ResolutionRequest's condition.
+	Message string `json:"message"`
+}
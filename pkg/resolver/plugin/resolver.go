@@ -0,0 +1,196 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	resolutioncommon "github.com/tektoncd/resolution/pkg/common"
+	"github.com/tektoncd/resolution/pkg/resolver/framework"
+)
+
+// maxResponseBytes is the largest response body read from a plugin.
+// It matches the limit on the size of a ResolutionRequest object.
+const maxResponseBytes = 1.5 * 1024 * 1024
+
+// Resolver implements framework.Resolver by delegating to a plugin
+// that speaks the protocol described in this package.
+type Resolver struct {
+	// Name is the name of the resolver, e.g. "Git".
+	Name string
+
+	// Type is the value of the resolution.tekton.dev/type label of
+	// the requests sent to the plugin.
+	Type string
+
+	// URL is the base URL of the plugin, e.g. http://localhost:8080
+	// for a plugin running as a sidecar.
+	URL string
+
+	// Client is used to talk to the plugin. http.DefaultClient is
+	// used if it's nil.
+	Client *http.Client
+}
+
+var _ framework.Resolver = &Resolver{}
+
+// ConfigurableResolver is a Resolver that passes the content of a
+// ConfigMap on to the plugin with every request.
+type ConfigurableResolver struct {
+	Resolver
+
+	// ConfigName is the name of the resolver's ConfigMap.
+	ConfigName string
+}
+
+var _ framework.ConfigWatcher = &ConfigurableResolver{}
+
+// Initialize checks that the resolver has everything it needs to reach
+// its plugin.
+func (r *Resolver) Initialize(context.Context) error {
+	if r.Type == "" {
+		return errors.New("plugin resolver needs a type")
+	}
+	if r.URL == "" {
+		return fmt.Errorf("plugin resolver %q needs a url", r.Type)
+	}
+	return nil
+}
+
+// GetName returns the resolver's name.
+func (r *Resolver) GetName(context.Context) string {
+	return r.Name
+}
+
+// GetSelector returns a map of labels to match requests to this
+// resolver.
+func (r *Resolver) GetSelector(context.Context) map[string]string {
+	return map[string]string{
+		resolutioncommon.LabelKeyResolverType: r.Type,
+	}
+}
+
+// ValidateParams asks the plugin whether params are valid. Only a
+// client error response from the plugin means that they aren't; if
+// the plugin can't be reached or fails itself the error is marked as
+// retryable so that the request isn't failed as invalid.
+func (r *Resolver) ValidateParams(ctx context.Context, params map[string]string) error {
+	resp, err := r.post(ctx, ValidatePath, params)
+	if err != nil {
+		return framework.NewErrorRetryable(err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return nil
+	case resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode == http.StatusTooManyRequests:
+		return framework.NewErrorRetryable(errorFromResponse(resp))
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return errorFromResponse(resp)
+	default:
+		return framework.NewErrorRetryable(errorFromResponse(resp))
+	}
+}
+
+// Resolve asks the plugin to resolve the resource described by params.
+func (r *Resolver) Resolve(ctx context.Context, params map[string]string) (framework.ResolvedResource, error) {
+	resp, err := r.post(ctx, ResolvePath, params)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errorFromResponse(resp)
+	}
+	body, err := framework.ReadAllWithLimit(resp.Body, maxResponseBytes)
+	if err != nil {
+		return nil, err
+	}
+	resolved := &ResolveResponse{}
+	if err := json.Unmarshal(body, resolved); err != nil {
+		return nil, fmt.Errorf("error decoding response from plugin: %w", err)
+	}
+	return &resolvedResource{data: resolved.Data, annotations: resolved.Annotations}, nil
+}
+
+// GetConfigName returns the name of the resolver's ConfigMap.
+func (r *ConfigurableResolver) GetConfigName(context.Context) string {
+	return r.ConfigName
+}
+
+// post sends a Request for params, along with the request-scoped data
+// in ctx, to one of the plugin's endpoints.
+func (r *Resolver) post(ctx context.Context, path string, params map[string]string) (*http.Response, error) {
+	body, err := json.Marshal(Request{
+		Params:    params,
+		Namespace: resolutioncommon.RequestNamespace(ctx),
+		Config:    framework.GetResolverConfigFromContext(ctx),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error encoding request to plugin: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(r.URL, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("error creating request to plugin: %w", err)
+	}
+	req.Header.Set("Content-Type", ContentType)
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error calling plugin: %w", err)
+	}
+	return resp, nil
+}
+
+// errorFromResponse returns the error described by a plugin's
+// unsuccessful response.
+func errorFromResponse(resp *http.Response) error {
+	errResp := &ErrorResponse{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseBytes)).Decode(errResp); err != nil || errResp.Message == "" {
+		return fmt.Errorf("plugin responded %s", resp.Status)
+	}
+	return errors.New(errResp.Message)
+}
+
+// resolvedResource is the resource returned by a plugin.
+type resolvedResource struct {
+	data        []byte
+	annotations map[string]string
+}
+
+var _ framework.ResolvedResource = &resolvedResource{}
+
+// Data returns the resolved content.
+func (r *resolvedResource) Data() []byte {
+	return r.data
+}
+
+// Annotations returns any annotations the plugin returned with the
+// resolved content.
+func (r *resolvedResource) Annotations() map[string]string {
+	return r.annotations
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	resolutioncommon "github.com/tektoncd/resolution/pkg/common"
+	"github.com/tektoncd/resolution/pkg/resolver/framework"
+)
+
+// NewHandler returns an http.Handler that serves the plugin side of the
+// protocol using resolver. It is the reference implementation of a
+// plugin and lets an existing Go resolver be run as a sidecar.
+//
+// Errors from ValidateParams that are marked with
+// framework.NewErrorRetryable are served as 503 Service Unavailable so
+// that the framework side tries the request again instead of failing
+// it as invalid.
+//
+// The resolver's Initialize method isn't called; that is left to the
+// caller.
+func NewHandler(resolver framework.Resolver) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(ValidatePath, func(w http.ResponseWriter, req *http.Request) {
+		ctx, params, ok := readRequest(w, req)
+		if !ok {
+			return
+		}
+		if err := resolver.ValidateParams(ctx, params); err != nil {
+			status := http.StatusBadRequest
+			if framework.IsErrorRetryable(err) {
+				status = http.StatusServiceUnavailable
+			}
+			writeJSON(w, status, ErrorResponse{Message: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, struct{}{})
+	})
+	mux.HandleFunc(ResolvePath, func(w http.ResponseWriter, req *http.Request) {
+		ctx, params, ok := readRequest(w, req)
+		if !ok {
+			return
+		}
+		resolved, err := resolver.Resolve(ctx, params)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Message: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, ResolveResponse{
+			Data:        resolved.Data(),
+			Annotations: resolved.Annotations(),
+		})
+	})
+	return mux
+}
+
+// readRequest decodes a Request, returning its params along with a
+// context carrying its namespace and config in the same way the
+// framework would. If the request is malformed an error response is
+// written and false is returned.
+func readRequest(w http.ResponseWriter, req *http.Request) (context.Context, map[string]string, bool) {
+	if req.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Message: fmt.Sprintf("method %s is not allowed", req.Method)})
+		return nil, nil, false
+	}
+	pluginReq := &Request{}
+	if err := json.NewDecoder(req.Body).Decode(pluginReq); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Message: fmt.Sprintf("error decoding request: %v", err)})
+		return nil, nil, false
+	}
+	ctx := resolutioncommon.InjectRequestNamespace(req.Context(), pluginReq.Namespace)
+	if pluginReq.Config != nil {
+		ctx = framework.InjectResolverConfigToContext(ctx, pluginReq.Config)
+	}
+	return ctx, pluginReq.Params, true
+}
+
+// writeJSON writes body as the JSON response with the given status.
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
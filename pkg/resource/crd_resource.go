@@ -26,15 +26,22 @@ import (
 	rrclient "github.com/tektoncd/resolution/pkg/client/clientset/versioned"
 	rrlisters "github.com/tektoncd/resolution/pkg/client/listers/resolution/v1alpha1"
 	resolutioncommon "github.com/tektoncd/resolution/pkg/common"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
 	"knative.dev/pkg/apis"
 )
 
 // CRDRequester implements the Requester interface using
 // ResolutionRequest CRDs.
 type CRDRequester struct {
-	clientset rrclient.Interface
-	lister    rrlisters.ResolutionRequestLister
+	clientset   rrclient.Interface
+	lister      rrlisters.ResolutionRequestLister
+	sharedCache *SharedCache
+
+	// waiters is set if the requester is told by an informer when
+	// ResolutionRequests change.
+	waiters *resolutionWaiters
 }
 
 // NewCRDRequester returns an implementation of Requester that uses
@@ -42,7 +49,7 @@ type CRDRequester struct {
 // resource (e.g. Tekton Pipelines) and the responder who can fetch
 // it (e.g. the gitresolver)
 func NewCRDRequester(clientset rrclient.Interface, lister rrlisters.ResolutionRequestLister) *CRDRequester {
-	return &CRDRequester{clientset, lister}
+	return &CRDRequester{clientset: clientset, lister: lister}
 }
 
 var _ Requester = &CRDRequester{}
@@ -50,34 +57,83 @@ var _ Requester = &CRDRequester{}
 // Submit constructs a ResolutionRequest object and submits it to the
 // kubernetes cluster, returning any errors experienced while doing so.
 // If ResolutionRequest is succeeded then it returns the resolved data.
+//
+// If the requester was created with a SharedCache that includes the
+// resolver then the request is first submitted to the shared namespace
+// and only submitted to the caller's namespace if the shared result
+// turns out not to be shareable. Either way the request must be
+// admitted in the caller's namespace.
 func (r *CRDRequester) Submit(ctx context.Context, resolver ResolverName, req Request) (ResolvedResource, error) {
+	if sharedReq, ok := r.sharedRequestFor(resolver, req); ok {
+		if err := r.admit(ctx, resolver, req); err != nil {
+			return nil, err
+		}
+		resource, err := r.submit(ctx, resolver, sharedReq)
+		if err == resolutioncommon.ErrorRequestInProgress || isShareable(resource, err) {
+			return resource, err
+		}
+	}
+	return r.submit(ctx, resolver, req)
+}
+
+// submit creates the ResolutionRequest for req if it doesn't exist yet,
+// or otherwise returns its outcome.
+func (r *CRDRequester) submit(ctx context.Context, resolver ResolverName, req Request) (ResolvedResource, error) {
 	rr, _ := r.lister.ResolutionRequests(req.Namespace()).Get(req.Name())
 	if rr == nil {
-		if err := r.createResolutionRequest(ctx, resolver, req); err != nil {
+		err := r.createResolutionRequest(ctx, resolver, req)
+		if !apierrors.IsAlreadyExists(err) {
+			if err != nil {
+				return nil, err
+			}
+			return nil, resolutioncommon.ErrorRequestInProgress
+		}
+		// The lister hasn't caught up with a request that was
+		// already submitted, so treat it like one that's still
+		// in progress once the caller is one of its owners.
+		if err := r.addOwnerReference(ctx, req.Namespace(), req.Name(), nil, req); err != nil {
 			return nil, err
 		}
 		return nil, resolutioncommon.ErrorRequestInProgress
 	}
 
-	if rr.Status.GetCondition(apis.ConditionSucceeded).IsUnknown() {
-		// TODO(sbwsg): This should be where an existing
-		// resource is given an additional owner reference so
-		// that it doesn't get deleted until the caller is done
-		// with it. Use appendOwnerReference and then submit
-		// update to ResolutionRequest.
+	// The request may have been submitted by someone else, so the
+	// caller is added as an owner to stop it being deleted while
+	// they still need it.
+	if err := r.addOwnerReference(ctx, rr.Namespace, rr.Name, rr, req); err != nil {
+		return nil, err
+	}
+
+	return resolutionOutcome(rr)
+}
+
+// resolutionOutcome returns the resolved resource of a completed
+// ResolutionRequest, or an error carrying the reason it failed. If the
+// request is still in progress ErrorRequestInProgress is returned.
+func resolutionOutcome(rr *v1alpha1.ResolutionRequest) (ResolvedResource, error) {
+	condition := rr.Status.GetCondition(apis.ConditionSucceeded)
+	if condition.IsUnknown() {
 		return nil, resolutioncommon.ErrorRequestInProgress
 	}
 
-	if rr.Status.GetCondition(apis.ConditionSucceeded).IsTrue() {
+	if condition.IsTrue() {
 		return crdIntoResource(rr), nil
 	}
 
-	message := rr.Status.GetCondition(apis.ConditionSucceeded).GetMessage()
-	err := resolutioncommon.NewError(resolutioncommon.ReasonResolutionFailed, errors.New(message))
-	return nil, err
+	reason := condition.GetReason()
+	if reason == "" {
+		reason = resolutioncommon.ReasonResolutionFailed
+	}
+	return nil, resolutioncommon.NewError(reason, errors.New(condition.GetMessage()))
 }
 
 func (r *CRDRequester) createResolutionRequest(ctx context.Context, resolver ResolverName, req Request) error {
+	_, err := r.clientset.ResolutionV1alpha1().ResolutionRequests(req.Namespace()).Create(ctx, r.newResolutionRequest(resolver, req), metav1.CreateOptions{})
+	return err
+}
+
+// newResolutionRequest returns the ResolutionRequest to create for req.
+func (r *CRDRequester) newResolutionRequest(resolver ResolverName, req Request) *v1alpha1.ResolutionRequest {
 	rr := &v1alpha1.ResolutionRequest{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: "resolution.tekton.dev/v1alpha1",
@@ -94,24 +150,58 @@ func (r *CRDRequester) createResolutionRequest(ctx context.Context, resolver Res
 			Parameters: req.Params(),
 		},
 	}
+	if r.sharedCache != nil && req.Namespace() == r.sharedCache.Namespace {
+		rr.Labels[resolutioncommon.LabelKeyShared] = "true"
+	}
 	appendOwnerReference(rr, req)
-	_, err := r.clientset.ResolutionV1alpha1().ResolutionRequests(rr.Namespace).Create(ctx, rr, metav1.CreateOptions{})
-	return err
+	return rr
+}
+
+// addOwnerReference adds the owner of req, if it has one, to the owner
+// references of the named ResolutionRequest, starting from latest if
+// it is given and otherwise getting it. Updates that conflict with
+// other submitters doing the same are retried against the latest
+// version of the ResolutionRequest.
+func (r *CRDRequester) addOwnerReference(ctx context.Context, namespace, name string, latest *v1alpha1.ResolutionRequest, req Request) error {
+	if _, ok := req.(OwnedRequest); !ok {
+		return nil
+	}
+	client := r.clientset.ResolutionV1alpha1().ResolutionRequests(namespace)
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if latest == nil {
+			var err error
+			if latest, err = client.Get(ctx, name, metav1.GetOptions{}); err != nil {
+				return err
+			}
+		}
+		updated := latest.DeepCopy()
+		latest = nil
+		if !appendOwnerReference(updated, req) {
+			return nil
+		}
+		_, err := client.Update(ctx, updated, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("error adding owner reference to resolution request %s/%s: %w", namespace, name, err)
+	}
+	return nil
 }
 
-func appendOwnerReference(rr *v1alpha1.ResolutionRequest, req Request) {
+// appendOwnerReference adds the owner of req, if it has one, to rr's
+// owner references. It returns true if rr was changed.
+func appendOwnerReference(rr *v1alpha1.ResolutionRequest, req Request) bool {
 	if ownedReq, ok := req.(OwnedRequest); ok {
 		newOwnerRef := ownedReq.OwnerRef()
-		isOwner := false
 		for _, ref := range rr.ObjectMeta.OwnerReferences {
 			if ownerRefsAreEqual(ref, newOwnerRef) {
-				isOwner = true
+				return false
 			}
 		}
-		if !isOwner {
-			rr.ObjectMeta.OwnerReferences = append(rr.ObjectMeta.OwnerReferences, newOwnerRef)
-		}
+		rr.ObjectMeta.OwnerReferences = append(rr.ObjectMeta.OwnerReferences, newOwnerRef)
+		return true
 	}
+	return false
 }
 
 func ownerRefsAreEqual(a, b metav1.OwnerReference) bool {
@@ -0,0 +1,207 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resource
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tektoncd/resolution/pkg/apis/resolution/v1alpha1"
+	"github.com/tektoncd/resolution/pkg/client/clientset/versioned/fake"
+	rrlisters "github.com/tektoncd/resolution/pkg/client/listers/resolution/v1alpha1"
+	resolutioncommon "github.com/tektoncd/resolution/pkg/common"
+	"github.com/tektoncd/resolution/test/diff"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ktesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+)
+
+func TestSubmitAddsOwnerReference(t *testing.T) {
+	existingOwner := ownerRef("existing")
+	for _, tc := range []struct {
+		name        string
+		status      corev1.ConditionStatus
+		owners      []metav1.OwnerReference
+		expectedErr error
+		expectAdded bool
+	}{{
+		name:        "in progress",
+		status:      corev1.ConditionUnknown,
+		owners:      []metav1.OwnerReference{existingOwner},
+		expectedErr: resolutioncommon.ErrorRequestInProgress,
+		expectAdded: true,
+	}, {
+		name:        "completed",
+		status:      corev1.ConditionTrue,
+		owners:      []metav1.OwnerReference{existingOwner},
+		expectAdded: true,
+	}, {
+		name:        "already an owner",
+		status:      corev1.ConditionUnknown,
+		owners:      []metav1.OwnerReference{existingOwner, ownerRef("new")},
+		expectedErr: resolutioncommon.ErrorRequestInProgress,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := existingRequest(tc.status, tc.owners...)
+			requester, clientset := newTestRequesterWithRequest(rr)
+
+			_, err := requester.Submit(context.Background(), "fake", ownedRequest{NewRequest(rr.Name, rr.Namespace, nil), ownerRef("new")})
+			if err != tc.expectedErr {
+				t.Fatalf("expected error %v, got %v", tc.expectedErr, err)
+			}
+
+			updated, err := clientset.ResolutionV1alpha1().ResolutionRequests(rr.Namespace).Get(context.Background(), rr.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("getting ResolutionRequest: %v", err)
+			}
+			if d := cmp.Diff([]metav1.OwnerReference{existingOwner, ownerRef("new")}, updated.OwnerReferences); d != "" {
+				t.Errorf("unexpected owner references %s", diff.PrintWantGot(d))
+			}
+			updates := 0
+			for _, action := range clientset.Actions() {
+				if action.GetVerb() == "update" {
+					updates++
+				}
+			}
+			if tc.expectAdded && updates != 1 {
+				t.Errorf("expected one update, got %d", updates)
+			} else if !tc.expectAdded && updates != 0 {
+				t.Errorf("expected no updates for an existing owner, got %d", updates)
+			}
+		})
+	}
+}
+
+func TestSubmitAddsOwnerReferencesFromConcurrentSubmitters(t *testing.T) {
+	rr := existingRequest(corev1.ConditionUnknown)
+	requester, clientset := newTestRequesterWithRequest(rr)
+
+	// The lister holds the same stale copy of the request for every
+	// submitter so all but one of their first updates conflict.
+	var expected []metav1.OwnerReference
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		owner := ownerRef(fmt.Sprintf("owner-%d", i))
+		expected = append(expected, owner)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := ownedRequest{NewRequest(rr.Name, rr.Namespace, nil), owner}
+			if _, err := requester.Submit(context.Background(), "fake", req); err != resolutioncommon.ErrorRequestInProgress {
+				t.Errorf("expected request to be in progress, got %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	updated, err := clientset.ResolutionV1alpha1().ResolutionRequests(rr.Namespace).Get(context.Background(), rr.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting ResolutionRequest: %v", err)
+	}
+	if len(updated.OwnerReferences) != len(expected) {
+		t.Fatalf("expected %d owner references, got %v", len(expected), updated.OwnerReferences)
+	}
+	for _, owner := range expected {
+		found := false
+		for _, ref := range updated.OwnerReferences {
+			if ownerRefsAreEqual(ref, owner) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("expected owner reference for %q", owner.Name)
+		}
+	}
+}
+
+type ownedRequest struct {
+	Request
+	owner metav1.OwnerReference
+}
+
+var _ OwnedRequest = ownedRequest{}
+
+func (r ownedRequest) OwnerRef() metav1.OwnerReference {
+	return r.owner
+}
+
+func ownerRef(name string) metav1.OwnerReference {
+	return metav1.OwnerReference{
+		APIVersion: "tekton.dev/v1beta1",
+		Kind:       "PipelineRun",
+		Name:       name,
+		UID:        types.UID(name + "-uid"),
+	}
+}
+
+func existingRequest(status corev1.ConditionStatus, owners ...metav1.OwnerReference) *v1alpha1.ResolutionRequest {
+	return &v1alpha1.ResolutionRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "rr",
+			Namespace:       "foo",
+			ResourceVersion: "1",
+			OwnerReferences: owners,
+		},
+		Status: v1alpha1.ResolutionRequestStatus{
+			Status: duckv1.Status{
+				Conditions: duckv1.Conditions{{
+					Type:   apis.ConditionSucceeded,
+					Status: status,
+				}},
+			},
+		},
+	}
+}
+
+// newTestRequesterWithRequest returns a CRDRequester whose lister and
+// fake clientset both hold rr. Unlike the default fake clientset,
+// updates with a stale resourceVersion are rejected with a conflict
+// like the API server would.
+func newTestRequesterWithRequest(rr *v1alpha1.ResolutionRequest) (*CRDRequester, *fake.Clientset) {
+	clientset := fake.NewSimpleClientset(rr)
+	var mu sync.Mutex
+	clientset.PrependReactor("update", "resolutionrequests", func(action ktesting.Action) (bool, runtime.Object, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		updated := action.(ktesting.UpdateAction).GetObject().(*v1alpha1.ResolutionRequest).DeepCopy()
+		current, err := clientset.Tracker().Get(action.GetResource(), updated.Namespace, updated.Name)
+		if err != nil {
+			return true, nil, err
+		}
+		currentVersion := current.(*v1alpha1.ResolutionRequest).ResourceVersion
+		if updated.ResourceVersion != currentVersion {
+			return true, nil, apierrors.NewConflict(action.GetResource().GroupResource(), updated.Name, fmt.Errorf("resourceVersion %s is stale", updated.ResourceVersion))
+		}
+		version, _ := strconv.Atoi(currentVersion)
+		updated.ResourceVersion = strconv.Itoa(version + 1)
+		return true, updated, clientset.Tracker().Update(action.GetResource(), updated, updated.Namespace)
+	})
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	_ = indexer.Add(rr)
+	return NewCRDRequester(clientset, rrlisters.NewResolutionRequestLister(indexer)), clientset
+}
@@ -0,0 +1,22 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package direct provides a resource.Requester that runs resolvers
+// in-process, without a cluster or ResolutionRequests, e.g. for CLIs
+// and tests. Resolved data goes through the same size limit, resource
+// validation and content policy checks as it would in a resolver
+// controller configured with the same ReconcilerModifiers.
+package direct
@@ -0,0 +1,168 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package direct
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	resolutioncommon "github.com/tektoncd/resolution/pkg/common"
+	"github.com/tektoncd/resolution/pkg/resolver/framework"
+	"github.com/tektoncd/resolution/pkg/resource"
+)
+
+// defaultResolutionTimeout matches the timeout that the resolver
+// framework gives a resolver that doesn't set its own.
+const defaultResolutionTimeout = time.Minute
+
+// Requester is a resource.Requester that hands requests straight to
+// framework.Resolver implementations in-process, without creating any
+// ResolutionRequests. Requests are routed to a resolver by the value
+// of the resolution.tekton.dev/type label in its selector, which is
+// the resource.ResolverName a caller submits to.
+type Requester struct {
+	resolvers map[resource.ResolverName]framework.Resolver
+	configs   map[resource.ResolverName]map[string]string
+	checks    *framework.ResourceChecks
+}
+
+var _ resource.BlockingRequester = &Requester{}
+
+// NewRequester initializes the given resolvers and returns a Requester
+// that routes requests to them. Resolved data is checked in the same
+// way as by a resolver controller with no ReconcilerModifiers until
+// SetModifiers is called.
+func NewRequester(ctx context.Context, resolvers ...framework.Resolver) (*Requester, error) {
+	r := &Requester{
+		resolvers: map[resource.ResolverName]framework.Resolver{},
+		configs:   map[resource.ResolverName]map[string]string{},
+		checks:    framework.NewResourceChecks(),
+	}
+	for _, resolver := range resolvers {
+		name := resource.ResolverName(resolver.GetSelector(ctx)[resolutioncommon.LabelKeyResolverType])
+		if name == "" {
+			return nil, framework.ErrorMissingTypeSelector
+		}
+		if err := resolver.Initialize(ctx); err != nil {
+			return nil, fmt.Errorf("error initializing resolver %q: %w", resolver.GetName(ctx), err)
+		}
+		r.resolvers[name] = resolver
+	}
+	return r, nil
+}
+
+// SetConfig sets the config that the named resolver receives in
+// place of the contents of its ConfigMap.
+func (r *Requester) SetConfig(resolver resource.ResolverName, conf map[string]string) {
+	r.configs[resolver] = conf
+}
+
+// SetModifiers sets the ReconcilerModifiers that resolved data is
+// checked as if a resolver controller had been started with, e.g.
+// framework.WithResourceValidation or framework.WithContentPolicy.
+func (r *Requester) SetModifiers(modifiers ...framework.ReconcilerModifier) {
+	r.checks = framework.NewResourceChecks(modifiers...)
+}
+
+// Submit validates the request's params with the resolver, resolves
+// it and checks the resolved data, returning the result straight
+// away. Failures are returned as a *resolutioncommon.Error with the
+// same reason the resolver framework would have given the
+// ResolutionRequest.
+func (r *Requester) Submit(ctx context.Context, resolverName resource.ResolverName, req resource.Request) (resource.ResolvedResource, error) {
+	resolver, ok := r.resolvers[resolverName]
+	if !ok {
+		return nil, fmt.Errorf("no resolver for type %q", resolverName)
+	}
+	key := fmt.Sprintf("%s/%s", req.Namespace(), req.Name())
+
+	ctx = resolutioncommon.InjectRequestNamespace(ctx, req.Namespace())
+	conf := r.configs[resolverName]
+	if conf == nil {
+		conf = map[string]string{}
+	}
+	ctx = framework.InjectResolverConfigToContext(ctx, conf)
+	ctx = r.checks.InjectMaxResolvedDataSize(ctx)
+
+	timeout := defaultResolutionTimeout
+	if timed, ok := resolver.(framework.TimedResolution); ok {
+		timeout = timed.GetResolutionTimeout(ctx, defaultResolutionTimeout)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if err := resolver.ValidateParams(ctx, req.Params()); err != nil {
+		return nil, failure(&resolutioncommon.ErrorInvalidRequest{
+			ResolutionRequestKey: key,
+			Message:              err.Error(),
+		})
+	}
+	resolved, err := resolver.Resolve(ctx, req.Params())
+	if err != nil {
+		var getErr error = &resolutioncommon.ErrorGettingResource{
+			ResolverName: resolver.GetName(ctx),
+			Key:          key,
+			Original:     err,
+		}
+		// Keep the reason of errors like exceeding the size limit
+		// that resolvers report themselves.
+		var reasoned *resolutioncommon.Error
+		if errors.As(err, &reasoned) {
+			getErr = resolutioncommon.NewError(reasoned.Reason, getErr)
+		}
+		return nil, failure(getErr)
+	}
+	if err := r.checks.Check(ctx, req.Params(), resolved); err != nil {
+		return nil, failure(err)
+	}
+	return &resolvedResource{
+		data:        resolved.Data(),
+		annotations: resolved.Annotations(),
+	}, nil
+}
+
+// SubmitAndWait is the same as Submit since a Requester never leaves a
+// request in progress.
+func (r *Requester) SubmitAndWait(ctx context.Context, resolver resource.ResolverName, req resource.Request) (resource.ResolvedResource, error) {
+	return r.Submit(ctx, resolver, req)
+}
+
+// failure wraps err in the same way that a failed ResolutionRequest is
+// reported to a resource.CRDRequester.
+func failure(err error) error {
+	reason, _ := resolutioncommon.ReasonError(err)
+	return resolutioncommon.NewError(reason, err)
+}
+
+// resolvedResource is the resource.ResolvedResource returned by a
+// Requester.
+type resolvedResource struct {
+	data        []byte
+	annotations map[string]string
+}
+
+var _ resource.ResolvedResource = &resolvedResource{}
+
+func (r *resolvedResource) Data() ([]byte, error) {
+	return r.data, nil
+}
+
+func (r *resolvedResource) Annotations() map[string]string {
+	return r.annotations
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package direct
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	resolutioncommon "github.com/tektoncd/resolution/pkg/common"
+	"github.com/tektoncd/resolution/pkg/resolver/framework"
+	"github.com/tektoncd/resolution/pkg/resource"
+)
+
+const fakeResolverName = resource.ResolverName(framework.LabelValueFakeResolverType)
+
+func TestRequester(t *testing.T) {
+	ctx := context.Background()
+	fakeResolver := &framework.FakeResolver{ForParam: map[string]*framework.FakeResolvedResource{
+		"bar": {Content: "some content", AnnotationMap: map[string]string{"foo": "bar"}},
+		"baz": {ErrorWith: "fake failure"},
+	}}
+	requester, err := NewRequester(ctx, fakeResolver)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resolved, err := requester.Submit(ctx, fakeResolverName, resource.NewRequest("rr", "foo", map[string]string{
+		framework.FakeParamName: "bar",
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data, _ := resolved.Data(); string(data) != "some content" {
+		t.Errorf("expected data %q, got %q", "some content", string(data))
+	}
+	if resolved.Annotations()["foo"] != "bar" {
+		t.Errorf("expected annotations to be passed through, got %v", resolved.Annotations())
+	}
+
+	for _, tc := range []struct {
+		name     string
+		resolver resource.ResolverName
+		params   map[string]string
+		expected string
+	}{{
+		name:     "resolver fails",
+		resolver: fakeResolverName,
+		params:   map[string]string{framework.FakeParamName: "baz"},
+		expected: `error getting "Fake" "foo/rr": fake failure`,
+	}, {
+		name:     "invalid params",
+		resolver: fakeResolverName,
+		params:   map[string]string{},
+		expected: `invalid resource request "foo/rr": missing fake-key`,
+	}, {
+		name:     "unknown resolver",
+		resolver: "other",
+		expected: `no resolver for type "other"`,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := requester.Submit(ctx, tc.resolver, resource.NewRequest("rr", "foo", tc.params))
+			if err == nil {
+				t.Fatalf("expected error %q", tc.expected)
+			}
+			if err.Error() != tc.expected {
+				t.Errorf("expected error %q, got %q", tc.expected, err.Error())
+			}
+			if tc.resolver != "other" {
+				var resolutionErr *resolutioncommon.Error
+				if !errors.As(err, &resolutionErr) || resolutionErr.Reason != resolutioncommon.ReasonResolutionFailed {
+					t.Errorf("expected resolution failure, got %#v", err)
+				}
+			}
+		})
+	}
+}
+
+// denyAll is a framework.ContentRule that rejects every resource.
+type denyAll struct{}
+
+func (denyAll) Name() string { return "deny-all" }
+
+func (denyAll) Allows(context.Context, map[string]interface{}, map[string]string) (bool, error) {
+	return false, nil
+}
+
+func TestRequesterChecksResolvedData(t *testing.T) {
+	ctx := context.Background()
+	fakeResolver := &framework.FakeResolver{ForParam: map[string]*framework.FakeResolvedResource{
+		"bar": {Content: "kind: Task\n"},
+	}}
+	req := resource.NewRequest("rr", "foo", map[string]string{framework.FakeParamName: "bar"})
+
+	for _, tc := range []struct {
+		name           string
+		conf           map[string]string
+		modifiers      []framework.ReconcilerModifier
+		expectedReason string
+	}{{
+		name:           "larger than size limit",
+		conf:           map[string]string{framework.ConfigMaxResolvedDataSize: "5"},
+		expectedReason: resolutioncommon.ReasonResolvedDataTooLarge,
+	}, {
+		name:           "not a valid resource",
+		modifiers:      []framework.ReconcilerModifier{framework.WithResourceValidation()},
+		expectedReason: resolutioncommon.ReasonInvalidResource,
+	}, {
+		name:           "not a valid resource with validation enabled by config",
+		conf:           map[string]string{framework.ConfigValidateResources: "true"},
+		expectedReason: resolutioncommon.ReasonInvalidResource,
+	}, {
+		name:           "not allowed by content policy",
+		modifiers:      []framework.ReconcilerModifier{framework.WithContentPolicy(denyAll{})},
+		expectedReason: resolutioncommon.ReasonPolicyViolation,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			requester, err := NewRequester(ctx, fakeResolver)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			requester.SetConfig(fakeResolverName, tc.conf)
+			requester.SetModifiers(tc.modifiers...)
+
+			_, err = requester.Submit(ctx, fakeResolverName, req)
+			var resolutionErr *resolutioncommon.Error
+			if !errors.As(err, &resolutionErr) || resolutionErr.Reason != tc.expectedReason {
+				t.Fatalf("expected error with reason %q, got %v", tc.expectedReason, err)
+			}
+		})
+	}
+}
@@ -35,6 +35,18 @@ type Requester interface {
 	Submit(context.Context, ResolverName, Request) (ResolvedResource, error)
 }
 
+// BlockingRequester is implemented by a Requester that can also wait
+// for a request to be resolved before returning, for callers that
+// aren't controllers and so can't requeue and try again later.
+type BlockingRequester interface {
+	Requester
+
+	// SubmitAndWait submits a request in the same way as Submit
+	// and then waits until it either completes or the given context
+	// is done.
+	SubmitAndWait(context.Context, ResolverName, Request) (ResolvedResource, error)
+}
+
 // Request is implemented by any type that represents a single request
 // for a remote resource. Implementing this interface gives the underlying
 // type an opportunity to control properties such as whether the name of
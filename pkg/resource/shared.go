@@ -0,0 +1,107 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resource
+
+import (
+	"context"
+
+	rrclient "github.com/tektoncd/resolution/pkg/client/clientset/versioned"
+	rrlisters "github.com/tektoncd/resolution/pkg/client/listers/resolution/v1alpha1"
+	resolutioncommon "github.com/tektoncd/resolution/pkg/common"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SharedCache configures a CRDRequester to share the results of
+// requests between namespaces. Requests to the listed resolvers are
+// made once, in a single shared namespace, and their result is handed
+// to every namespace that asks for the same thing.
+//
+// Only results that the resolver has marked as cluster-shareable are
+// reused. Anything else, including failures, is requested again from
+// the caller's own namespace. Shared requests are labelled with
+// resolution.tekton.dev/shared and deleted by the controller a day
+// after they complete, so they're resolved again from time to time.
+//
+// Every request is still checked against the admission policy of the
+// caller's namespace, with a dry run, before a shared result is used.
+type SharedCache struct {
+	// Namespace is where shared ResolutionRequests are created. The
+	// requester's lister must be able to see it.
+	Namespace string
+
+	// Resolvers are the resolvers whose results may be shared.
+	Resolvers []ResolverName
+}
+
+// NewCRDRequesterWithSharedCache returns a CRDRequester that shares the
+// results of requests to cluster-shareable resolvers between
+// namespaces using the given cache.
+func NewCRDRequesterWithSharedCache(clientset rrclient.Interface, lister rrlisters.ResolutionRequestLister, cache SharedCache) *CRDRequester {
+	r := NewCRDRequester(clientset, lister)
+	r.sharedCache = &cache
+	return r
+}
+
+// sharedRequestFor returns the request to submit to the shared
+// namespace in place of req. ok is false if results of the resolver
+// aren't shared.
+func (r *CRDRequester) sharedRequestFor(resolver ResolverName, req Request) (Request, bool) {
+	if r.sharedCache == nil || r.sharedCache.Namespace == "" || req.Namespace() == r.sharedCache.Namespace {
+		return nil, false
+	}
+	shared := false
+	for _, name := range r.sharedCache.Resolvers {
+		if name == resolver {
+			shared = true
+		}
+	}
+	if !shared {
+		return nil, false
+	}
+	// The name only depends on the resolver and params so that
+	// every namespace making the same request finds the same one.
+	name, err := GenerateDeterministicName(string(resolver), string(resolver), req.Params())
+	if err != nil {
+		return nil, false
+	}
+	return NewRequest(name, r.sharedCache.Namespace, req.Params()), true
+}
+
+// admit checks that req would be admitted in its own namespace by
+// creating it as a dry run. This applies the admission policy of the
+// caller's namespace, e.g. which sources it may resolve from, to
+// results shared from the shared namespace too. A request that already
+// exists was admitted when it was created or last updated.
+func (r *CRDRequester) admit(ctx context.Context, resolver ResolverName, req Request) error {
+	_, err := r.clientset.ResolutionV1alpha1().ResolutionRequests(req.Namespace()).Create(ctx, r.newResolutionRequest(resolver, req), metav1.CreateOptions{
+		DryRun: []string{metav1.DryRunAll},
+	})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
+
+// isShareable returns true if resource was resolved successfully and
+// marked by its resolver as shareable between namespaces.
+func isShareable(resource ResolvedResource, err error) bool {
+	if err != nil || resource == nil {
+		return false
+	}
+	return resource.Annotations()[resolutioncommon.AnnotationKeyClusterShareable] == "true"
+}
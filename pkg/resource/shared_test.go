@@ -0,0 +1,198 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resource
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tektoncd/resolution/pkg/apis/resolution/v1alpha1"
+	"github.com/tektoncd/resolution/pkg/client/clientset/versioned/fake"
+	rrlisters "github.com/tektoncd/resolution/pkg/client/listers/resolution/v1alpha1"
+	resolutioncommon "github.com/tektoncd/resolution/pkg/common"
+	"github.com/tektoncd/resolution/test/diff"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ktesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+)
+
+const sharedNamespace = "shared"
+
+func TestSubmitSharedCache(t *testing.T) {
+	params := map[string]string{"bundle": "example.com/bundle@sha256:abc"}
+	sharedName, err := GenerateDeterministicName("bundles", "bundles", params)
+	if err != nil {
+		t.Fatalf("unexpected error generating name: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name              string
+		resolver          ResolverName
+		existing          []*v1alpha1.ResolutionRequest
+		expectedData      string
+		expectedErr       error
+		expectedCreatedIn []string
+	}{{
+		name:     "shareable result in shared namespace",
+		resolver: "bundles",
+		existing: []*v1alpha1.ResolutionRequest{
+			completedRequest(sharedName, sharedNamespace, "shared content", true),
+		},
+		expectedData: "shared content",
+		// The request is created in the tenant's namespace as a
+		// dry run to check it would be admitted there.
+		expectedCreatedIn: []string{"tenant"},
+	}, {
+		name:              "no shared request yet",
+		resolver:          "bundles",
+		expectedErr:       resolutioncommon.ErrorRequestInProgress,
+		expectedCreatedIn: []string{"tenant", sharedNamespace},
+	}, {
+		name:     "shared result not shareable",
+		resolver: "bundles",
+		existing: []*v1alpha1.ResolutionRequest{
+			completedRequest(sharedName, sharedNamespace, "shared content", false),
+		},
+		expectedErr:       resolutioncommon.ErrorRequestInProgress,
+		expectedCreatedIn: []string{"tenant", "tenant"},
+	}, {
+		name:              "resolver not shared",
+		resolver:          "git",
+		expectedErr:       resolutioncommon.ErrorRequestInProgress,
+		expectedCreatedIn: []string{"tenant"},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			var objs []runtime.Object
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+			for _, rr := range tc.existing {
+				objs = append(objs, rr)
+				_ = indexer.Add(rr)
+			}
+			clientset := fake.NewSimpleClientset(objs...)
+			requester := NewCRDRequesterWithSharedCache(clientset, rrlisters.NewResolutionRequestLister(indexer), SharedCache{
+				Namespace: sharedNamespace,
+				Resolvers: []ResolverName{"bundles"},
+			})
+
+			resource, err := requester.Submit(context.Background(), tc.resolver, NewRequest("rr", "tenant", params))
+			if err != tc.expectedErr {
+				t.Fatalf("expected error %v, got %v", tc.expectedErr, err)
+			}
+			if tc.expectedData != "" {
+				data, err := resource.Data()
+				if err != nil {
+					t.Fatalf("unexpected error getting data: %v", err)
+				}
+				if string(data) != tc.expectedData {
+					t.Errorf("expected data %q, got %q", tc.expectedData, string(data))
+				}
+			}
+
+			var created []string
+			for _, action := range clientset.Actions() {
+				if action.GetVerb() == "create" {
+					created = append(created, action.GetNamespace())
+				}
+			}
+			if d := cmp.Diff(tc.expectedCreatedIn, created); d != "" {
+				t.Errorf("unexpected namespaces requests were created in %s", diff.PrintWantGot(d))
+			}
+		})
+	}
+}
+
+func TestSubmitSharedCacheLabelsSharedRequests(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	requester := NewCRDRequesterWithSharedCache(clientset, rrlisters.NewResolutionRequestLister(indexer), SharedCache{
+		Namespace: sharedNamespace,
+		Resolvers: []ResolverName{"bundles"},
+	})
+
+	params := map[string]string{"bundle": "example.com/bundle@sha256:abc"}
+	if _, err := requester.Submit(context.Background(), "bundles", NewRequest("rr", "tenant", params)); err != resolutioncommon.ErrorRequestInProgress {
+		t.Fatalf("expected request to be in progress, got %v", err)
+	}
+	sharedRequests, err := clientset.ResolutionV1alpha1().ResolutionRequests(sharedNamespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error listing shared requests: %v", err)
+	}
+	if len(sharedRequests.Items) != 1 || sharedRequests.Items[0].Labels[resolutioncommon.LabelKeyShared] != "true" {
+		t.Errorf("expected a shared request labelled %s, got %v", resolutioncommon.LabelKeyShared, sharedRequests.Items)
+	}
+}
+
+func TestSubmitSharedCacheNotAdmittedInNamespace(t *testing.T) {
+	params := map[string]string{"bundle": "example.com/bundle@sha256:abc"}
+	sharedName, err := GenerateDeterministicName("bundles", "bundles", params)
+	if err != nil {
+		t.Fatalf("unexpected error generating name: %v", err)
+	}
+	shared := completedRequest(sharedName, sharedNamespace, "shared content", true)
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	_ = indexer.Add(shared)
+	clientset := fake.NewSimpleClientset(shared)
+
+	// Stand in for the webhook denying requests from the tenant's
+	// namespace, e.g. by its resolution policy.
+	denied := apierrors.NewForbidden(v1alpha1.Resource("resolutionrequests"), "rr", errors.New("bundle is not allowed in namespace tenant"))
+	clientset.PrependReactor("create", "resolutionrequests", func(action ktesting.Action) (bool, runtime.Object, error) {
+		return action.GetNamespace() == "tenant", nil, denied
+	})
+	requester := NewCRDRequesterWithSharedCache(clientset, rrlisters.NewResolutionRequestLister(indexer), SharedCache{
+		Namespace: sharedNamespace,
+		Resolvers: []ResolverName{"bundles"},
+	})
+
+	resource, err := requester.Submit(context.Background(), "bundles", NewRequest("rr", "tenant", params))
+	if !apierrors.IsForbidden(err) {
+		t.Fatalf("expected the shared result to be refused, got %v, %v", resource, err)
+	}
+}
+
+func completedRequest(name, namespace, content string, shareable bool) *v1alpha1.ResolutionRequest {
+	annotations := map[string]string{}
+	if shareable {
+		annotations[resolutioncommon.AnnotationKeyClusterShareable] = "true"
+	}
+	return &v1alpha1.ResolutionRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Status: v1alpha1.ResolutionRequestStatus{
+			Status: duckv1.Status{
+				Conditions: duckv1.Conditions{{
+					Type:   apis.ConditionSucceeded,
+					Status: corev1.ConditionTrue,
+				}},
+				Annotations: annotations,
+			},
+			ResolutionRequestStatusFields: v1alpha1.ResolutionRequestStatusFields{
+				Data: base64.StdEncoding.EncodeToString([]byte(content)),
+			},
+		},
+	}
+}
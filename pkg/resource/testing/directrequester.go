@@ -0,0 +1,35 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testing
+
+import (
+	"context"
+
+	"github.com/tektoncd/resolution/pkg/resolver/framework"
+	"github.com/tektoncd/resolution/pkg/resource/direct"
+)
+
+// DirectRequester runs real resolvers in-process instead of going
+// through ResolutionRequest objects. It is the requester from
+// pkg/resource/direct, which the resolver CLIs also use.
+type DirectRequester = direct.Requester
+
+// NewDirectRequester initializes the given resolvers and returns a
+// DirectRequester that routes requests to them by resolver name.
+func NewDirectRequester(ctx context.Context, resolvers ...framework.Resolver) (*DirectRequester, error) {
+	return direct.NewRequester(ctx, resolvers...)
+}
@@ -0,0 +1,46 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tektoncd/resolution/pkg/resolver/framework"
+	"github.com/tektoncd/resolution/pkg/resource"
+)
+
+func TestDirectRequester(t *testing.T) {
+	ctx := context.Background()
+	fakeResolver := &framework.FakeResolver{ForParam: map[string]*framework.FakeResolvedResource{
+		"bar": {Content: "some content"},
+	}}
+	requester, err := NewDirectRequester(ctx, fakeResolver)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resolved, err := requester.Submit(ctx, resource.ResolverName(framework.LabelValueFakeResolverType), resource.NewRequest("rr", "foo", map[string]string{
+		framework.FakeParamName: "bar",
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data, _ := resolved.Data(); string(data) != "some content" {
+		t.Errorf("expected data %q, got %q", "some content", string(data))
+	}
+}
@@ -0,0 +1,23 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package testing provides implementations of resource.Requester for
+// use in the tests of projects that request remote resources, so that
+// they don't need a cluster, CRDs or fake clientsets.
+//
+// FakeRequester returns canned responses that a test configures up
+// front. DirectRequester runs real resolvers in-process instead.
+package testing
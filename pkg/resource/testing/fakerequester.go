@@ -0,0 +1,178 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testing
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	resolutioncommon "github.com/tektoncd/resolution/pkg/common"
+	"github.com/tektoncd/resolution/pkg/resource"
+)
+
+// FakeResponse is a canned response that a FakeRequester returns for
+// requests to a given resolver with given params.
+type FakeResponse struct {
+	// Data is the content of the resolved resource.
+	Data []byte
+
+	// Annotations are returned alongside Data.
+	Annotations map[string]string
+
+	// Err, if set, is returned instead of a resolved resource.
+	Err error
+
+	// InProgressCycles is the number of times that Submit returns
+	// resolutioncommon.ErrorRequestInProgress for the request
+	// before it responds, mimicking a request that takes a few
+	// reconciles to resolve.
+	InProgressCycles int
+
+	// Latency is how long each call to Submit waits before
+	// returning, or until its context is done.
+	Latency time.Duration
+}
+
+// Submission records a single call to a FakeRequester.
+type Submission struct {
+	Resolver resource.ResolverName
+	Request  resource.Request
+}
+
+// FakeRequester is a resource.Requester that returns canned responses
+// configured with Respond. Requests it has no response for fail with
+// an error.
+type FakeRequester struct {
+	mu          sync.Mutex
+	responses   map[string]*fakeEntry
+	submissions []Submission
+}
+
+type fakeEntry struct {
+	response FakeResponse
+	cycles   int
+}
+
+var _ resource.BlockingRequester = &FakeRequester{}
+
+// NewFakeRequester returns a FakeRequester with no responses.
+func NewFakeRequester() *FakeRequester {
+	return &FakeRequester{
+		responses: map[string]*fakeEntry{},
+	}
+}
+
+// Respond configures the response to requests to resolver with exactly
+// the given params. It returns the FakeRequester so that calls can be
+// chained.
+func (f *FakeRequester) Respond(resolver resource.ResolverName, params map[string]string, response FakeResponse) *FakeRequester {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.responses[responseKey(resolver, params)] = &fakeEntry{response: response}
+	return f
+}
+
+// Submit records the request and returns the response configured for
+// it.
+func (f *FakeRequester) Submit(ctx context.Context, resolver resource.ResolverName, req resource.Request) (resource.ResolvedResource, error) {
+	f.mu.Lock()
+	f.submissions = append(f.submissions, Submission{Resolver: resolver, Request: req})
+	entry, ok := f.responses[responseKey(resolver, req.Params())]
+	var response FakeResponse
+	inProgress := false
+	if ok {
+		response = entry.response
+		if entry.cycles < response.InProgressCycles {
+			entry.cycles++
+			inProgress = true
+		}
+	}
+	f.mu.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("no response configured for resolver %q with params %v", resolver, req.Params())
+	}
+
+	if response.Latency > 0 {
+		select {
+		case <-time.After(response.Latency):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	if inProgress {
+		return nil, resolutioncommon.ErrorRequestInProgress
+	}
+	if response.Err != nil {
+		return nil, response.Err
+	}
+	return &ResolvedResource{
+		ResolvedData:        response.Data,
+		ResolvedAnnotations: response.Annotations,
+	}, nil
+}
+
+// SubmitAndWait calls Submit until the request is no longer in
+// progress or ctx is done.
+func (f *FakeRequester) SubmitAndWait(ctx context.Context, resolver resource.ResolverName, req resource.Request) (resource.ResolvedResource, error) {
+	for {
+		resolved, err := f.Submit(ctx, resolver, req)
+		if err != resolutioncommon.ErrorRequestInProgress {
+			return resolved, err
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Submissions returns every request made to the FakeRequester so far,
+// in the order they were made.
+func (f *FakeRequester) Submissions() []Submission {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Submission{}, f.submissions...)
+}
+
+// responseKey identifies the response for a resolver and set of params.
+func responseKey(resolver resource.ResolverName, params map[string]string) string {
+	// Hashing can't fail so the error is ignored.
+	key, _ := resource.GenerateDeterministicName(string(resolver), string(resolver), params)
+	return key
+}
+
+// ResolvedResource is a resource.ResolvedResource returned by a
+// FakeRequester.
+type ResolvedResource struct {
+	ResolvedData        []byte
+	ResolvedAnnotations map[string]string
+}
+
+var _ resource.ResolvedResource = &ResolvedResource{}
+
+// Data returns the ResolvedData field.
+func (r *ResolvedResource) Data() ([]byte, error) {
+	return r.ResolvedData, nil
+}
+
+// Annotations returns the ResolvedAnnotations field.
+func (r *ResolvedResource) Annotations() map[string]string {
+	return r.ResolvedAnnotations
+}
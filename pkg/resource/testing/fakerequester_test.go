@@ -0,0 +1,86 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testing
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	resolutioncommon "github.com/tektoncd/resolution/pkg/common"
+	"github.com/tektoncd/resolution/pkg/resource"
+)
+
+func TestFakeRequester(t *testing.T) {
+	params := map[string]string{"url": "https://example.com/repo.git"}
+	failure := errors.New("fake failure")
+	fake := NewFakeRequester().
+		Respond("git", params, FakeResponse{Data: []byte("some content"), InProgressCycles: 2}).
+		Respond("hub", params, FakeResponse{Err: failure})
+	req := resource.NewRequest("rr", "foo", params)
+
+	for i := 0; i < 2; i++ {
+		if _, err := fake.Submit(context.Background(), "git", req); err != resolutioncommon.ErrorRequestInProgress {
+			t.Fatalf("expected submission %d to be in progress, got %v", i, err)
+		}
+	}
+	resolved, err := fake.Submit(context.Background(), "git", req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data, _ := resolved.Data(); string(data) != "some content" {
+		t.Errorf("expected data %q, got %q", "some content", string(data))
+	}
+
+	if _, err := fake.Submit(context.Background(), "hub", req); err != failure {
+		t.Errorf("expected configured failure, got %v", err)
+	}
+	if _, err := fake.Submit(context.Background(), "bundles", req); err == nil {
+		t.Errorf("expected error for request without a response")
+	}
+	if n := len(fake.Submissions()); n != 5 {
+		t.Errorf("expected 5 submissions to be recorded, got %d", n)
+	}
+}
+
+func TestFakeRequesterSubmitAndWait(t *testing.T) {
+	params := map[string]string{"name": "task"}
+	fake := NewFakeRequester().Respond("hub", params, FakeResponse{Data: []byte("some content"), InProgressCycles: 3})
+
+	resolved, err := fake.SubmitAndWait(context.Background(), "hub", resource.NewRequest("rr", "foo", params))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data, _ := resolved.Data(); string(data) != "some content" {
+		t.Errorf("expected data %q, got %q", "some content", string(data))
+	}
+	if n := len(fake.Submissions()); n != 4 {
+		t.Errorf("expected 4 submissions, got %d", n)
+	}
+}
+
+func TestFakeRequesterLatency(t *testing.T) {
+	params := map[string]string{"name": "task"}
+	fake := NewFakeRequester().Respond("hub", params, FakeResponse{Latency: time.Minute})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := fake.Submit(ctx, "hub", resource.NewRequest("rr", "foo", params)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+}
@@ -0,0 +1,346 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/tektoncd/resolution/pkg/apis/resolution/v1alpha1"
+	rrclient "github.com/tektoncd/resolution/pkg/client/clientset/versioned"
+	rrinformers "github.com/tektoncd/resolution/pkg/client/informers/externalversions/resolution/v1alpha1"
+	resolutioncommon "github.com/tektoncd/resolution/pkg/common"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+// defaultBatchConcurrency is the number of requests that
+// SubmitAndWaitAll waits on at the same time.
+const defaultBatchConcurrency = 10
+
+// listerPollInterval is how often SubmitAndWait checks the requester's
+// lister for changes that it hasn't been told about by an informer.
+const listerPollInterval = time.Second
+
+// ErrorRequestDeleted is returned by SubmitAndWait when the
+// ResolutionRequest being waited on is deleted before it completes.
+var ErrorRequestDeleted = errors.New("resolution request was deleted before it completed")
+
+// errWatchClosed is used internally to signal that a watch ended
+// before the request being waited on completed.
+var errWatchClosed = errors.New("watch closed")
+
+var _ BlockingRequester = &CRDRequester{}
+
+// NewCRDRequesterWithInformer returns a CRDRequester that reads
+// ResolutionRequests from the given informer's lister and is told by
+// the informer when they change, so that SubmitAndWait returns as soon
+// as the informer sees a request complete. The informer must be
+// started by the caller.
+func NewCRDRequesterWithInformer(clientset rrclient.Interface, informer rrinformers.ResolutionRequestInformer) *CRDRequester {
+	r := NewCRDRequester(clientset, informer.Lister())
+	r.waiters = newResolutionWaiters()
+	informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    r.waiters.changed,
+		UpdateFunc: func(_, obj interface{}) { r.waiters.changed(obj) },
+		DeleteFunc: r.waiters.deleted,
+	})
+	return r
+}
+
+// SubmitAndWait submits a request in the same way as Submit but, rather
+// than returning ErrorRequestInProgress, waits for the ResolutionRequest
+// to complete. The resolved resource is returned if it succeeds. If it
+// fails then a *resolutioncommon.Error is returned with the reason
+// given by the ResolutionRequest's condition. If ctx is done first then
+// its error is returned, wrapped.
+//
+// Requests are read from the requester's lister rather than watched
+// individually, so any number of callers can wait at once without
+// adding load to the API server. Requesters made with
+// NewCRDRequesterWithInformer see changes straight away; others check
+// their lister every second.
+func (r *CRDRequester) SubmitAndWait(ctx context.Context, resolver ResolverName, req Request) (ResolvedResource, error) {
+	if sharedReq, ok := r.sharedRequestFor(resolver, req); ok {
+		if err := r.admit(ctx, resolver, req); err != nil {
+			return nil, err
+		}
+		resource, err := r.submitAndWait(ctx, resolver, sharedReq)
+		if isShareable(resource, err) || ctx.Err() != nil {
+			return resource, err
+		}
+	}
+	return r.submitAndWait(ctx, resolver, req)
+}
+
+func (r *CRDRequester) submitAndWait(ctx context.Context, resolver ResolverName, req Request) (ResolvedResource, error) {
+	// Start listening for changes before submitting so that none
+	// are missed in between.
+	waiter := r.waiters.add(req.Namespace(), req.Name())
+	defer r.waiters.remove(waiter)
+
+	resource, err := r.submit(ctx, resolver, req)
+	if !errors.Is(err, resolutioncommon.ErrorRequestInProgress) {
+		return resource, err
+	}
+	return r.waitForResolution(ctx, req, waiter)
+}
+
+// BatchResult is the outcome of a single request submitted with
+// SubmitAndWaitAll.
+type BatchResult struct {
+	Request  Request
+	Resource ResolvedResource
+	Err      error
+}
+
+// SubmitAndWaitAll submits many requests to the same resolver and
+// waits for all of them concurrently. The results are returned in the
+// same order as the requests. A request that fails doesn't stop the
+// others from being waited on.
+func (r *CRDRequester) SubmitAndWaitAll(ctx context.Context, resolver ResolverName, reqs []Request) []BatchResult {
+	results := make([]BatchResult, len(reqs))
+	slots := make(chan struct{}, defaultBatchConcurrency)
+	var wg sync.WaitGroup
+	for i, req := range reqs {
+		wg.Add(1)
+		go func(i int, req Request) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			resource, err := r.SubmitAndWait(ctx, resolver, req)
+			results[i] = BatchResult{Request: req, Resource: resource, Err: err}
+		}(i, req)
+	}
+	wg.Wait()
+	return results
+}
+
+// waitForResolution waits for a submitted ResolutionRequest to
+// complete, checking the requester's lister whenever waiter is told it
+// changed, or every listerPollInterval, until it does or ctx is done.
+func (r *CRDRequester) waitForResolution(ctx context.Context, req Request, waiter *resolutionWaiter) (ResolvedResource, error) {
+	key := fmt.Sprintf("%s/%s", req.Namespace(), req.Name())
+	ticker := time.NewTicker(listerPollInterval)
+	defer ticker.Stop()
+	for {
+		rr, err := r.lister.ResolutionRequests(req.Namespace()).Get(req.Name())
+		switch {
+		case err == nil:
+			if resource, err := resolutionOutcome(rr); err != resolutioncommon.ErrorRequestInProgress {
+				return resource, err
+			}
+		case !apierrors.IsNotFound(err):
+			return nil, fmt.Errorf("error getting resolution request %q: %w", key, err)
+		}
+		// A request that isn't found has either only just been
+		// created, so the lister hasn't caught up, or has been
+		// deleted, which the informer tells the waiter about.
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("error waiting for resolution request %q: %w", key, ctx.Err())
+		case <-waiter.deletedChan():
+			return nil, ErrorRequestDeleted
+		case <-waiter.changedChan():
+		case <-ticker.C:
+		}
+	}
+}
+
+// WaitForResolution watches an existing ResolutionRequest until it
+// completes or ctx is done. The result is the same as SubmitAndWait's,
+// which makes it useful for waiting on requests that were submitted by
+// someone else. Each call opens its own watch, so callers waiting on
+// many requests at once should use SubmitAndWait with a requester made
+// by NewCRDRequesterWithInformer instead.
+func WaitForResolution(ctx context.Context, clientset rrclient.Interface, namespace, name string) (ResolvedResource, error) {
+	client := clientset.ResolutionV1alpha1().ResolutionRequests(namespace)
+	key := fmt.Sprintf("%s/%s", namespace, name)
+	for {
+		w, err := client.Watch(ctx, metav1.ListOptions{
+			FieldSelector: fields.OneTermEqualSelector("metadata.name", name).String(),
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("error waiting for resolution request %q: %w", key, ctx.Err())
+			}
+			return nil, fmt.Errorf("error watching resolution request %q: %w", key, err)
+		}
+
+		// The request may have completed before the watch was
+		// started, in which case no more events will arrive for it.
+		rr, err := client.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			w.Stop()
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("error waiting for resolution request %q: %w", key, ctx.Err())
+			}
+			return nil, fmt.Errorf("error getting resolution request %q: %w", key, err)
+		}
+		if resource, err := resolutionOutcome(rr); err != resolutioncommon.ErrorRequestInProgress {
+			w.Stop()
+			return resource, err
+		}
+
+		resource, err := watchUntilDone(ctx, w, name)
+		w.Stop()
+		if err == errWatchClosed {
+			// The watch was closed by the server, so start
+			// another.
+			continue
+		}
+		if ctx.Err() != nil && err == ctx.Err() {
+			return nil, fmt.Errorf("error waiting for resolution request %q: %w", key, err)
+		}
+		return resource, err
+	}
+}
+
+// watchUntilDone reads events from w until the named ResolutionRequest
+// completes, is deleted, or ctx is done. errWatchClosed is returned if
+// the watch is closed before any of those happen.
+func watchUntilDone(ctx context.Context, w watch.Interface, name string) (ResolvedResource, error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case event, ok := <-w.ResultChan():
+			if !ok {
+				return nil, errWatchClosed
+			}
+			rr, ok := event.Object.(*v1alpha1.ResolutionRequest)
+			if !ok || rr.Name != name {
+				continue
+			}
+			switch event.Type {
+			case watch.Deleted:
+				return nil, ErrorRequestDeleted
+			case watch.Added, watch.Modified:
+				if resource, err := resolutionOutcome(rr); err != resolutioncommon.ErrorRequestInProgress {
+					return resource, err
+				}
+			}
+		}
+	}
+}
+
+// resolutionWaiters tells callers of SubmitAndWait when the informer
+// sees the ResolutionRequests they are waiting on change.
+type resolutionWaiters struct {
+	mu      sync.Mutex
+	waiters map[string]map[*resolutionWaiter]struct{}
+}
+
+// resolutionWaiter is a single caller waiting on a ResolutionRequest.
+// A nil *resolutionWaiter is never told about changes.
+type resolutionWaiter struct {
+	key     string
+	changed chan struct{}
+	deleted chan struct{}
+	once    sync.Once
+}
+
+func newResolutionWaiters() *resolutionWaiters {
+	return &resolutionWaiters{waiters: map[string]map[*resolutionWaiter]struct{}{}}
+}
+
+// add starts listening for changes to the named request. It returns
+// nil if w is nil.
+func (w *resolutionWaiters) add(namespace, name string) *resolutionWaiter {
+	if w == nil {
+		return nil
+	}
+	waiter := &resolutionWaiter{
+		key:     fmt.Sprintf("%s/%s", namespace, name),
+		changed: make(chan struct{}, 1),
+		deleted: make(chan struct{}),
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.waiters[waiter.key] == nil {
+		w.waiters[waiter.key] = map[*resolutionWaiter]struct{}{}
+	}
+	w.waiters[waiter.key][waiter] = struct{}{}
+	return waiter
+}
+
+// remove stops listening for changes for waiter.
+func (w *resolutionWaiters) remove(waiter *resolutionWaiter) {
+	if w == nil || waiter == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.waiters[waiter.key], waiter)
+	if len(w.waiters[waiter.key]) == 0 {
+		delete(w.waiters, waiter.key)
+	}
+}
+
+// changed wakes up everyone waiting on obj.
+func (w *resolutionWaiters) changed(obj interface{}) {
+	w.each(obj, func(waiter *resolutionWaiter) {
+		select {
+		case waiter.changed <- struct{}{}:
+		default:
+			// Already woken up and not yet checked.
+		}
+	})
+}
+
+// deleted tells everyone waiting on obj that it is gone.
+func (w *resolutionWaiters) deleted(obj interface{}) {
+	w.each(obj, func(waiter *resolutionWaiter) {
+		waiter.once.Do(func() { close(waiter.deleted) })
+	})
+}
+
+func (w *resolutionWaiters) each(obj interface{}, f func(*resolutionWaiter)) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for waiter := range w.waiters[key] {
+		f(waiter)
+	}
+}
+
+// changedChan returns a channel that receives when the request being
+// waited on changes, or nil if waiter is nil.
+func (waiter *resolutionWaiter) changedChan() <-chan struct{} {
+	if waiter == nil {
+		return nil
+	}
+	return waiter.changed
+}
+
+// deletedChan returns a channel that is closed when the request being
+// waited on is deleted, or nil if waiter is nil.
+func (waiter *resolutionWaiter) deletedChan() <-chan struct{} {
+	if waiter == nil {
+		return nil
+	}
+	return waiter.deleted
+}
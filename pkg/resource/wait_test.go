@@ -0,0 +1,274 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resource
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/tektoncd/resolution/pkg/apis/resolution/v1alpha1"
+	"github.com/tektoncd/resolution/pkg/client/clientset/versioned/fake"
+	"github.com/tektoncd/resolution/pkg/client/informers/externalversions"
+	rrlisters "github.com/tektoncd/resolution/pkg/client/listers/resolution/v1alpha1"
+	resolutioncommon "github.com/tektoncd/resolution/pkg/common"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+)
+
+func TestSubmitAndWait(t *testing.T) {
+	for _, tc := range []struct {
+		name           string
+		complete       func(*v1alpha1.ResolutionRequest)
+		expectedData   string
+		expectedReason string
+	}{{
+		name: "succeeded",
+		complete: func(rr *v1alpha1.ResolutionRequest) {
+			rr.Status.Data = base64.StdEncoding.EncodeToString([]byte("some content"))
+			rr.Status.MarkSucceeded()
+		},
+		expectedData: "some content",
+	}, {
+		name: "failed",
+		complete: func(rr *v1alpha1.ResolutionRequest) {
+			rr.Status.MarkFailed(resolutioncommon.ReasonResolutionTimedOut, "took too long")
+		},
+		expectedReason: resolutioncommon.ReasonResolutionTimedOut,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			requester, clientset := newTestRequester(ctx, t)
+			req := NewRequest("rr", "foo", map[string]string{"foo": "bar"})
+
+			go completeWhenCreated(ctx, t, clientset, req, tc.complete)
+
+			resource, err := requester.SubmitAndWait(ctx, "fake", req)
+			if tc.expectedReason != "" {
+				var resolutionErr *resolutioncommon.Error
+				if !errors.As(err, &resolutionErr) {
+					t.Fatalf("expected error with reason %q, got %v", tc.expectedReason, err)
+				}
+				if resolutionErr.Reason != tc.expectedReason {
+					t.Errorf("expected reason %q, got %q", tc.expectedReason, resolutionErr.Reason)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			data, err := resource.Data()
+			if err != nil {
+				t.Fatalf("unexpected error getting data: %v", err)
+			}
+			if string(data) != tc.expectedData {
+				t.Errorf("expected data %q, got %q", tc.expectedData, string(data))
+			}
+		})
+	}
+}
+
+func TestSubmitAndWaitContextDone(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	requester, _ := newTestRequester(ctx, t)
+
+	_, err := requester.SubmitAndWait(ctx, "fake", NewRequest("rr", "foo", nil))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded error, got %v", err)
+	}
+}
+
+func TestSubmitAndWaitDeleted(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	requester, clientset := newTestRequester(ctx, t)
+	req := NewRequest("rr", "foo", nil)
+
+	go func() {
+		if err := waitForCreation(ctx, clientset, req); err != nil {
+			t.Errorf("waiting for request to be created: %v", err)
+			return
+		}
+		if err := clientset.ResolutionV1alpha1().ResolutionRequests(req.Namespace()).Delete(ctx, req.Name(), metav1.DeleteOptions{}); err != nil {
+			t.Errorf("deleting request: %v", err)
+		}
+	}()
+
+	if _, err := requester.SubmitAndWait(ctx, "fake", req); !errors.Is(err, ErrorRequestDeleted) {
+		t.Fatalf("expected ErrorRequestDeleted, got %v", err)
+	}
+}
+
+func TestSubmitAndWaitAlreadySubmitted(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	rr := &v1alpha1.ResolutionRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "rr", Namespace: "foo"},
+	}
+	rr.Status.Data = base64.StdEncoding.EncodeToString([]byte("some content"))
+	rr.Status.MarkSucceeded()
+	clientset := fake.NewSimpleClientset(rr)
+
+	// The lister doesn't have the request yet, as if its informer
+	// hadn't caught up, so creating it fails as it already exists.
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	requester := NewCRDRequester(clientset, rrlisters.NewResolutionRequestLister(indexer))
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		if err := indexer.Add(rr); err != nil {
+			t.Errorf("adding request to lister: %v", err)
+		}
+	}()
+
+	resource, err := requester.SubmitAndWait(ctx, "fake", NewRequest("rr", "foo", nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := resource.Data()
+	if err != nil {
+		t.Fatalf("unexpected error getting data: %v", err)
+	}
+	if string(data) != "some content" {
+		t.Errorf("expected data %q, got %q", "some content", string(data))
+	}
+	for _, action := range clientset.Actions() {
+		if action.GetVerb() == "watch" {
+			t.Errorf("expected request to be waited on without a watch")
+		}
+	}
+}
+
+func TestSubmitAndWaitAll(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	requester, clientset := newTestRequester(ctx, t)
+
+	var reqs []Request
+	for i := 0; i < 2*defaultBatchConcurrency; i++ {
+		req := NewRequest(fmt.Sprintf("rr-%d", i), "foo", nil)
+		reqs = append(reqs, req)
+		content := fmt.Sprintf("content %d", i)
+		go completeWhenCreated(ctx, t, clientset, req, func(rr *v1alpha1.ResolutionRequest) {
+			rr.Status.Data = base64.StdEncoding.EncodeToString([]byte(content))
+			rr.Status.MarkSucceeded()
+		})
+	}
+
+	results := requester.SubmitAndWaitAll(ctx, "fake", reqs)
+	if len(results) != len(reqs) {
+		t.Fatalf("expected %d results, got %d", len(reqs), len(results))
+	}
+	for i, result := range results {
+		if result.Request != reqs[i] {
+			t.Errorf("result %d is for request %q", i, result.Request.Name())
+		}
+		if result.Err != nil {
+			t.Errorf("unexpected error for %q: %v", result.Request.Name(), result.Err)
+			continue
+		}
+		data, err := result.Resource.Data()
+		if err != nil {
+			t.Fatalf("unexpected error getting data: %v", err)
+		}
+		if expected := fmt.Sprintf("content %d", i); string(data) != expected {
+			t.Errorf("expected data %q for %q, got %q", expected, result.Request.Name(), string(data))
+		}
+	}
+}
+
+func TestWaitForResolutionAlreadyDone(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	rr := &v1alpha1.ResolutionRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "rr", Namespace: "foo"},
+	}
+	rr.Status.Data = base64.StdEncoding.EncodeToString([]byte("some content"))
+	rr.Status.MarkSucceeded()
+	clientset := fake.NewSimpleClientset(rr)
+
+	resource, err := WaitForResolution(ctx, clientset, "foo", "rr")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := resource.Data()
+	if err != nil {
+		t.Fatalf("unexpected error getting data: %v", err)
+	}
+	if string(data) != "some content" {
+		t.Errorf("expected data %q, got %q", "some content", string(data))
+	}
+}
+
+// newTestRequester returns a CRDRequester backed by a fake clientset
+// and an informer over it, which is stopped when ctx is done.
+func newTestRequester(ctx context.Context, t *testing.T) (*CRDRequester, *fake.Clientset) {
+	t.Helper()
+	clientset := fake.NewSimpleClientset()
+	factory := externalversions.NewSharedInformerFactory(clientset, 0)
+	requester := NewCRDRequesterWithInformer(clientset, factory.Resolution().V1alpha1().ResolutionRequests())
+	factory.Start(ctx.Done())
+	for typ, synced := range factory.WaitForCacheSync(ctx.Done()) {
+		if !synced {
+			t.Fatalf("error syncing informer for %v", typ)
+		}
+	}
+	return requester, clientset
+}
+
+// waitForCreation waits for the ResolutionRequest for req to exist.
+func waitForCreation(ctx context.Context, clientset *fake.Clientset, req Request) error {
+	return wait.PollImmediateUntil(10*time.Millisecond, func() (bool, error) {
+		_, err := clientset.ResolutionV1alpha1().ResolutionRequests(req.Namespace()).Get(ctx, req.Name(), metav1.GetOptions{})
+		return err == nil, nil
+	}, ctx.Done())
+}
+
+// completeWhenCreated stands in for a resolver, waiting for the
+// ResolutionRequest for req to be created and then completing it with
+// the given func.
+func completeWhenCreated(ctx context.Context, t *testing.T, clientset *fake.Clientset, req Request, complete func(*v1alpha1.ResolutionRequest)) {
+	t.Helper()
+	if err := waitForCreation(ctx, clientset, req); err != nil {
+		t.Errorf("waiting for %q to be created: %v", req.Name(), err)
+		return
+	}
+	client := clientset.ResolutionV1alpha1().ResolutionRequests(req.Namespace())
+	rr, err := client.Get(ctx, req.Name(), metav1.GetOptions{})
+	if err != nil {
+		t.Errorf("getting %q: %v", req.Name(), err)
+		return
+	}
+	rr.Status.Status = duckv1.Status{
+		Conditions: duckv1.Conditions{{
+			Type:   apis.ConditionSucceeded,
+			Status: corev1.ConditionUnknown,
+		}},
+	}
+	complete(rr)
+	if _, err := client.UpdateStatus(ctx, rr, metav1.UpdateOptions{}); err != nil {
+		t.Errorf("completing %q: %v", req.Name(), err)
+	}
+}
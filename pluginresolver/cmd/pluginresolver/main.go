@@ -0,0 +1,48 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"os"
+
+	"github.com/tektoncd/resolution/pkg/apis/resolution/v1alpha1"
+	"github.com/tektoncd/resolution/pkg/resolver/framework"
+	"github.com/tektoncd/resolution/pkg/resolver/plugin"
+	filteredinformerfactory "knative.dev/pkg/client/injection/kube/informers/factory/filtered"
+	"knative.dev/pkg/injection/sharedmain"
+	"knative.dev/pkg/signals"
+)
+
+func main() {
+	resolver := plugin.Resolver{
+		Name: os.Getenv("RESOLVER_NAME"),
+		Type: os.Getenv("RESOLVER_TYPE"),
+		URL:  os.Getenv("PLUGIN_URL"),
+	}
+	if resolver.Name == "" {
+		resolver.Name = resolver.Type
+	}
+
+	var r framework.Resolver = &resolver
+	if configName := os.Getenv("RESOLVER_CONFIG_NAME"); configName != "" {
+		r = &plugin.ConfigurableResolver{Resolver: resolver, ConfigName: configName}
+	}
+	ctx := filteredinformerfactory.WithSelectors(signals.NewContext(), v1alpha1.ManagedByLabelKey)
+	sharedmain.MainWithContext(ctx, "controller",
+		framework.NewController(ctx, r),
+	)
+}
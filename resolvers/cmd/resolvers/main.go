@@ -0,0 +1,47 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// resolvers runs the git, bundle and hub resolvers together in a
+// single process so that they share one set of informers and caches.
+// Each of them can be switched on and off while running with the
+// resolvers-feature-flags ConfigMap.
+package main
+
+import (
+	"os"
+
+	"github.com/tektoncd/resolution/bundleresolver/pkg/bundle"
+	"github.com/tektoncd/resolution/gitresolver/pkg/git"
+	"github.com/tektoncd/resolution/hubresolver/pkg/hub"
+	"github.com/tektoncd/resolution/internal/cmdutil"
+	"github.com/tektoncd/resolution/pkg/apis/resolution/v1alpha1"
+	"github.com/tektoncd/resolution/pkg/resolver/framework"
+	filteredinformerfactory "knative.dev/pkg/client/injection/kube/informers/factory/filtered"
+	"knative.dev/pkg/injection/sharedmain"
+	"knative.dev/pkg/signals"
+)
+
+func main() {
+	ctx := filteredinformerfactory.WithSelectors(signals.NewContext(), v1alpha1.ManagedByLabelKey)
+	featureFlags := framework.WithFeatureFlags(framework.FeatureFlagsConfigMapName)
+	contentPolicy := framework.WithContentPolicyFromConfigMap(framework.ContentPolicyConfigMapName)
+
+	sharedmain.MainWithContext(ctx, "controller",
+		framework.NewController(ctx, &git.Resolver{}, featureFlags, contentPolicy),
+		framework.NewController(ctx, &bundle.Resolver{}, featureFlags, contentPolicy),
+		framework.NewController(ctx, &hub.Resolver{HubURL: cmdutil.HubURL(os.Getenv("HUB_API"))}, featureFlags, contentPolicy),
+	)
+}
@@ -0,0 +1,29 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fixtures starts in-process stand-ins for the services that
+// resolvers fetch from, so that resolvers can be tested end-to-end
+// without a network connection:
+//
+//   - NewGitServer serves git repositories over the smart HTTP
+//     protocol, built from a declarative description of their commits.
+//   - NewRegistry serves an OCI registry preloaded with Tekton bundles
+//     built from YAML.
+//   - NewHub serves the parts of the Tekton Hub API that the hub
+//     resolver uses.
+//
+// Every server is shut down when the test that started it finishes.
+package fixtures
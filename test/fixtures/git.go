@@ -0,0 +1,460 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fixtures
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/pktline"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/server"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// DefaultBranch is the branch that GitCommits are made on if they
+// don't name one, and that HEAD points at in every GitRepo.
+const DefaultBranch = "master"
+
+// commitTime is the time every commit is made at, so that the same
+// GitRepo always has the same commit hashes.
+var commitTime = time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// GitRepo describes a repository served by a GitServer.
+type GitRepo struct {
+	// Name is the path the repository is served at, relative to the
+	// server's URL. It's also served with a .git suffix.
+	Name string
+
+	// Commits are made to the repository in order.
+	Commits []GitCommit
+}
+
+// GitCommit describes a commit in a GitRepo.
+type GitCommit struct {
+	// Branch is the branch the commit is made on. A branch that
+	// doesn't exist yet is started from the latest commit on
+	// DefaultBranch. Defaults to DefaultBranch.
+	Branch string
+
+	// Files maps the paths of files to write to their content. Files
+	// from earlier commits on the branch are kept.
+	Files map[string]string
+
+	// Tag, if set, is the name of an annotated tag to create for the
+	// commit.
+	Tag string
+
+	// Message is the commit message. Defaults to "commit <n>".
+	Message string
+
+	// Sign, if set, is given the encoded commit without a signature
+	// and returns the armored signature to add to it, e.g. from
+	// GPGSigner or SSHSigner.
+	Sign func(payload []byte) (string, error)
+
+	// LFSFiles maps the paths of files stored with Git LFS to their
+	// content. The commit has an LFS pointer at each path and the
+	// GitServer serves the content from the repo's LFS endpoint.
+	LFSFiles map[string]string
+
+	// Submodules maps paths to the names of repos to add as
+	// submodules there, at the latest commit on their DefaultBranch.
+	// The repos must be served by the same GitServer and come before
+	// this one. Their URLs are relative to this repo's.
+	Submodules map[string]string
+}
+
+// GitServer serves GitRepos over git's smart HTTP protocol. Only
+// fetching is supported.
+type GitServer struct {
+	// URL is the base URL of the server.
+	URL string
+
+	hashes map[string][]string
+}
+
+// NewGitServer builds repos in memory and starts a GitServer serving
+// them.
+func NewGitServer(t *testing.T, repos ...GitRepo) *GitServer {
+	t.Helper()
+	loader := server.MapLoader{}
+	s := &GitServer{hashes: map[string][]string{}}
+	built := map[string]*git.Repository{}
+	lfsObjects := map[string]string{}
+	for _, repo := range repos {
+		repository, hashes, err := buildRepo(repo, built, lfsObjects)
+		if err != nil {
+			t.Fatalf("error building git repo %q: %v", repo.Name, err)
+		}
+		loader[repoEndpoint(repo.Name).String()] = repository.Storer
+		s.hashes[repo.Name] = hashes
+		built[repo.Name] = repository
+	}
+	svr := httptest.NewServer(&gitHandler{transport: server.NewServer(loader), lfsObjects: lfsObjects})
+	t.Cleanup(svr.Close)
+	s.URL = svr.URL
+	return s
+}
+
+// RepoURL returns the URL to clone the named repo from.
+func (s *GitServer) RepoURL(name string) string {
+	return s.URL + "/" + name + ".git"
+}
+
+// CommitHash returns the hash of the nth commit, counting from 0, made
+// to the named repo.
+func (s *GitServer) CommitHash(name string, n int) string {
+	return s.hashes[name][n]
+}
+
+// LFSPointer returns the pointer Git LFS stores in a repository in place
+// of content.
+func LFSPointer(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return fmt.Sprintf("version https://git-lfs.github.com/spec/v1\noid sha256:%s\nsize %d\n", hex.EncodeToString(sum[:]), len(content))
+}
+
+// buildRepo makes repo's commits in an in-memory repository, returning
+// the repository and the hash of each commit. Submodules are added from
+// the repositories already built and the content of LFS files is added
+// to lfsObjects, keyed by its sha256 digest.
+func buildRepo(repo GitRepo, built map[string]*git.Repository, lfsObjects map[string]string) (*git.Repository, []string, error) {
+	repository, err := git.Init(memory.NewStorage(), memfs.New())
+	if err != nil {
+		return nil, nil, err
+	}
+	worktree, err := repository.Worktree()
+	if err != nil {
+		return nil, nil, err
+	}
+	signature := &object.Signature{Name: "Tekton", Email: "tekton@example.com", When: commitTime}
+
+	var hashes []string
+	for n, commit := range repo.Commits {
+		branch := commit.Branch
+		if branch == "" {
+			branch = DefaultBranch
+		}
+		if err := checkoutBranch(repository, worktree, branch); err != nil {
+			return nil, nil, fmt.Errorf("commit %d: %w", n, err)
+		}
+
+		files := map[string]string{}
+		for path, content := range commit.Files {
+			files[path] = content
+		}
+		for path, content := range commit.LFSFiles {
+			files[path] = LFSPointer(content)
+			sum := sha256.Sum256([]byte(content))
+			lfsObjects[hex.EncodeToString(sum[:])] = content
+		}
+		if len(commit.Submodules) > 0 {
+			files[".gitmodules"] = gitmodules(commit.Submodules)
+		}
+		paths := make([]string, 0, len(files))
+		for path := range files {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			if err := util.WriteFile(worktree.Filesystem, path, []byte(files[path]), 0644); err != nil {
+				return nil, nil, fmt.Errorf("commit %d: error writing %q: %w", n, path, err)
+			}
+			if _, err := worktree.Add(path); err != nil {
+				return nil, nil, fmt.Errorf("commit %d: error adding %q: %w", n, path, err)
+			}
+		}
+		if err := addSubmodules(repository, commit.Submodules, built); err != nil {
+			return nil, nil, fmt.Errorf("commit %d: %w", n, err)
+		}
+
+		message := commit.Message
+		if message == "" {
+			message = fmt.Sprintf("commit %d", n)
+		}
+		hash, err := worktree.Commit(message, &git.CommitOptions{Author: signature})
+		if err != nil {
+			return nil, nil, fmt.Errorf("commit %d: %w", n, err)
+		}
+		if commit.Sign != nil {
+			if hash, err = signCommit(repository, branch, hash, commit.Sign); err != nil {
+				return nil, nil, fmt.Errorf("commit %d: error signing: %w", n, err)
+			}
+		}
+		if commit.Tag != "" {
+			if _, err := repository.CreateTag(commit.Tag, hash, &git.CreateTagOptions{Message: commit.Tag, Tagger: signature}); err != nil {
+				return nil, nil, fmt.Errorf("commit %d: error creating tag %q: %w", n, commit.Tag, err)
+			}
+		}
+		hashes = append(hashes, hash.String())
+	}
+
+	// Leave HEAD pointing at the default branch whichever branch the
+	// last commit was made on.
+	head := plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.NewBranchReferenceName(DefaultBranch))
+	if err := repository.Storer.SetReference(head); err != nil {
+		return nil, nil, err
+	}
+	return repository, hashes, nil
+}
+
+// gitmodules returns the .gitmodules file for submodules at the given
+// paths of the named repos.
+func gitmodules(submodules map[string]string) string {
+	paths := make([]string, 0, len(submodules))
+	for path := range submodules {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	b := &strings.Builder{}
+	for _, path := range paths {
+		fmt.Fprintf(b, "[submodule %q]\n\tpath = %s\n\turl = ../%s.git\n", path, path, submodules[path])
+	}
+	return b.String()
+}
+
+// addSubmodules stages a link at each path to the latest commit on
+// DefaultBranch of the named repo.
+func addSubmodules(repository *git.Repository, submodules map[string]string, built map[string]*git.Repository) error {
+	if len(submodules) == 0 {
+		return nil
+	}
+	idx, err := repository.Storer.Index()
+	if err != nil {
+		return err
+	}
+	for path, name := range submodules {
+		submodule, ok := built[name]
+		if !ok {
+			return fmt.Errorf("submodule %q: repo %q hasn't been built", path, name)
+		}
+		ref, err := submodule.Reference(plumbing.NewBranchReferenceName(DefaultBranch), false)
+		if err != nil {
+			return fmt.Errorf("submodule %q: %w", path, err)
+		}
+		entry, err := idx.Entry(path)
+		if err != nil {
+			entry = idx.Add(path)
+		}
+		entry.Hash = ref.Hash()
+		entry.Mode = filemode.Submodule
+	}
+	return repository.Storer.SetIndex(idx)
+}
+
+// signCommit replaces the commit at the tip of branch with a copy
+// signed by sign, returning the hash of the signed commit.
+func signCommit(repository *git.Repository, branch string, hash plumbing.Hash, sign func([]byte) (string, error)) (plumbing.Hash, error) {
+	commit, err := repository.CommitObject(hash)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	unsigned := &plumbing.MemoryObject{}
+	if err := commit.EncodeWithoutSignature(unsigned); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	reader, err := unsigned.Reader()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	payload, err := io.ReadAll(reader)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if commit.PGPSignature, err = sign(payload); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	signed := repository.Storer.NewEncodedObject()
+	if err := commit.Encode(signed); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	signedHash, err := repository.Storer.SetEncodedObject(signed)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	ref := plumbing.NewHashReference(plumbing.NewBranchReferenceName(branch), signedHash)
+	return signedHash, repository.Storer.SetReference(ref)
+}
+
+// checkoutBranch checks out branch, creating it from the latest commit
+// on DefaultBranch if it doesn't exist yet.
+func checkoutBranch(repository *git.Repository, worktree *git.Worktree, branch string) error {
+	name := plumbing.NewBranchReferenceName(branch)
+	if _, err := repository.Reference(name, false); err == nil {
+		// Forced, because go-git treats submodules, which are never
+		// checked out here, as changes.
+		return worktree.Checkout(&git.CheckoutOptions{Branch: name, Force: true})
+	}
+	head, err := repository.Reference(plumbing.NewBranchReferenceName(DefaultBranch), false)
+	if err != nil {
+		if branch == DefaultBranch {
+			// The first commit, which creates the default branch.
+			return nil
+		}
+		return fmt.Errorf("can't start branch %q before %q has any commits", branch, DefaultBranch)
+	}
+	return worktree.Checkout(&git.CheckoutOptions{Branch: name, Hash: head.Hash(), Create: true, Force: true})
+}
+
+func repoEndpoint(name string) *transport.Endpoint {
+	return &transport.Endpoint{Protocol: "file", Path: "/" + strings.TrimSuffix(name, ".git")}
+}
+
+// gitHandler serves the upload-pack half of git's smart HTTP protocol,
+// which is all that clones and fetches need.
+type gitHandler struct {
+	transport transport.Transport
+
+	// lfsObjects maps the sha256 digests of objects served from the
+	// LFS endpoints of every repo to their content.
+	lfsObjects map[string]string
+}
+
+func (h *gitHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch {
+	case req.Method == http.MethodGet && strings.HasSuffix(req.URL.Path, "/info/refs"):
+		if service := req.URL.Query().Get("service"); service != transport.UploadPackServiceName {
+			http.Error(w, fmt.Sprintf("service %q is not supported", service), http.StatusForbidden)
+			return
+		}
+		h.advertiseRefs(w, req, strings.TrimSuffix(req.URL.Path, "/info/refs"))
+	case req.Method == http.MethodPost && strings.HasSuffix(req.URL.Path, "/"+transport.UploadPackServiceName):
+		h.uploadPack(w, req, strings.TrimSuffix(req.URL.Path, "/"+transport.UploadPackServiceName))
+	case req.Method == http.MethodPost && strings.HasSuffix(req.URL.Path, "/info/lfs/objects/batch"):
+		h.lfsBatch(w, req)
+	case req.Method == http.MethodGet && strings.HasPrefix(req.URL.Path, lfsObjectsPath):
+		content, ok := h.lfsObjects[strings.TrimPrefix(req.URL.Path, lfsObjectsPath)]
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+		_, _ = io.WriteString(w, content)
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+func (h *gitHandler) advertiseRefs(w http.ResponseWriter, req *http.Request, repo string) {
+	session, ok := h.session(w, repo)
+	if !ok {
+		return
+	}
+	defer session.Close()
+	refs, err := session.AdvertisedReferencesContext(req.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	refs.Prefix = [][]byte{[]byte("# service=" + transport.UploadPackServiceName), pktline.Flush}
+	w.Header().Set("Content-Type", "application/x-"+transport.UploadPackServiceName+"-advertisement")
+	w.Header().Set("Cache-Control", "no-cache")
+	_ = refs.Encode(w)
+}
+
+func (h *gitHandler) uploadPack(w http.ResponseWriter, req *http.Request, repo string) {
+	session, ok := h.session(w, repo)
+	if !ok {
+		return
+	}
+	defer session.Close()
+	uploadReq := packp.NewUploadPackRequest()
+	if err := uploadReq.Decode(req.Body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	resp, err := session.UploadPack(req.Context(), uploadReq)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer resp.Close()
+	w.Header().Set("Content-Type", "application/x-"+transport.UploadPackServiceName+"-result")
+	w.Header().Set("Cache-Control", "no-cache")
+	_ = resp.Encode(w)
+}
+
+// session starts an upload-pack session for repo, responding with an
+// error if it can't be.
+func (h *gitHandler) session(w http.ResponseWriter, repo string) (transport.UploadPackSession, bool) {
+	session, err := h.transport.NewUploadPackSession(repoEndpoint(strings.TrimPrefix(repo, "/")), nil)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if err == transport.ErrRepositoryNotFound {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return nil, false
+	}
+	return session, true
+}
+
+// lfsObjectsPath is the path LFS objects are downloaded from, followed
+// by their sha256 digest.
+const lfsObjectsPath = "/lfs/objects/"
+
+// lfsObject is an object in a request to or response from the Git LFS
+// batch API.
+type lfsObject struct {
+	OID     string                 `json:"oid"`
+	Size    int64                  `json:"size"`
+	Actions map[string]interface{} `json:"actions,omitempty"`
+	Error   map[string]interface{} `json:"error,omitempty"`
+}
+
+// lfsBatch answers requests to download objects with the Git LFS batch
+// API by pointing at lfsObjectsPath.
+func (h *gitHandler) lfsBatch(w http.ResponseWriter, req *http.Request) {
+	batch := struct {
+		Operation string      `json:"operation"`
+		Objects   []lfsObject `json:"objects"`
+	}{}
+	if err := json.NewDecoder(req.Body).Decode(&batch); err != nil || batch.Operation != "download" {
+		http.Error(w, "only download batches are supported", http.StatusBadRequest)
+		return
+	}
+	objects := []lfsObject{}
+	for _, object := range batch.Objects {
+		if _, ok := h.lfsObjects[object.OID]; ok {
+			object.Actions = map[string]interface{}{
+				"download": map[string]interface{}{"href": "http://" + req.Host + lfsObjectsPath + object.OID},
+			}
+		} else {
+			object.Error = map[string]interface{}{"code": http.StatusNotFound, "message": "Object does not exist"}
+		}
+		objects = append(objects, object)
+	}
+	w.Header().Set("Content-Type", "application/vnd.git-lfs+json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"transfer": "basic", "objects": objects})
+}
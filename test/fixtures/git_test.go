@@ -0,0 +1,145 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fixtures
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+func TestGitServer(t *testing.T) {
+	svr := NewGitServer(t, GitRepo{
+		Name: "catalog",
+		Commits: []GitCommit{{
+			Files: map[string]string{"task/foo.yaml": "first"},
+		}, {
+			Branch: "other",
+			Files:  map[string]string{"task/foo.yaml": "on other"},
+		}, {
+			Files: map[string]string{"task/foo.yaml": "second"},
+			Tag:   "v1",
+		}},
+	})
+
+	ctx := context.Background()
+	filesystem := memfs.New()
+	repository, err := git.CloneContext(ctx, memory.NewStorage(), filesystem, &git.CloneOptions{URL: svr.RepoURL("catalog")})
+	if err != nil {
+		t.Fatalf("error cloning: %v", err)
+	}
+	checkFile(t, filesystem, "task/foo.yaml", "second")
+
+	if err := repository.FetchContext(ctx, &git.FetchOptions{
+		RefSpecs: []config.RefSpec{"+refs/heads/other:refs/remotes/other"},
+	}); err != nil {
+		t.Fatalf("error fetching branch: %v", err)
+	}
+	for revision, expected := range map[string]string{
+		"other":                      "on other",
+		"v1":                         "second",
+		svr.CommitHash("catalog", 0): "first",
+	} {
+		hash, err := repository.ResolveRevision(plumbing.Revision(revision))
+		if err != nil {
+			t.Fatalf("error resolving %q: %v", revision, err)
+		}
+		worktree, err := repository.Worktree()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := worktree.Checkout(&git.CheckoutOptions{Hash: *hash}); err != nil {
+			t.Fatalf("error checking out %q: %v", revision, err)
+		}
+		checkFile(t, filesystem, "task/foo.yaml", expected)
+	}
+}
+
+func TestGitServerUnknownRepo(t *testing.T) {
+	svr := NewGitServer(t)
+	_, err := git.CloneContext(context.Background(), memory.NewStorage(), memfs.New(), &git.CloneOptions{URL: svr.RepoURL("missing")})
+	if err == nil {
+		t.Fatalf("expected error cloning repo that doesn't exist")
+	}
+}
+
+func TestGitServerSubmodulesAndLFS(t *testing.T) {
+	svr := NewGitServer(t, GitRepo{
+		Name: "tasks",
+		Commits: []GitCommit{{
+			Files: map[string]string{"foo.yaml": "in submodule"},
+		}},
+	}, GitRepo{
+		Name: "catalog",
+		Commits: []GitCommit{{
+			LFSFiles:   map[string]string{"big.yaml": "in LFS"},
+			Submodules: map[string]string{"vendor/tasks": "tasks"},
+		}},
+	})
+
+	filesystem := memfs.New()
+	repository, err := git.CloneContext(context.Background(), memory.NewStorage(), filesystem, &git.CloneOptions{URL: svr.RepoURL("catalog")})
+	if err != nil {
+		t.Fatalf("error cloning: %v", err)
+	}
+	checkFile(t, filesystem, "big.yaml", LFSPointer("in LFS"))
+
+	worktree, err := repository.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	submodule, err := worktree.Submodule("vendor/tasks")
+	if err != nil {
+		t.Fatalf("error getting submodule: %v", err)
+	}
+	if err := submodule.Update(&git.SubmoduleUpdateOptions{Init: true}); err != nil {
+		t.Fatalf("error updating submodule: %v", err)
+	}
+	checkFile(t, filesystem, "vendor/tasks/foo.yaml", "in submodule")
+
+	sum := sha256.Sum256([]byte("in LFS"))
+	resp, err := http.Get(svr.URL + lfsObjectsPath + hex.EncodeToString(sum[:]))
+	if err != nil {
+		t.Fatalf("error downloading LFS object: %v", err)
+	}
+	defer resp.Body.Close()
+	if content, _ := io.ReadAll(resp.Body); string(content) != "in LFS" {
+		t.Errorf("expected LFS object to contain %q, got %q", "in LFS", content)
+	}
+}
+
+func checkFile(t *testing.T, filesystem billy.Basic, path, expected string) {
+	t.Helper()
+	content, err := util.ReadFile(filesystem, path)
+	if err != nil {
+		t.Fatalf("error reading %q: %v", path, err)
+	}
+	if string(content) != expected {
+		t.Errorf("expected %q to contain %q, got %q", path, expected, content)
+	}
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fixtures
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// HubResource is a version of a resource served by a Hub.
+type HubResource struct {
+	Catalog string
+	Kind    string
+	Name    string
+	Version string
+
+	// YAML is the content of the resource.
+	YAML string
+
+	// Hang makes requests for the resource go unanswered until the
+	// client gives up, for testing timeouts and cancellation.
+	Hang bool
+}
+
+// Hub serves the Tekton Hub API's endpoint for fetching the YAML of a
+// version of a resource:
+//
+//	GET /v1/resource/<catalog>/<kind>/<name>/<version>/yaml
+//
+// Resources it doesn't have get the same 404 response as from the real
+// Hub.
+type Hub struct {
+	// URL is the base URL of the API, the equivalent of
+	// https://api.hub.tekton.dev.
+	URL string
+}
+
+// NewHub starts a Hub serving resources.
+func NewHub(t *testing.T, resources ...HubResource) *Hub {
+	t.Helper()
+	byPath := map[string]HubResource{}
+	for _, resource := range resources {
+		byPath[hubResourcePath(resource.Catalog, resource.Kind, resource.Name, resource.Version)] = resource
+	}
+	done := make(chan struct{})
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		resource, ok := byPath[req.URL.Path]
+		if ok && resource.Hang {
+			select {
+			case <-req.Context().Done():
+			case <-done:
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if req.Method != http.MethodGet || !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"name":      "not-found",
+				"id":        "fixture",
+				"message":   "resource not found",
+				"temporary": false,
+				"timeout":   false,
+				"fault":     false,
+			})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]string{"yaml": resource.YAML},
+		})
+	}))
+	// Cleanups run last first, so hanging requests are released
+	// before Close waits for them.
+	t.Cleanup(svr.Close)
+	t.Cleanup(func() { close(done) })
+	return &Hub{URL: svr.URL}
+}
+
+func hubResourcePath(catalog, kind, name, version string) string {
+	return "/" + strings.Join([]string{"v1", "resource", catalog, kind, name, version, "yaml"}, "/")
+}
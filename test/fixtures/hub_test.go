@@ -0,0 +1,76 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fixtures
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestHub(t *testing.T) {
+	hub := NewHub(t, HubResource{
+		Catalog: "tekton",
+		Kind:    "task",
+		Name:    "foo",
+		Version: "0.1",
+		YAML:    "some: yaml\n",
+	}, HubResource{
+		Catalog: "tekton",
+		Kind:    "task",
+		Name:    "slow",
+		Version: "0.1",
+		Hang:    true,
+	})
+
+	for _, tc := range []struct {
+		path           string
+		expectedStatus int
+		expectedBody   string
+	}{{
+		path:           "/v1/resource/tekton/task/foo/0.1/yaml",
+		expectedStatus: http.StatusOK,
+		expectedBody:   `{"data":{"yaml":"some: yaml\n"}}` + "\n",
+	}, {
+		path:           "/v1/resource/tekton/task/foo/0.2/yaml",
+		expectedStatus: http.StatusNotFound,
+		expectedBody:   `{"fault":false,"id":"fixture","message":"resource not found","name":"not-found","temporary":false,"timeout":false}` + "\n",
+	}} {
+		resp, err := http.Get(hub.URL + tc.path)
+		if err != nil {
+			t.Fatalf("error getting %s: %v", tc.path, err)
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tc.expectedStatus {
+			t.Errorf("expected status %d for %s, got %d", tc.expectedStatus, tc.path, resp.StatusCode)
+		}
+		if string(body) != tc.expectedBody {
+			t.Errorf("expected body %q for %s, got %q", tc.expectedBody, tc.path, body)
+		}
+	}
+
+	client := &http.Client{Timeout: 100 * time.Millisecond}
+	if resp, err := client.Get(hub.URL + "/v1/resource/tekton/task/slow/0.1/yaml"); err == nil {
+		resp.Body.Close()
+		t.Errorf("expected request for hanging resource to time out, got status %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,284 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fixtures
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"sigs.k8s.io/yaml"
+)
+
+// The layer annotations that identify the resource in each layer of a
+// Tekton bundle.
+const (
+	bundleAnnotationKind       = "dev.tekton.image.kind"
+	bundleAnnotationName       = "dev.tekton.image.name"
+	bundleAnnotationAPIVersion = "dev.tekton.image.apiVersion"
+)
+
+// Bundle describes a Tekton bundle pushed to a Registry.
+type Bundle struct {
+	// Name is the repository and tag the bundle is pushed to,
+	// relative to the registry, e.g. "catalog/tasks:v1".
+	Name string
+
+	// Resources are the YAML documents of the Tekton resources in the
+	// bundle. Each becomes one layer, annotated with its kind, name
+	// and apiVersion.
+	Resources []string
+}
+
+// Registry is an OCI registry serving Tekton bundles.
+type Registry struct {
+	// Host is the host and port of the registry, e.g.
+	// 127.0.0.1:34567.
+	Host string
+
+	digests map[string]string
+}
+
+// NewRegistry starts a Registry and pushes bundles to it.
+func NewRegistry(t *testing.T, bundles ...Bundle) *Registry {
+	t.Helper()
+	svr := httptest.NewServer(registry.New(registry.Logger(log.New(ioutil.Discard, "", 0))))
+	t.Cleanup(svr.Close)
+	r := &Registry{
+		Host:    strings.TrimPrefix(svr.URL, "http://"),
+		digests: map[string]string{},
+	}
+	for _, bundle := range bundles {
+		if err := r.push(bundle); err != nil {
+			t.Fatalf("error pushing bundle %q: %v", bundle.Name, err)
+		}
+	}
+	return r
+}
+
+// Ref returns the full reference of the named bundle, for use as the
+// bundle resolver's bundle param.
+func (r *Registry) Ref(name string) string {
+	return r.Host + "/" + name
+}
+
+// DigestRef returns the reference of the named bundle by its digest
+// rather than its tag.
+func (r *Registry) DigestRef(name string) string {
+	repo := name
+	if i := strings.LastIndex(name, ":"); i > 0 {
+		repo = name[:i]
+	}
+	return r.Host + "/" + repo + "@" + r.digests[name]
+}
+
+func (r *Registry) push(bundle Bundle) error {
+	img, err := BundleImage(bundle.Resources...)
+	if err != nil {
+		return err
+	}
+	ref, err := name.ParseReference(r.Ref(bundle.Name))
+	if err != nil {
+		return err
+	}
+	if err := remote.Write(ref, img); err != nil {
+		return err
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		return err
+	}
+	r.digests[bundle.Name] = digest.String()
+	return nil
+}
+
+// BundleImage builds a Tekton bundle from the YAML documents of Tekton
+// resources. Like the bundles built by `tkn bundle push`, each resource
+// is in its own layer as the only file in a tarball.
+func BundleImage(resources ...string) (v1.Image, error) {
+	if len(resources) == 0 {
+		return nil, errors.New("a bundle needs at least one resource")
+	}
+	img := &bundleImage{layers: map[v1.Hash]*bundleLayer{}}
+	manifest := v1.Manifest{
+		SchemaVersion: 2,
+		MediaType:     types.OCIManifestSchema1,
+	}
+	config := v1.ConfigFile{
+		OS:           "linux",
+		Architecture: "amd64",
+		RootFS:       v1.RootFS{Type: "layers"},
+	}
+	for i, resource := range resources {
+		meta := struct {
+			APIVersion string `json:"apiVersion"`
+			Kind       string `json:"kind"`
+			Metadata   struct {
+				Name string `json:"name"`
+			} `json:"metadata"`
+		}{}
+		if err := yaml.Unmarshal([]byte(resource), &meta); err != nil {
+			return nil, fmt.Errorf("resource %d: %w", i, err)
+		}
+		if meta.APIVersion == "" || meta.Kind == "" || meta.Metadata.Name == "" {
+			return nil, fmt.Errorf("resource %d: apiVersion, kind and metadata.name are required", i)
+		}
+
+		layer, diffID, err := tarLayer(meta.Metadata.Name, []byte(resource))
+		if err != nil {
+			return nil, fmt.Errorf("resource %d: %w", i, err)
+		}
+		img.layers[layer.digest] = layer
+		config.RootFS.DiffIDs = append(config.RootFS.DiffIDs, diffID)
+		manifest.Layers = append(manifest.Layers, v1.Descriptor{
+			MediaType: types.OCILayer,
+			Size:      int64(len(layer.content)),
+			Digest:    layer.digest,
+			Annotations: map[string]string{
+				bundleAnnotationKind:       strings.ToLower(meta.Kind),
+				bundleAnnotationName:       meta.Metadata.Name,
+				bundleAnnotationAPIVersion: meta.APIVersion,
+			},
+		})
+	}
+
+	var err error
+	if img.config, err = json.Marshal(config); err != nil {
+		return nil, err
+	}
+	configDigest, configSize, err := v1.SHA256(bytes.NewReader(img.config))
+	if err != nil {
+		return nil, err
+	}
+	manifest.Config = v1.Descriptor{
+		MediaType: types.OCIConfigJSON,
+		Size:      configSize,
+		Digest:    configDigest,
+	}
+	if img.manifest, err = json.Marshal(manifest); err != nil {
+		return nil, err
+	}
+	return partial.CompressedToImage(img)
+}
+
+// tarLayer returns a gzipped layer holding a tarball with a single
+// file, along with the digest of the uncompressed tarball.
+func tarLayer(filename string, content []byte) (*bundleLayer, v1.Hash, error) {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	if err := tw.WriteHeader(&tar.Header{
+		Name:     filename,
+		Mode:     0600,
+		Size:     int64(len(content)),
+		Typeflag: tar.TypeReg,
+	}); err != nil {
+		return nil, v1.Hash{}, err
+	}
+	if _, err := tw.Write(content); err != nil {
+		return nil, v1.Hash{}, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, v1.Hash{}, err
+	}
+	diffID, _, err := v1.SHA256(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		return nil, v1.Hash{}, err
+	}
+
+	compressed := &bytes.Buffer{}
+	zw := gzip.NewWriter(compressed)
+	if _, err := zw.Write(buf.Bytes()); err != nil {
+		return nil, v1.Hash{}, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, v1.Hash{}, err
+	}
+	digest, _, err := v1.SHA256(bytes.NewReader(compressed.Bytes()))
+	if err != nil {
+		return nil, v1.Hash{}, err
+	}
+	return &bundleLayer{content: compressed.Bytes(), digest: digest}, diffID, nil
+}
+
+// bundleImage is the minimum needed to build a v1.Image from an image's
+// manifest, config and layers.
+type bundleImage struct {
+	manifest []byte
+	config   []byte
+	layers   map[v1.Hash]*bundleLayer
+}
+
+var _ partial.CompressedImageCore = &bundleImage{}
+
+func (i *bundleImage) RawConfigFile() ([]byte, error) {
+	return i.config, nil
+}
+
+func (i *bundleImage) MediaType() (types.MediaType, error) {
+	return types.OCIManifestSchema1, nil
+}
+
+func (i *bundleImage) RawManifest() ([]byte, error) {
+	return i.manifest, nil
+}
+
+func (i *bundleImage) LayerByDigest(digest v1.Hash) (partial.CompressedLayer, error) {
+	layer, ok := i.layers[digest]
+	if !ok {
+		return nil, fmt.Errorf("no layer with digest %s", digest)
+	}
+	return layer, nil
+}
+
+// bundleLayer is a gzipped layer held in memory.
+type bundleLayer struct {
+	content []byte
+	digest  v1.Hash
+}
+
+var _ partial.CompressedLayer = &bundleLayer{}
+
+func (l *bundleLayer) Digest() (v1.Hash, error) {
+	return l.digest, nil
+}
+
+func (l *bundleLayer) Compressed() (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(l.content)), nil
+}
+
+func (l *bundleLayer) Size() (int64, error) {
+	return int64(len(l.content)), nil
+}
+
+func (l *bundleLayer) MediaType() (types.MediaType, error) {
+	return types.OCILayer, nil
+}
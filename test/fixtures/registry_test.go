@@ -0,0 +1,114 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fixtures
+
+import (
+	"archive/tar"
+	"io/ioutil"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/tektoncd/resolution/test/diff"
+)
+
+const (
+	taskYAML = `apiVersion: tekton.dev/v1beta1
+kind: Task
+metadata:
+  name: foo
+`
+	pipelineYAML = `apiVersion: tekton.dev/v1beta1
+kind: Pipeline
+metadata:
+  name: bar
+`
+)
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry(t, Bundle{
+		Name:      "catalog/bundle:v1",
+		Resources: []string{taskYAML, pipelineYAML},
+	})
+
+	for _, bundle := range []string{registry.Ref("catalog/bundle:v1"), registry.DigestRef("catalog/bundle:v1")} {
+		ref, err := name.ParseReference(bundle)
+		if err != nil {
+			t.Fatalf("error parsing %q: %v", bundle, err)
+		}
+		img, err := remote.Image(ref)
+		if err != nil {
+			t.Fatalf("error pulling %q: %v", bundle, err)
+		}
+		manifest, err := img.Manifest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var annotations []map[string]string
+		for _, layer := range manifest.Layers {
+			annotations = append(annotations, layer.Annotations)
+		}
+		expected := []map[string]string{{
+			"dev.tekton.image.kind":       "task",
+			"dev.tekton.image.name":       "foo",
+			"dev.tekton.image.apiVersion": "tekton.dev/v1beta1",
+		}, {
+			"dev.tekton.image.kind":       "pipeline",
+			"dev.tekton.image.name":       "bar",
+			"dev.tekton.image.apiVersion": "tekton.dev/v1beta1",
+		}}
+		if d := cmp.Diff(expected, annotations); d != "" {
+			t.Errorf("unexpected layer annotations %s", diff.PrintWantGot(d))
+		}
+
+		layers, err := img.Layers()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, expected := range []string{taskYAML, pipelineYAML} {
+			uncompressed, err := layers[i].Uncompressed()
+			if err != nil {
+				t.Fatal(err)
+			}
+			tr := tar.NewReader(uncompressed)
+			if _, err := tr.Next(); err != nil {
+				t.Fatalf("error reading layer %d: %v", i, err)
+			}
+			content, err := ioutil.ReadAll(tr)
+			if err != nil {
+				t.Fatalf("error reading layer %d: %v", i, err)
+			}
+			if string(content) != expected {
+				t.Errorf("expected layer %d to contain %q, got %q", i, expected, content)
+			}
+			uncompressed.Close()
+		}
+	}
+}
+
+func TestBundleImageRequiresResourceMetadata(t *testing.T) {
+	for _, resources := range [][]string{
+		nil,
+		{"kind: Task"},
+		{"not: [yaml"},
+	} {
+		if _, err := BundleImage(resources...); err == nil {
+			t.Errorf("expected error building bundle from %q", resources)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2022 The Tekton Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fixtures
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"golang.org/x/crypto/ssh"
+)
+
+// GPGSigner returns a GitCommit.Sign func that signs commits with a GPG
+// key.
+func GPGSigner(entity *openpgp.Entity) func([]byte) (string, error) {
+	return func(payload []byte) (string, error) {
+		var signature bytes.Buffer
+		if err := openpgp.ArmoredDetachSign(&signature, entity, bytes.NewReader(payload), nil); err != nil {
+			return "", err
+		}
+		return signature.String(), nil
+	}
+}
+
+// SSHSigner returns a GitCommit.Sign func that signs commits with an
+// SSH key the same way git does, following OpenSSH's PROTOCOL.sshsig.
+func SSHSigner(signer ssh.Signer) func([]byte) (string, error) {
+	return func(payload []byte) (string, error) {
+		hash := sha512.Sum512(payload)
+		signed := append([]byte("SSHSIG"), ssh.Marshal(struct {
+			Namespace     string
+			Reserved      string
+			HashAlgorithm string
+			Hash          []byte
+		}{"git", "", "sha512", hash[:]})...)
+		signature, err := signer.Sign(rand.Reader, signed)
+		if err != nil {
+			return "", err
+		}
+		blob := append([]byte("SSHSIG"), ssh.Marshal(struct {
+			Version       uint32
+			PublicKey     []byte
+			Namespace     string
+			Reserved      string
+			HashAlgorithm string
+			Signature     []byte
+		}{1, signer.PublicKey().Marshal(), "git", "", "sha512", ssh.Marshal(signature)})...)
+
+		encoded := base64.StdEncoding.EncodeToString(blob)
+		lines := []string{"-----BEGIN SSH SIGNATURE-----"}
+		for len(encoded) > 70 {
+			lines = append(lines, encoded[:70])
+			encoded = encoded[70:]
+		}
+		lines = append(lines, encoded, "-----END SSH SIGNATURE-----")
+		return strings.Join(lines, "\n") + "\n", nil
+	}
+}